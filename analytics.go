@@ -0,0 +1,140 @@
+package envconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A UseStatus says how a config field got its value.
+type UseStatus int
+
+const (
+	// UseSet means the field's variable was set.
+	UseSet UseStatus = iota
+	// UseDefaultFrom means the field's variable was unset, and the
+	// variable named by its "defaultFrom" tag was used.
+	UseDefaultFrom
+	// UseDefault means the field's "default" tag was used.
+	UseDefault
+	// UseUnset means the field was left unset.
+	UseUnset
+	// UseKept means the field already held a value, which was kept
+	// according to the Decoder's OverwritePolicy.
+	UseKept
+)
+
+func (s UseStatus) String() string {
+	switch s {
+	case UseSet:
+		return "set"
+	case UseDefaultFrom:
+		return "defaultFrom"
+	case UseDefault:
+		return "default"
+	case UseUnset:
+		return "unset"
+	case UseKept:
+		return "kept"
+	}
+	return fmt.Sprintf("UseStatus(%d)", int(s))
+}
+
+// A VariableUse records how one field was decoded, for WithAnalytics.
+type VariableUse struct {
+	// Name is the field's variable.
+	Name string
+	// Field is the path of Go field names, joined with ".".
+	Field  string
+	Status UseStatus
+	// From is the variable the value was read from, which is Name or one of
+	// its aliases or, with UseDefaultFrom, the defaultFrom variable. It is
+	// empty for UseDefault and UseUnset.
+	From string
+	// Source is the name of the Source which held From, as given by
+	// SourceName. With Layers, it is the layer which supplied the value.
+	Source string
+	// Deprecated is true if the value was read from a variable which is
+	// deprecated; see the "deprecated" tag.
+	Deprecated bool
+	// Blank is true if the variable, or one of its aliases, held a blank
+	// value which WithBlankAsUnset treated as unset.
+	Blank bool
+}
+
+// WithAnalytics sets a sink which is passed a record of every variable read
+// by each call to Decode or DecodeLazy: whether it was set, fell back to
+// another variable or to a default, or was left unset, and whether it is
+// deprecated. Values are not included. Platform teams can use it to measure
+// which settings are really used before removing them:
+//
+//	envconf.WithAnalytics(func(uses []envconf.VariableUse) {
+//		for _, u := range uses {
+//			metrics.Inc("config_variable", u.Name, u.Status.String())
+//		}
+//	})
+//
+// Fields whose types implement EnvDecoder, and Lazy fields, which are read
+// outside of Decode, are not reported.
+func WithAnalytics(sink func(uses []VariableUse)) Option {
+	return func(d *Decoder) { d.analytics = sink }
+}
+
+// sourceOf returns the name of the Source which supplied the variable name,
+// looking through Layers to the layer it came from, or the empty string if
+// name is empty.
+func (d *Decoder) sourceOf(name string) string {
+	if len(name) == 0 {
+		return ""
+	}
+	src := d.source
+	for {
+		l, ok := src.(*Layers)
+		if !ok {
+			return SourceName(src)
+		}
+		i, ok := l.Origin(name)
+		if !ok {
+			return SourceName(src)
+		}
+		src = l.sources[i]
+	}
+}
+
+// recordLookup records the variable a field's value was found in, if any,
+// and warns if the field is deprecated.
+func (d *Decoder) recordLookup(st *decodeState, pf PlannedField, from string) {
+	if len(from) == 0 {
+		return
+	}
+	status := UseSet
+	if from == pf.DefaultFrom {
+		status = UseDefaultFrom
+	}
+	d.recordUse(st, pf, status, from)
+}
+
+// recordUse records how the field pf was decoded. A field with a
+// "deprecated" tag whose variable was set is reported to the warning func.
+func (d *Decoder) recordUse(st *decodeState, pf PlannedField, status UseStatus, from string) {
+	msg, deprecated := pf.Field.Tag.Lookup("deprecated")
+	deprecated = deprecated && status == UseSet
+	if deprecated {
+		err := fmt.Errorf("Config variable %s is deprecated", pf.Name)
+		if len(msg) > 0 && msg != "true" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		d.warn(err)
+	}
+
+	if d.analytics != nil {
+		st.uses = append(st.uses, VariableUse{
+			Name:       pf.Name,
+			Field:      strings.Join(pf.Path, "."),
+			Status:     status,
+			From:       from,
+			Source:     d.sourceOf(from),
+			Deprecated: deprecated,
+			Blank:      st.blank,
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package envconf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithAnalytics(t *testing.T) {
+	var conf struct {
+		Port    int    `default:"80"`
+		Host    string `required:"true"`
+		Replica string `defaultFrom:"HOST"`
+		Workers int    `deprecated:"use CONCURRENCY"`
+		Legacy  bool   `deprecated:"true"`
+		Label   string
+		Name    string `required:"true"`
+	}
+
+	var uses []VariableUse
+	var warnings []error
+	d := NewDecoder(
+		WithSource(MapSource{"HOST": "db", "WORKERS": "4", "LEGACY": "1"}),
+		WithAnalytics(func(u []VariableUse) { uses = u }),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }),
+	)
+	if err := d.Decode(&conf); err == nil {
+		t.Errorf("Decode(): expected an error for NAME")
+		t.Fail()
+	}
+
+	expect := []VariableUse{
+		{Name: "PORT", Field: "Port", Status: UseDefault},
+		{Name: "HOST", Field: "Host", Status: UseSet, From: "HOST", Source: "map"},
+		{Name: "REPLICA", Field: "Replica", Status: UseDefaultFrom, From: "HOST", Source: "map"},
+		{Name: "WORKERS", Field: "Workers", Status: UseSet, From: "WORKERS", Source: "map", Deprecated: true},
+		{Name: "LEGACY", Field: "Legacy", Status: UseSet, From: "LEGACY", Source: "map", Deprecated: true},
+		{Name: "LABEL", Field: "Label", Status: UseUnset},
+		{Name: "NAME", Field: "Name", Status: UseUnset},
+	}
+	if !reflect.DeepEqual(uses, expect) {
+		t.Errorf("Expected uses %+v, got %+v", expect, uses)
+		t.Fail()
+	}
+
+	if len(warnings) != 2 || warnings[0].Error() != "Config variable WORKERS is deprecated: use CONCURRENCY" ||
+		warnings[1].Error() != "Config variable LEGACY is deprecated" {
+		t.Errorf("Expected a warning per deprecated variable, got %v", warnings)
+		t.Fail()
+	}
+}
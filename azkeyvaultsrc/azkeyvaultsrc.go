@@ -0,0 +1,178 @@
+/*
+Package azkeyvaultsrc provides an envconf Source backed by Azure Key Vault
+secrets.
+
+Key Vault secret names may only hold letters, digits and dashes, so variables
+are mapped to secrets by lower-casing them and turning underscores into
+dashes: DB_PASSWORD is read from the secret db-password.
+
+	src := azkeyvaultsrc.New(client)
+	err := envconf.NewDecoder(envconf.WithSource(
+		envconf.LayerSources(envconf.EnvSource{}, src))).Decode(&conf)
+
+The package does not depend on the Azure SDK. Instead it takes a Client,
+which an application adapts from the SDK it already uses; with azsecrets:
+
+	type kvClient struct{ *azsecrets.Client }
+
+	func (c kvClient) GetSecret(ctx context.Context, name string) (string, error) {
+		resp, err := c.Client.GetSecret(ctx, name, "", nil)
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			return "", azkeyvaultsrc.ErrNotFound
+		} else if err != nil {
+			return "", err
+		}
+		return *resp.Value, nil
+	}
+*/
+package azkeyvaultsrc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a Client for a secret which does not exist, or
+// is disabled. The Source treats its variable as unset.
+var ErrNotFound = errors.New("secret not found")
+
+// A Client reads the current version of the named secret.
+type Client interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// DefaultTimeout is the default time limit on each call to the Client.
+const DefaultTimeout = 10 * time.Second
+
+// Source looks up variables in Key Vault.
+type Source struct {
+	client   Client
+	ctx      context.Context
+	timeout  time.Duration
+	secretOf func(name string) string
+
+	mu      sync.Mutex
+	fetched map[string]string
+}
+
+// An Option configures a Source.
+type Option func(*Source)
+
+// WithContext sets the context of each call to the Client. The default is
+// context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(s *Source) {
+		s.ctx = ctx
+	}
+}
+
+// WithTimeout sets the time limit on each call to the Client. The default is
+// DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Source) {
+		s.timeout = d
+	}
+}
+
+// WithNameMapper sets how variable names are mapped to secret names. Names
+// it returns which are not valid secret names are treated as unset.
+func WithNameMapper(fn func(name string) string) Option {
+	return func(s *Source) {
+		s.secretOf = fn
+	}
+}
+
+// DefaultNameMapper lower-cases name and turns underscores into dashes, so
+// DB_PASSWORD becomes db-password.
+func DefaultNameMapper(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// New returns a Source reading secrets with client.
+func New(client Client, opts ...Option) *Source {
+	s := &Source{
+		client:   client,
+		ctx:      context.Background(),
+		timeout:  DefaultTimeout,
+		secretOf: DefaultNameMapper,
+		fetched:  make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Locate returns the name of the secret name is read from.
+func (s *Source) Locate(name string) (string, bool) {
+	secret := s.secretOf(name)
+	return secret, validName(secret)
+}
+
+// validName reports whether name is a valid secret name: 1 to 127 letters,
+// digits and dashes.
+func validName(name string) bool {
+	if len(name) == 0 || len(name) > 127 {
+		return false
+	}
+	for _, c := range name {
+		if !(c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Lookup reads the secret for name. A variable whose secret does not exist
+// is unset.
+func (s *Source) Lookup(name string) (string, bool, error) {
+	return s.LookupContext(s.ctx, name)
+}
+
+// LookupContext is like Lookup, reading the secret with ctx in place of the
+// context set by WithContext. The timeout still applies.
+func (s *Source) LookupContext(ctx context.Context, name string) (string, bool, error) {
+	secret, ok := s.Locate(name)
+	if !ok {
+		return "", false, nil
+	}
+
+	s.mu.Lock()
+	v, ok := s.fetched[secret]
+	s.mu.Unlock()
+	if ok {
+		return v, true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	v, err := s.client.GetSecret(ctx, secret)
+	if errors.Is(err, ErrNotFound) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("Reading Key Vault secret %s failed: %v", secret, err)
+	}
+
+	s.mu.Lock()
+	s.fetched[secret] = v
+	s.mu.Unlock()
+	return v, true, nil
+}
+
+// Refresh discards the secrets read, so that they are read again on their
+// next lookup.
+func (s *Source) Refresh() {
+	s.mu.Lock()
+	s.fetched = make(map[string]string)
+	s.mu.Unlock()
+}
+
+// Name identifies the source in errors and reports.
+func (s *Source) Name() string {
+	return "azure-key-vault"
+}
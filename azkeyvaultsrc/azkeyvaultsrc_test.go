@@ -0,0 +1,99 @@
+package azkeyvaultsrc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// fakeKeyVault serves secrets from a map.
+type fakeKeyVault struct {
+	secrets map[string]string
+	names   []string
+	err     error
+}
+
+func (f *fakeKeyVault) GetSecret(ctx context.Context, name string) (string, error) {
+	f.names = append(f.names, name)
+	if f.err != nil {
+		return "", f.err
+	}
+	if v, ok := f.secrets[name]; ok {
+		return v, nil
+	}
+	return "", ErrNotFound
+}
+
+type appConfig struct {
+	Port int `default:"80"`
+	DB   struct {
+		Password string `required:"true"`
+	}
+	Path string
+}
+
+func TestSource(t *testing.T) {
+	fake := &fakeKeyVault{secrets: map[string]string{"db-password": "hunter2", "port": "8080"}}
+	src := New(fake)
+
+	var conf appConfig
+	if err := envconf.ReadConfigSource(&conf, src); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 8080 || conf.DB.Password != "hunter2" {
+		t.Errorf("Unexpected config %+v", conf)
+		t.Fail()
+	}
+	if strings.Join(fake.names, ",") != "port,db-password,path" {
+		t.Errorf("Unexpected secrets read: %v", fake.names)
+		t.Fail()
+	}
+
+	// Secrets are cached until Refresh.
+	envconf.ReadConfigSource(&conf, src)
+	if len(fake.names) != 4 {
+		t.Errorf("Expected only the missing secret to be read again, got %v", fake.names)
+		t.Fail()
+	}
+	src.Refresh()
+	envconf.ReadConfigSource(&conf, src)
+	if len(fake.names) != 7 {
+		t.Errorf("Expected every secret to be read after Refresh, got %v", fake.names)
+		t.Fail()
+	}
+
+	// Names which can't be secret names are unset without a call.
+	src = New(fake, WithNameMapper(func(name string) string { return "app_" + name }))
+	if _, ok, err := src.Lookup("PORT"); ok || err != nil {
+		t.Errorf("Lookup(): expected an invalid secret name to be unset, got %v, %v", ok, err)
+		t.Fail()
+	}
+	if _, ok := src.Locate("PORT"); ok {
+		t.Errorf("Locate(): expected no secret for an invalid name")
+		t.Fail()
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	fake := &fakeKeyVault{err: errors.New("403 Forbidden")}
+	var conf appConfig
+	err := envconf.ReadConfigSource(&conf, New(fake, WithTimeout(time.Second)))
+	match := "Reading Key Vault secret port failed: 403 Forbidden"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	fake.err = nil
+	err = envconf.ReadConfigSource(&conf, New(fake))
+	match = "Missing config fields: DB_PASSWORD"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Command envconf-doc writes Markdown documentation of the environment
+variables a program reads, from the schema the program exports with
+envconf's Decoder.ExportSchema:
+
+	myservice -export-config-schema | envconf-doc -o CONFIG.md
+
+In a release process, -check makes sure the documentation has been kept up
+to date, by failing if the file differs from what would be written:
+
+	myservice -export-config-schema | envconf-doc -check -o CONFIG.md
+
+Usage:
+
+	envconf-doc [-o file] [-check] [schema.json]
+
+The schema is read from the named file, or from standard input. Without -o,
+the Markdown is written to standard output.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ceralena/envconf"
+)
+
+func main() {
+	output := flag.String("o", "", "write the Markdown to `file`")
+	check := flag.Bool("check", false, "fail if the file given with -o is out of date")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: envconf-doc [-o file] [-check] [schema.json]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 || (*check && len(*output) == 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in := os.Stdin
+	if flag.NArg() == 1 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if err := run(in, os.Stdout, *output, *check); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run renders the schema read from in, and writes it to output, or to
+// stdout if output is empty. With check, output is compared instead.
+func run(in io.Reader, stdout io.Writer, output string, check bool) error {
+	var schema envconf.Schema
+	if err := json.NewDecoder(in).Decode(&schema); err != nil {
+		return fmt.Errorf("Invalid schema: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := schema.WriteMarkdown(&buf); err != nil {
+		return err
+	}
+
+	switch {
+	case len(output) == 0:
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	case check:
+		existing, err := os.ReadFile(output)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(existing, buf.Bytes()) {
+			return fmt.Errorf("%s is out of date; regenerate it with envconf-doc", output)
+		}
+		return nil
+	default:
+		return os.WriteFile(output, buf.Bytes(), 0644)
+	}
+}
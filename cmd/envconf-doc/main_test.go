@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+func TestRun(t *testing.T) {
+	var conf struct {
+		Port int `required:"true" desc:"port to listen on"`
+	}
+	var schema bytes.Buffer
+	if err := envconf.NewDecoder().ExportSchema(&schema, &conf); err != nil {
+		t.Fatal(err)
+	}
+	expect := "| Variable | Type | Default | Required | Description |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `PORT` | `int` |  | yes | port to listen on |\n"
+
+	var out bytes.Buffer
+	if err := run(bytes.NewReader(schema.Bytes()), &out, "", false); err != nil || out.String() != expect {
+		t.Errorf("run(): expected\n%s\ngot\n%s, %v", expect, out.String(), err)
+		t.Fail()
+	}
+
+	path := filepath.Join(t.TempDir(), "CONFIG.md")
+	if err := run(bytes.NewReader(schema.Bytes()), &out, path, false); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if err := run(bytes.NewReader(schema.Bytes()), &out, path, true); err != nil {
+		t.Errorf("run(): expected an up to date file to pass -check, got %v", err)
+		t.Fail()
+	}
+
+	os.WriteFile(path, []byte("stale"), 0644)
+	err := run(bytes.NewReader(schema.Bytes()), &out, path, true)
+	match := "CONFIG.md is out of date"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	err = run(strings.NewReader("nope"), &out, "", false)
+	match = "Invalid schema"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Command envconf-migrate rewrites calls to envconf's ReadConfig functions into
+the equivalent Decoder calls, so that a large codebase can move to the
+Decoder API mechanically:
+
+	envconf.ReadConfigEnvPrefix("APP_", &conf)
+
+becomes
+
+	envconf.NewDecoder(envconf.WithPrefix("APP_")).Decode(&conf)
+
+The ReadConfig functions remain supported, and behave exactly as the calls
+they are rewritten to, so the rewrite changes nothing by itself; migrating is
+only needed to go on to use Decoder options. Both differ in places from the
+ReadConfig functions of earlier releases, as listed in envconf's README.
+
+Usage:
+
+	envconf-migrate [-w] [-l] path ...
+
+Each path is a Go file or a directory, which is walked for Go files outside
+vendor and testdata directories. By default the rewritten files are printed;
+-w writes them back in place and -l lists the files which would change.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const importPath = "github.com/ceralena/envconf"
+
+func main() {
+	write := flag.Bool("w", false, "write rewritten files in place")
+	list := flag.Bool("l", false, "list files which would be rewritten")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: envconf-migrate [-w] [-l] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, root := range flag.Args() {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if path != root && (info.Name() == "vendor" || info.Name() == "testdata") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, ".go") {
+				return nil
+			}
+			return migrateFile(path, *write, *list)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// migrateFile rewrites the file at path, and writes, lists or prints it.
+func migrateFile(path string, write, list bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, n, err := rewrite(path, src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case n == 0 && (write || list):
+		return nil
+	case list:
+		fmt.Println(path)
+		return nil
+	case write:
+		return os.WriteFile(path, out, 0644)
+	default:
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+}
+
+// rewrite rewrites the calls to ReadConfig functions in the Go source src,
+// and returns the formatted result and the number of calls rewritten.
+func rewrite(filename string, src []byte) ([]byte, int, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pkg := localName(f)
+	if len(pkg) == 0 {
+		return src, 0, nil
+	}
+
+	n := 0
+	ast.Inspect(f, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpr); ok && rewriteCall(pkg, call) {
+			n++
+		}
+		return true
+	})
+	if n == 0 {
+		return src, 0, nil
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), n, nil
+}
+
+// localName returns the name envconf is imported as in f, or the empty
+// string if it is not imported or is imported with "." or "_".
+func localName(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p != importPath {
+			continue
+		}
+		if imp.Name == nil {
+			return "envconf"
+		} else if imp.Name.Name != "." && imp.Name.Name != "_" {
+			return imp.Name.Name
+		}
+	}
+	return ""
+}
+
+// rewriteCall rewrites call in place if it calls a ReadConfig function of
+// the package named pkg, and reports whether it did.
+func rewriteCall(pkg string, call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || x.Name != pkg || x.Obj != nil {
+		return false
+	}
+
+	// New nodes take the position of the call, so that the printer keeps
+	// comments around it in place.
+	pos := call.Pos()
+	ident := func(name string) *ast.Ident {
+		return &ast.Ident{NamePos: pos, Name: name}
+	}
+	ref := func(name string) ast.Expr {
+		return &ast.SelectorExpr{X: ident(pkg), Sel: ident(name)}
+	}
+	option := func(name string, arg ast.Expr) ast.Expr {
+		return &ast.CallExpr{Fun: ref(name), Lparen: pos, Args: []ast.Expr{arg}, Rparen: arg.End()}
+	}
+
+	var conf ast.Expr
+	var opts []ast.Expr
+	args := call.Args
+	switch {
+	case sel.Sel.Name == "ReadConfig" && len(args) == 2:
+		conf, opts = args[0], []ast.Expr{option("WithGetter", args[1])}
+	case sel.Sel.Name == "ReadConfigEnv" && len(args) == 1:
+		conf = args[0]
+	case sel.Sel.Name == "ReadConfigEnvPrefix" && len(args) == 2:
+		conf, opts = args[1], []ast.Expr{option("WithPrefix", args[0])}
+	case sel.Sel.Name == "ReadConfigMap" && len(args) == 2:
+		conf, opts = args[0], []ast.Expr{option("WithSource", option("MapSource", args[1]))}
+	case sel.Sel.Name == "ReadConfigSource" && len(args) == 2:
+		conf, opts = args[0], []ast.Expr{option("WithSource", args[1])}
+	default:
+		return false
+	}
+
+	decoder := &ast.CallExpr{Fun: ref("NewDecoder"), Lparen: pos, Args: opts, Rparen: pos}
+	call.Fun = &ast.SelectorExpr{X: decoder, Sel: ident("Decode")}
+	call.Args = []ast.Expr{conf}
+	return true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const before = `package main
+
+import (
+	"os"
+
+	ec "github.com/ceralena/envconf"
+)
+
+func load() error {
+	var conf struct{ Port int }
+	if err := ec.ReadConfigEnvPrefix("APP_", &conf); err != nil {
+		return err
+	}
+	// Keep this comment.
+	ec.ReadConfig(&conf, os.Getenv)
+	ec.ReadConfigEnv(&conf)
+	ec.ReadConfigMap(&conf, map[string]string{"PORT": "80"})
+	return ec.ReadConfigDotenv(&conf, ".env")
+}
+`
+
+const after = `package main
+
+import (
+	"os"
+
+	ec "github.com/ceralena/envconf"
+)
+
+func load() error {
+	var conf struct{ Port int }
+	if err := ec.NewDecoder(ec.WithPrefix("APP_")).Decode(&conf); err != nil {
+		return err
+	}
+	// Keep this comment.
+	ec.NewDecoder(ec.WithGetter(os.Getenv)).Decode(&conf)
+	ec.NewDecoder().Decode(&conf)
+	ec.NewDecoder(ec.WithSource(ec.MapSource(map[string]string{"PORT": "80"}))).Decode(&conf)
+	return ec.ReadConfigDotenv(&conf, ".env")
+}
+`
+
+func TestRewrite(t *testing.T) {
+	out, n, err := rewrite("main.go", []byte(before))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if n != 4 || string(out) != after {
+		t.Errorf("rewrite(): expected 4 calls rewritten to\n%s\ngot %d:\n%s", after, n, out)
+		t.Fail()
+	}
+
+	// Another package's ReadConfig, and a local variable shadowing the
+	// package name, are left alone.
+	other := strings.Replace(before, `ec "github.com/ceralena/envconf"`, `ec "example.com/other"`, 1)
+	if out, n, _ := rewrite("main.go", []byte(other)); n != 0 || string(out) != other {
+		t.Errorf("rewrite(): expected no changes to other packages, got %d:\n%s", n, out)
+		t.Fail()
+	}
+	shadowed := strings.Replace(before, "var conf struct{ Port int }", "var conf struct{ Port int }\n\tec := loader{}", 1)
+	if _, n, _ := rewrite("main.go", []byte(shadowed)); n != 0 {
+		t.Errorf("rewrite(): expected no changes through a shadowed name, got %d", n)
+		t.Fail()
+	}
+
+	if _, _, err := rewrite("main.go", []byte("package main\nfunc {")); err == nil {
+		t.Errorf("rewrite(): expected a parse error")
+		t.Fail()
+	}
+}
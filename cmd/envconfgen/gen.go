@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A kind says how a value is parsed.
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindBool
+	kindFloat32
+	kindFloat64
+	kindDuration
+	kindText
+)
+
+// runtimeTags are the tags which only envconf's runtime understands.
+var runtimeTags = []string{
+	"convert", "pattern", "min", "max", "minlen", "minentropy", "file",
+	"deprecated", "fetch", "cmd", "required_if", "csv", "trim", "allowempty",
+}
+
+// pkgInfo holds what the generator knows of the package being read.
+type pkgInfo struct {
+	fset  *token.FileSet
+	name  string
+	types map[string]*ast.TypeSpec
+	// the file each type is declared in, for resolving its imports
+	files map[string]*ast.File
+	// types with an UnmarshalText method
+	text map[string]bool
+}
+
+// A generator writes the loader for one struct type.
+type generator struct {
+	pkg     *pkgInfo
+	prefix  string
+	buf     bytes.Buffer
+	imports map[string]string // name -> path
+	// fields with a "required" tag, so the loader reports missing fields
+	required bool
+}
+
+// generate returns the source of the loader for the struct typeName in the
+// package in dir.
+func generate(dir, typeName, prefix string) ([]byte, error) {
+	pkg, err := loadPackage(dir, typeName)
+	if err != nil {
+		return nil, err
+	}
+	spec := pkg.types[typeName]
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok || spec.TypeParams != nil {
+		return nil, fmt.Errorf("%s is not a non-generic struct type", typeName)
+	}
+
+	g := &generator{pkg: pkg, prefix: strings.ToUpper(prefix), imports: make(map[string]string)}
+	if err := g.structFields(st, pkg.files[typeName], "c", nil, scope{}); err != nil {
+		return nil, fmt.Errorf("%s: %v", typeName, err)
+	}
+	fields := g.buf.String()
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by envconfgen; DO NOT EDIT.\n\npackage %s\n\n", pkg.name)
+	if g.required {
+		g.imports["fmt"] = "fmt"
+		g.imports["strings"] = "strings"
+	}
+	if len(g.imports) > 0 {
+		names := make([]string, 0, len(g.imports))
+		for name := range g.imports {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return g.imports[names[i]] < g.imports[names[j]] })
+		out.WriteString("import (\n")
+		for _, name := range names {
+			path := g.imports[name]
+			if name == path[strings.LastIndex(path, "/")+1:] {
+				name = ""
+			}
+			fmt.Fprintf(&out, "\t%s %q\n", name, path)
+		}
+		out.WriteString(")\n\n")
+	}
+	fmt.Fprintf(&out, "// Load%s reads a %s from getter as envconf.ReadConfig would, but\n", typeName, typeName)
+	fmt.Fprintf(&out, "// without reflection. It was generated by envconfgen from the definition of\n// %s.\n", typeName)
+	fmt.Fprintf(&out, "func Load%s(getter func(string) string) (%s, error) {\n", typeName, typeName)
+	fmt.Fprintf(&out, "\tvar c %s\n", typeName)
+	if g.required {
+		out.WriteString("\tvar missing []string\n")
+	}
+	out.WriteString(fields)
+	if g.required {
+		out.WriteString("\tif len(missing) > 0 {\n")
+		out.WriteString("\t\treturn c, fmt.Errorf(\"Missing config fields: %s\", strings.Join(missing, \", \"))\n")
+		out.WriteString("\t}\n")
+	}
+	out.WriteString("\treturn c, nil\n}\n")
+
+	return format.Source(out.Bytes())
+}
+
+// loadPackage parses the non-test Go files in dir and indexes the package
+// declaring typeName.
+func loadPackage(dir, typeName string) (*pkgInfo, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, p := range pkgs {
+		pkg := &pkgInfo{
+			fset:  fset,
+			name:  name,
+			types: make(map[string]*ast.TypeSpec),
+			files: make(map[string]*ast.File),
+			text:  make(map[string]bool),
+		}
+		for _, f := range p.Files {
+			for _, decl := range f.Decls {
+				switch decl := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							pkg.types[ts.Name.Name] = ts
+							pkg.files[ts.Name.Name] = f
+						}
+					}
+				case *ast.FuncDecl:
+					if decl.Recv != nil && decl.Name.Name == "UnmarshalText" && len(decl.Recv.List) == 1 {
+						recv := decl.Recv.List[0].Type
+						if star, ok := recv.(*ast.StarExpr); ok {
+							recv = star.X
+						}
+						if id, ok := recv.(*ast.Ident); ok {
+							pkg.text[id.Name] = true
+						}
+					}
+				}
+			}
+		}
+		if _, ok := pkg.types[typeName]; ok {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("no type %s in %s", typeName, dir)
+}
+
+// A scope says how variable names are derived within a nested struct, as
+// for envconf's "prefix" tag.
+type scope struct {
+	base string
+	from int
+}
+
+func (s scope) name(path []string) string {
+	return s.base + strings.ToUpper(strings.Join(path[s.from:], "_"))
+}
+
+// structFields writes the code reading the fields of st, which is declared
+// in file, into the struct at the Go expression dst. path holds the field
+// names used for variable names.
+func (g *generator) structFields(st *ast.StructType, file *ast.File, dst string, path []string, sc scope) error {
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return err
+			}
+			tag = reflect.StructTag(s)
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			// embedded
+			typ := field.Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			id, ok := typ.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("embedded field %s: only structs of the same package are supported", g.expr(field.Type))
+			}
+			if _, isPtr := field.Type.(*ast.StarExpr); isPtr {
+				return fmt.Errorf("embedded field %s: pointers are not supported", id.Name)
+			}
+			spec, ok := g.pkg.types[id.Name]
+			est, isStruct := resolveStruct(g.pkg, spec)
+			if !ok || !isStruct {
+				return fmt.Errorf("embedded field %s: not a struct", id.Name)
+			}
+			fieldScope := sc
+			if p, ok := tag.Lookup("prefix"); ok {
+				fieldScope = scope{strings.ToUpper(p), len(path)}
+			}
+			if err := g.structFields(est, g.pkg.files[id.Name], dst+"."+id.Name, path, fieldScope); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, name := range names {
+			if !name.IsExported() {
+				continue
+			}
+			fieldPath := append(path[:len(path):len(path)], name.Name)
+			if err := g.field(name.Name, field.Type, tag, file, dst+"."+name.Name, fieldPath, sc); err != nil {
+				return fmt.Errorf("field %s: %v", strings.Join(fieldPath, "."), err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveStruct returns the struct type spec defines, if it is one.
+func resolveStruct(pkg *pkgInfo, spec *ast.TypeSpec) (*ast.StructType, bool) {
+	if spec == nil || spec.TypeParams != nil || pkg.text[spec.Name.Name] {
+		return nil, false
+	}
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		return t, true
+	case *ast.Ident:
+		if spec.Assign != 0 {
+			return resolveStruct(pkg, pkg.types[t.Name])
+		}
+	}
+	return nil, false
+}
+
+// field writes the code reading one field.
+func (g *generator) field(name string, typ ast.Expr, tag reflect.StructTag, file *ast.File, dst string, path []string, sc scope) error {
+	for _, t := range runtimeTags {
+		if _, ok := tag.Lookup(t); ok {
+			return fmt.Errorf("the %q tag needs envconf's runtime; read this struct with envconf instead", t)
+		}
+	}
+	if tag.Get("json") == "true" {
+		return fmt.Errorf("the json:\"true\" tag needs envconf's runtime; read this struct with envconf instead")
+	}
+	if r := tag.Get("required"); len(r) > 0 && r != "true" && r != "false" {
+		return fmt.Errorf("invalid required tag %q", r)
+	}
+
+	// nested structs
+	var st *ast.StructType
+	switch t := typ.(type) {
+	case *ast.StructType:
+		st = t
+	case *ast.Ident:
+		if s, ok := resolveStruct(g.pkg, g.pkg.types[t.Name]); ok {
+			st, file = s, g.pkg.files[t.Name]
+		}
+	}
+	if st != nil {
+		if p, ok := tag.Lookup("prefix"); ok {
+			sc = scope{strings.ToUpper(p), len(path)}
+		}
+		return g.structFields(st, file, dst, path, sc)
+	}
+
+	elem, slice := typ, false
+	if arr, ok := typ.(*ast.ArrayType); ok && arr.Len == nil {
+		elem, slice = arr.Elt, true
+	}
+	k, err := g.kindOf(elem, file)
+	if err != nil {
+		return err
+	}
+
+	sep := ","
+	if s := tag.Get("separator"); len(s) > 0 {
+		sep = s
+	}
+	var oneof []string
+	if s := tag.Get("oneof"); len(s) > 0 {
+		oneof = strings.Split(s, ",")
+	}
+	def := tag.Get("default")
+	if strings.HasPrefix(def, "func:") {
+		return fmt.Errorf("the default %q needs envconf's runtime; read this struct with envconf instead", def)
+	}
+	if len(def) > 0 {
+		values := []string{def}
+		if slice {
+			values = strings.Split(def, sep)
+		}
+		for _, v := range values {
+			if err := checkDefault(k, v, oneof); err != nil {
+				return fmt.Errorf("invalid default %q: %v", def, err)
+			}
+		}
+	}
+
+	varName := g.prefix + sc.name(path)
+	if env := tag.Get("env"); len(env) > 0 {
+		varName = g.prefix + env
+	}
+	lookups := []string{varName}
+	if aliases := tag.Get("alias"); len(aliases) > 0 {
+		for _, a := range strings.Split(aliases, ",") {
+			lookups = append(lookups, g.prefix+strings.TrimSpace(a))
+		}
+	}
+	if from := tag.Get("defaultFrom"); len(from) > 0 {
+		lookups = append(lookups, g.prefix+from)
+	}
+
+	w := &g.buf
+	fmt.Fprintf(w, "\t// %s\n\t{\n", strings.Join(path, "."))
+	fmt.Fprintf(w, "\t\tv := getter(%q)\n", lookups[0])
+	for _, l := range lookups[1:] {
+		fmt.Fprintf(w, "\t\tif v == \"\" {\n\t\t\tv = getter(%q)\n\t\t}\n", l)
+	}
+	switch {
+	case tag.Get("required") == "true":
+		g.required = true
+		fmt.Fprintf(w, "\t\tif v == \"\" {\n\t\t\tmissing = append(missing, %q)\n\t\t} else {\n", varName)
+	case len(def) > 0:
+		fmt.Fprintf(w, "\t\tif v == \"\" {\n\t\t\tv = %q\n\t\t}\n\t\t{\n", def)
+	default:
+		fmt.Fprintf(w, "\t\tif v != \"\" {\n")
+	}
+
+	fieldName := path[len(path)-1]
+	if slice {
+		if err := g.use(typ, file); err != nil {
+			return err
+		}
+		g.imports["strings"] = "strings"
+		fmt.Fprintf(w, "\t\t\tparts := strings.Split(v, %q)\n", sep)
+		fmt.Fprintf(w, "\t\t\txs := make(%s, len(parts))\n", g.expr(typ))
+		fmt.Fprintf(w, "\t\t\tfor i, p := range parts {\n")
+		g.checkOneOf("p", fieldName, oneof)
+		g.parse(k, "p", "xs[i]", g.expr(elem), fieldName)
+		fmt.Fprintf(w, "\t\t\t}\n\t\t\t%s = xs\n", dst)
+	} else {
+		if k != kindText {
+			if err := g.use(typ, file); err != nil {
+				return err
+			}
+		}
+		g.checkOneOf("v", fieldName, oneof)
+		g.parse(k, "v", dst, g.expr(elem), fieldName)
+	}
+	fmt.Fprintf(w, "\t\t}\n\t}\n")
+	return nil
+}
+
+// kindOf returns how values of the type typ, used in file, are parsed.
+func (g *generator) kindOf(typ ast.Expr, file *ast.File) (kind, error) {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return kindString, nil
+		case "int":
+			return kindInt, nil
+		case "bool":
+			return kindBool, nil
+		case "float32":
+			return kindFloat32, nil
+		case "float64":
+			return kindFloat64, nil
+		}
+		if g.pkg.text[t.Name] {
+			return kindText, nil
+		}
+		if spec, ok := g.pkg.types[t.Name]; ok && spec.TypeParams == nil {
+			if _, ok := spec.Type.(*ast.Ident); ok {
+				return g.kindOf(spec.Type, g.pkg.files[t.Name])
+			}
+			if sel, ok := spec.Type.(*ast.SelectorExpr); ok && spec.Assign != 0 {
+				return g.kindOf(sel, g.pkg.files[t.Name])
+			}
+		}
+	case *ast.SelectorExpr:
+		pkgName, ok := t.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		path, ok := importOf(file, pkgName.Name)
+		if !ok {
+			return 0, fmt.Errorf("no import for %s", pkgName.Name)
+		}
+		if path == "time" && t.Sel.Name == "Duration" {
+			return kindDuration, nil
+		}
+		return kindText, nil
+	}
+	return 0, fmt.Errorf("unsupported type %s", g.expr(typ))
+}
+
+// use records the imports needed to refer to the type typ, used in file,
+// from the generated code.
+func (g *generator) use(typ ast.Expr, file *ast.File) error {
+	var err error
+	ast.Inspect(typ, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		if id, ok := sel.X.(*ast.Ident); ok {
+			if path, ok := importOf(file, id.Name); ok {
+				g.imports[id.Name] = path
+			} else {
+				err = fmt.Errorf("no import for %s", id.Name)
+			}
+		}
+		return false
+	})
+	return err
+}
+
+// importOf returns the path of the package imported as name in file. For
+// imports without an explicit name, the package name is assumed to be the
+// last element of the path.
+func importOf(file *ast.File, name string) (string, bool) {
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return p, true
+			}
+		} else if p == name || strings.HasSuffix(p, "/"+name) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// checkDefault parses a default value as the generated code would.
+func checkDefault(k kind, v string, oneof []string) error {
+	if len(oneof) > 0 {
+		found := false
+		for _, o := range oneof {
+			found = found || o == v
+		}
+		if !found {
+			return fmt.Errorf("must be one of %s", strings.Join(oneof, ", "))
+		}
+	}
+	var err error
+	switch k {
+	case kindInt:
+		_, err = strconv.ParseInt(v, 10, 0)
+	case kindBool:
+		_, err = strconv.ParseBool(v)
+	case kindFloat32:
+		_, err = strconv.ParseFloat(v, 32)
+	case kindFloat64:
+		_, err = strconv.ParseFloat(v, 64)
+	case kindDuration:
+		_, err = time.ParseDuration(v)
+	}
+	return err
+}
+
+// checkOneOf writes a check of the raw value v against oneof.
+func (g *generator) checkOneOf(v, fieldName string, oneof []string) {
+	if len(oneof) == 0 {
+		return
+	}
+	g.imports["fmt"] = "fmt"
+	quoted := make([]string, len(oneof))
+	for i, o := range oneof {
+		quoted[i] = strconv.Quote(o)
+	}
+	fmt.Fprintf(&g.buf, "\t\t\tswitch %s {\n\t\t\tcase %s:\n\t\t\tdefault:\n", v, strings.Join(quoted, ", "))
+	fmt.Fprintf(&g.buf, "\t\t\t\treturn c, fmt.Errorf(\"Invalid value for config field %s: %%q (must be one of %s)\", %s)\n",
+		fieldName, strings.Join(oneof, ", "), v)
+	fmt.Fprintf(&g.buf, "\t\t\t}\n")
+}
+
+// parse writes the code parsing the raw value v of kind k into dst, of the
+// type typ.
+func (g *generator) parse(k kind, v, dst, typ, fieldName string) {
+	w := &g.buf
+	fail := fmt.Sprintf("return c, fmt.Errorf(\"Invalid value for config field %s: %%v\", err)", fieldName)
+	if k != kindString {
+		g.imports["fmt"] = "fmt"
+	}
+	switch k {
+	case kindString:
+		fmt.Fprintf(w, "\t\t\t%s = %s(%s)\n", dst, typ, v)
+	case kindInt:
+		g.imports["strconv"] = "strconv"
+		fmt.Fprintf(w, "\t\t\tn, err := strconv.ParseInt(%s, 10, 0)\n\t\t\tif err != nil {\n\t\t\t\t%s\n\t\t\t}\n", v, fail)
+		fmt.Fprintf(w, "\t\t\t%s = %s(n)\n", dst, typ)
+	case kindBool:
+		g.imports["strconv"] = "strconv"
+		fmt.Fprintf(w, "\t\t\tb, err := strconv.ParseBool(%s)\n\t\t\tif err != nil {\n\t\t\t\t%s\n\t\t\t}\n", v, fail)
+		fmt.Fprintf(w, "\t\t\t%s = %s(b)\n", dst, typ)
+	case kindFloat32, kindFloat64:
+		g.imports["strconv"] = "strconv"
+		bits := 64
+		if k == kindFloat32 {
+			bits = 32
+		}
+		fmt.Fprintf(w, "\t\t\tf, err := strconv.ParseFloat(%s, %d)\n\t\t\tif err != nil {\n\t\t\t\t%s\n\t\t\t}\n", v, bits, fail)
+		fmt.Fprintf(w, "\t\t\t%s = %s(f)\n", dst, typ)
+	case kindDuration:
+		g.imports["time"] = "time"
+		fmt.Fprintf(w, "\t\t\td, err := time.ParseDuration(%s)\n\t\t\tif err != nil {\n\t\t\t\t%s\n\t\t\t}\n", v, fail)
+		fmt.Fprintf(w, "\t\t\t%s = %s(d)\n", dst, typ)
+	case kindText:
+		fmt.Fprintf(w, "\t\t\tif err := %s.UnmarshalText([]byte(%s)); err != nil {\n\t\t\t\t%s\n\t\t\t}\n", dst, v, fail)
+	}
+}
+
+// expr returns the source of e.
+func (g *generator) expr(e ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, g.pkg.fset, e)
+	return buf.String()
+}
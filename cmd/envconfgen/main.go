@@ -0,0 +1,74 @@
+/*
+Command envconfgen generates a function which reads a config struct from the
+environment without reflection, for programs which care about binary size
+and startup time. Given a struct type, it writes a function
+
+	func LoadConfig(getter func(string) string) (Config, error)
+
+which reads the same variables as envconf.ReadConfig and applies the same
+"required", "default", "defaultFrom", "env", "alias", "separator", "oneof"
+and "prefix" tags. Tags are checked when the code is generated: a default
+which doesn't parse as its field's type, or a tag which only envconf's
+runtime understands, such as "min" or "file", is an error, in which case the
+struct should be read with envconf instead.
+
+Run it with go generate from the package defining the struct:
+
+	//go:generate envconfgen -type Config -prefix MYAPP_
+
+Usage:
+
+	envconfgen -type T [-prefix P] [-o file] [dir]
+
+The struct is looked for in the package in dir, by default the current
+directory. The function is written to t_envconf.go beside it unless -o is
+given.
+
+Fields may be strings, ints, bools, float32s, float64s, time.Durations,
+types defined from those, types with an UnmarshalText method, slices of any
+of these, and nested and embedded structs. Types from other packages are
+assumed to implement encoding.TextUnmarshaler, which the compiler then
+checks.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeName := flag.String("type", "", "the struct `type` to generate a loader for")
+	prefix := flag.String("prefix", "", "the `prefix` of every variable name")
+	output := flag.String("o", "", "write the code to `file`")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: envconfgen -type T [-prefix P] [-o file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if len(*typeName) == 0 || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if len(*output) == 0 {
+		*output = filepath.Join(dir, strings.ToLower(*typeName)+"_envconf.go")
+	}
+
+	src, err := generate(dir, *typeName, *prefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "envconfgen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "envconfgen: %v\n", err)
+		os.Exit(1)
+	}
+}
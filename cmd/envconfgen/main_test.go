@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// testSource declares the struct for generation and a program printing the
+// result of its loader as JSON. Its types mirror those of TestGenerate.
+const testSource = `package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+	tm "time"
+)
+
+type Level string
+
+type Upper string
+
+func (u *Upper) UnmarshalText(b []byte) error {
+	*u = Upper(strings.ToUpper(string(b)))
+	return nil
+}
+
+type Common struct {
+	Debug bool
+}
+
+type DB struct {
+	Host string ` + "`required:\"true\"`" + `
+	Port int    ` + "`default:\"5432\"`" + `
+}
+
+type Config struct {
+	Common
+	Port     int           ` + "`required:\"true\"`" + `
+	Timeout  time.Duration ` + "`default:\"5s\"`" + `
+	Interval tm.Duration
+	Ratio    float64
+	Level    Level    ` + "`oneof:\"debug,info\" default:\"info\"`" + `
+	Name     Upper    ` + "`env:\"APP_NAME\" alias:\"SERVICE_NAME\"`" + `
+	Hosts    []string ` + "`separator:\";\"`" + `
+	Ports    []int
+	Replica  string ` + "`defaultFrom:\"PRIMARY\"`" + `
+	Primary  DB     ` + "`prefix:\"PRIMARY_DB_\"`" + `
+	Server   struct {
+		Addr string
+	}
+	hidden int
+}
+
+func main() {
+	env := map[string]string{}
+	json.Unmarshal([]byte(os.Args[1]), &env)
+	c, err := LoadConfig(func(k string) string { return env[k] })
+	if err != nil {
+		os.Stdout.WriteString("error: " + err.Error())
+		return
+	}
+	json.NewEncoder(os.Stdout).Encode(c)
+}
+`
+
+type testLevel string
+
+type testUpper string
+
+func (u *testUpper) UnmarshalText(b []byte) error {
+	*u = testUpper(strings.ToUpper(string(b)))
+	return nil
+}
+
+type testConfig struct {
+	Common struct {
+		Debug bool
+	}
+	Port     int           `required:"true"`
+	Timeout  time.Duration `default:"5s"`
+	Interval time.Duration
+	Ratio    float64
+	Level    testLevel `oneof:"debug,info" default:"info"`
+	Name     testUpper `env:"APP_NAME" alias:"SERVICE_NAME"`
+	Hosts    []string  `separator:";"`
+	Ports    []int
+	Replica  string `defaultFrom:"PRIMARY"`
+	Primary  struct {
+		Host string `required:"true"`
+		Port int    `default:"5432"`
+	} `prefix:"PRIMARY_DB_"`
+	Server struct {
+		Addr string
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(testSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(dir, "Config", "")
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config_envconf.go"), src, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []map[string]string{
+		{
+			"PORT": "8080", "DEBUG": "true", "TIMEOUT": "1m", "INTERVAL": "2s", "RATIO": "0.5",
+			"LEVEL": "debug", "SERVICE_NAME": "api", "HOSTS": "a;b", "PORTS": "1,2",
+			"PRIMARY": "db", "PRIMARY_DB_HOST": "primary", "SERVER_ADDR": ":80",
+		},
+		{"PORT": "1", "APP_NAME": "x", "SERVICE_NAME": "y", "REPLICA": "r", "PRIMARY_DB_HOST": "h", "PRIMARY_DB_PORT": "6432"},
+		{"PORT": "x", "PRIMARY_DB_HOST": "h"},
+		{"PORT": "1", "LEVEL": "warn", "PRIMARY_DB_HOST": "h"},
+		{"PORTS": "1,x"},
+		{},
+	}
+	for _, env := range tests {
+		arg, _ := json.Marshal(env)
+		cmd := exec.Command("go", "run", "main.go", "config_envconf.go", string(arg))
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GO111MODULE=off")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("go run: %v\n%s", err, out)
+			t.FailNow()
+		}
+
+		var conf testConfig
+		rerr := envconf.ReadConfigMap(&conf, env)
+		if strings.HasPrefix(string(out), "error: ") {
+			if rerr == nil {
+				t.Errorf("Load(%v): generated code failed with %s, but envconf succeeded", env, out)
+				t.Fail()
+			} else if strings.Contains(rerr.Error(), "Missing") && string(out) != "error: "+rerr.Error() {
+				t.Errorf("Load(%v): expected %q, got %q", env, rerr, out)
+				t.Fail()
+			}
+			continue
+		}
+		var generated testConfig
+		if err := json.Unmarshal(out, &generated); err != nil {
+			t.Fatal(err)
+		}
+		if rerr != nil || !reflect.DeepEqual(generated, conf) {
+			t.Errorf("Load(%v): expected %+v, %v, got %+v", env, conf, rerr, generated)
+			t.Fail()
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		src, errmatch string
+	}{
+		{"type Config struct { Port int `min:\"1\"` }", `field Port: the "min" tag needs envconf's runtime`},
+		{"type Config struct { Port int `default:\"x\"` }", `field Port: invalid default "x"`},
+		{"type Config struct { Level string `oneof:\"a,b\" default:\"c\"` }", `invalid default "c": must be one of a, b`},
+		{"type Config struct { Port *int }", "field Port: unsupported type *int"},
+		{"type Config struct { Key []byte }", "field Key: unsupported type byte"},
+		{"type Config struct { N int64 }", "field N: unsupported type int64"},
+		{"type Config int", "Config is not a non-generic struct type"},
+		{"type Other struct{}", "no type Config"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "config.go"), []byte("package config\n\n"+test.src+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := generate(dir, "Config", ""); err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("generate(%s): expected an error matching '%s', got '%v'", test.src, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
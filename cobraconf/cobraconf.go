@@ -0,0 +1,79 @@
+/*
+Package cobraconf binds envconf config structs to pflag flag sets and cobra
+commands, so that a CLI reads each setting from either a flag or the
+environment, with the flag taking precedence:
+
+	var conf struct {
+		Port int    `default:"8080" desc:"port to listen on"`
+		Bind string `desc:"address to bind"`
+	}
+	cmd := &cobra.Command{
+		Use: "serve",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(conf)
+		},
+	}
+	if err := cobraconf.Command(cmd, &conf); err != nil {
+		return err
+	}
+
+Here serve is run with conf read from --port and --bind, or PORT and BIND
+when the flags are not given. Flags are named and described as by
+envconf.BindFlags.
+
+The package lives apart from envconf so that only programs which use cobra
+depend on it.
+*/
+package cobraconf
+
+import (
+	"flag"
+
+	"github.com/ceralena/envconf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// BindPFlags registers a flag on fs for each field of conf, as by
+// envconf.BindFlags, and returns the Source serving the flags which were set.
+// opts configure the Decoder which names the flags' variables.
+func BindPFlags(fs *pflag.FlagSet, conf interface{}, opts ...envconf.Option) (*envconf.FlagSource, error) {
+	gofs := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	src, err := envconf.NewDecoder(opts...).BindFlags(gofs, conf)
+	if err != nil {
+		return nil, err
+	}
+	fs.AddGoFlagSet(gofs)
+	return src, nil
+}
+
+// Command registers a flag on cmd for each field of conf and sets cmd's
+// PreRunE to read conf before cmd is run. Each field is read from its flag
+// if it was given, and otherwise from the Decoder's source, the environment
+// unless opts say otherwise. Any PreRunE which cmd already had is called
+// once conf has been read.
+//
+// The Decoder selects fields with envconf.WithCommand(cmd.Name()), so that
+// subcommands sharing a config struct each have flags for, and require, only
+// the fields whose "cmd" tags name them and the fields without one.
+func Command(cmd *cobra.Command, conf interface{}, opts ...envconf.Option) error {
+	opts = append([]envconf.Option{envconf.WithCommand(cmd.Name())}, opts...)
+	flags, err := BindPFlags(cmd.Flags(), conf, opts...)
+	if err != nil {
+		return err
+	}
+
+	next := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		base := envconf.NewDecoder(opts...).Source()
+		layered := envconf.WithSource(envconf.LayerSources(flags, base))
+		if err := envconf.NewDecoder(append(opts[:len(opts):len(opts)], layered)...).Decode(conf); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(cmd, args)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package cobraconf
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+	"github.com/spf13/cobra"
+)
+
+type serveConfig struct {
+	Port   int  `default:"8080" desc:"port to listen on"`
+	Debug  bool `desc:"enable debug logging"`
+	Server struct {
+		Host string `required:"true"`
+	}
+}
+
+func newCommand(t *testing.T, conf *serveConfig, env envconf.MapSource, ran *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:  "serve",
+		RunE: func(cmd *cobra.Command, args []string) error { *ran = true; return nil },
+	}
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := Command(cmd, conf, envconf.WithSource(env)); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	return cmd
+}
+
+func TestCommand(t *testing.T) {
+	var conf serveConfig
+	var ran bool
+	env := envconf.MapSource{"PORT": "9090", "SERVER_HOST": "env.example.com"}
+	cmd := newCommand(t, &conf, env, &ran)
+
+	cmd.SetArgs([]string{"--debug", "--server-host", "flag.example.com"})
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !ran || conf.Port != 9090 || !conf.Debug || conf.Server.Host != "flag.example.com" {
+		t.Errorf("Expected flags over the environment, got %+v", conf)
+		t.Fail()
+	}
+
+	if usage := cmd.Flags().FlagUsages(); !strings.Contains(usage, "port to listen on (env PORT) (default 8080)") {
+		t.Errorf("Expected help text from the desc tag, got:\n%s", usage)
+		t.Fail()
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		args     []string
+		errmatch string
+	}{
+		{nil, "Missing config fields: SERVER_HOST"},
+		{[]string{"--port", "eighty"}, `invalid argument "eighty" for "--port"`},
+	}
+
+	for _, test := range tests {
+		var conf serveConfig
+		var ran bool
+		cmd := newCommand(t, &conf, envconf.MapSource{}, &ran)
+		cmd.SetArgs(test.args)
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Execute(%v): expected an error matching '%s', got '%v'", test.args, test.errmatch, err)
+			t.Fail()
+		}
+		if ran {
+			t.Errorf("Execute(%v): expected the command not to run", test.args)
+			t.Fail()
+		}
+	}
+}
+
+func TestCommandSubsets(t *testing.T) {
+	var conf struct {
+		Debug  bool
+		Server struct {
+			Port int `required:"true"`
+		} `cmd:"serve"`
+		DB struct {
+			URL string `required:"true"`
+		} `cmd:"serve,migrate"`
+	}
+	root := &cobra.Command{Use: "mytool"}
+	var ran []string
+	for _, name := range []string{"serve", "migrate"} {
+		cmd := &cobra.Command{
+			Use:  name,
+			RunE: func(cmd *cobra.Command, args []string) error { ran = append(ran, cmd.Name()); return nil },
+		}
+		if err := Command(cmd, &conf, envconf.WithSource(envconf.MapSource{})); err != nil {
+			t.Errorf("Unexpected error %v", err)
+			t.FailNow()
+		}
+		root.AddCommand(cmd)
+	}
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+
+	root.SetArgs([]string{"migrate", "--db-url", "postgres://db"})
+	if err := root.Execute(); err != nil || len(ran) != 1 {
+		t.Errorf("Expected migrate to run without server config, got %v", err)
+		t.Fail()
+	}
+
+	root.SetArgs([]string{"migrate", "--server-port", "80"})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "unknown flag: --server-port") {
+		t.Errorf("Expected migrate to have no server flags, got %v", err)
+		t.Fail()
+	}
+
+	root.SetArgs([]string{"serve", "--db-url", "postgres://db"})
+	match := "Missing config fields: SERVER_PORT"
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
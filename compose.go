@@ -0,0 +1,70 @@
+package envconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteCompose writes the variables of the schema to w as the environment
+// block of a Docker Compose service, passing each variable through from the
+// environment compose runs in:
+//
+//	environment:
+//	  PORT: "${PORT:-8080}"
+//	  DATABASE_URL: "${DATABASE_URL:?DATABASE_URL is required}"
+//
+// Defaults are filled in, except for those of secrets. Required variables
+// make compose fail when they are unset. The block can be pasted into a
+// compose file, or placed under a service by indenting it.
+func (s *Schema) WriteCompose(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("environment:\n")
+	for _, v := range s.Variables {
+		if len(v.Description) > 0 {
+			fmt.Fprintf(bw, "  # %s\n", strings.ReplaceAll(v.Description, "\n", " "))
+		}
+		if v.Prefix {
+			fmt.Fprintf(bw, "  # %s*: variables read by %s\n", v.Name, v.Type)
+			continue
+		}
+		fmt.Fprintf(bw, "  %s: %s\n", v.Name, strconv.Quote(composeValue(v)))
+	}
+	return bw.Flush()
+}
+
+// composeValue returns the interpolation compose is to make for v.
+func composeValue(v SchemaVariable) string {
+	def := v.Default
+	if v.Secret && def == Redacted {
+		def = ""
+	}
+	def = strings.ReplaceAll(def, "$", "$$")
+	if len(v.DefaultFrom) > 0 {
+		from := "${" + v.DefaultFrom + "}"
+		if len(def) > 0 {
+			from = "${" + v.DefaultFrom + ":-" + def + "}"
+		}
+		def = from
+	}
+
+	switch {
+	case len(def) > 0:
+		return "${" + v.Name + ":-" + def + "}"
+	case v.Required:
+		return "${" + v.Name + ":?" + v.Name + " is required}"
+	}
+	return "${" + v.Name + "}"
+}
+
+// WriteCompose writes the variables the Decoder reads for conf to w as the
+// environment block of a Docker Compose service; see Schema.WriteCompose.
+func (d *Decoder) WriteCompose(w io.Writer, conf interface{}) error {
+	s, err := d.Schema(conf)
+	if err != nil {
+		return err
+	}
+	return s.WriteCompose(w)
+}
@@ -0,0 +1,37 @@
+package envconf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCompose(t *testing.T) {
+	var conf struct {
+		Port     int    `default:"8080" desc:"port to listen on"`
+		URL      string `required:"true"`
+		Password string `secret:"true" default:"hunter2"`
+		Replica  string `defaultFrom:"PRIMARY" default:"localhost"`
+		Pattern  string `default:"$HOME/*.log"`
+		Cache    cacheConfig
+		Name     string
+	}
+	expect := "environment:\n" +
+		"  # port to listen on\n" +
+		"  APP_PORT: \"${APP_PORT:-8080}\"\n" +
+		"  APP_URL: \"${APP_URL:?APP_URL is required}\"\n" +
+		"  APP_PASSWORD: \"${APP_PASSWORD}\"\n" +
+		"  APP_REPLICA: \"${APP_REPLICA:-${APP_PRIMARY:-localhost}}\"\n" +
+		"  APP_PATTERN: \"${APP_PATTERN:-$$HOME/*.log}\"\n" +
+		"  # APP_CACHE_*: variables read by envconf.cacheConfig\n" +
+		"  APP_NAME: \"${APP_NAME}\"\n"
+
+	var buf bytes.Buffer
+	if err := NewDecoder(WithPrefix("APP_")).WriteCompose(&buf, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if buf.String() != expect {
+		t.Errorf("WriteCompose(): expected\n%s\ngot\n%s", expect, buf.String())
+		t.Fail()
+	}
+}
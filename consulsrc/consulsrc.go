@@ -0,0 +1,245 @@
+/*
+Package consulsrc provides an envconf Source backed by the key/value store of
+a Consul agent.
+
+Each variable is mapped to a key under a prefix, by default by lower-casing it
+and turning underscores into slashes, so that with the prefix "myapp/" the
+variable SERVER_PORT is read from the key myapp/server/port:
+
+	consul := consulsrc.New("myapp/")
+	err := envconf.NewDecoder(
+		envconf.WithSource(envconf.LayerSources(envconf.EnvSource{}, consul)),
+		envconf.WithPrefetcher(consul),
+	).Decode(&conf)
+
+Decode reads the whole prefix in one request, rather than one request per
+variable, when the source is the Decoder's own or is passed to WithPrefetcher
+as above.
+
+The source talks to Consul's HTTP API directly, so using it adds no
+dependencies. The agent's address and ACL token are taken from the
+CONSUL_HTTP_ADDR and CONSUL_HTTP_TOKEN variables, as by the consul command,
+unless set with options.
+*/
+package consulsrc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultAddr is the agent address used when none is configured.
+const DefaultAddr = "http://127.0.0.1:8500"
+
+// Source looks up variables in Consul's key/value store.
+type Source struct {
+	addr       string
+	prefix     string
+	token      string
+	datacenter string
+	client     *http.Client
+	keyFor     func(name string) string
+
+	mu      sync.Mutex
+	fetched map[string]string
+}
+
+// An Option configures a Source.
+type Option func(*Source)
+
+// WithAddr sets the address of the Consul agent, such as
+// "https://consul.internal:8501".
+func WithAddr(addr string) Option {
+	return func(s *Source) {
+		s.addr = addr
+	}
+}
+
+// WithToken sets the ACL token sent with each request.
+func WithToken(token string) Option {
+	return func(s *Source) {
+		s.token = token
+	}
+}
+
+// WithDatacenter reads from the given datacenter rather than the agent's
+// own.
+func WithDatacenter(dc string) Option {
+	return func(s *Source) {
+		s.datacenter = dc
+	}
+}
+
+// WithHTTPClient sets the client used for requests. The default is
+// http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Source) {
+		s.client = c
+	}
+}
+
+// WithKeyMapper sets how variable names are mapped to keys, relative to the
+// prefix.
+func WithKeyMapper(fn func(name string) string) Option {
+	return func(s *Source) {
+		s.keyFor = fn
+	}
+}
+
+// DefaultKeyMapper lower-cases name and turns underscores into slashes, so
+// SERVER_PORT becomes server/port.
+func DefaultKeyMapper(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "/")
+}
+
+// New returns a Source reading keys under prefix, which should usually end
+// in "/".
+func New(prefix string, opts ...Option) *Source {
+	s := &Source{
+		addr:   os.Getenv("CONSUL_HTTP_ADDR"),
+		token:  os.Getenv("CONSUL_HTTP_TOKEN"),
+		prefix: prefix,
+		client: http.DefaultClient,
+		keyFor: DefaultKeyMapper,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.addr) == 0 {
+		s.addr = DefaultAddr
+	} else if !strings.Contains(s.addr, "://") {
+		s.addr = "http://" + s.addr
+	}
+	s.addr = strings.TrimSuffix(s.addr, "/")
+	return s
+}
+
+// Key returns the Consul key name is read from.
+func (s *Source) Key(name string) string {
+	return s.prefix + s.keyFor(name)
+}
+
+// Lookup reads the key for name. A missing key is an unset variable.
+func (s *Source) Lookup(name string) (string, bool, error) {
+	return s.LookupContext(context.Background(), name)
+}
+
+// LookupContext is like Lookup, making its request with ctx.
+func (s *Source) LookupContext(ctx context.Context, name string) (string, bool, error) {
+	s.mu.Lock()
+	if s.fetched != nil {
+		v, ok := s.fetched[s.Key(name)]
+		s.mu.Unlock()
+		return v, ok, nil
+	}
+	s.mu.Unlock()
+
+	body, found, err := s.get(ctx, s.Key(name), url.Values{"raw": {""}})
+	if err != nil || !found {
+		return "", false, err
+	}
+	return string(body), true, nil
+}
+
+// Prefetch reads every key under the prefix in one request. Later lookups
+// are served from what was read, until Refresh is called.
+func (s *Source) Prefetch(names []string) error {
+	return s.PrefetchContext(context.Background(), names)
+}
+
+// PrefetchContext is like Prefetch, making its request with ctx.
+func (s *Source) PrefetchContext(ctx context.Context, names []string) error {
+	body, found, err := s.get(ctx, s.prefix, url.Values{"recurse": {""}})
+	if err != nil {
+		return err
+	}
+
+	fetched := make(map[string]string)
+	if found {
+		var pairs []struct {
+			Key   string
+			Value []byte
+		}
+		if err := json.Unmarshal(body, &pairs); err != nil {
+			return fmt.Errorf("Invalid response from consul: %v", err)
+		}
+		for _, p := range pairs {
+			fetched[p.Key] = string(p.Value)
+		}
+	}
+
+	s.mu.Lock()
+	s.fetched = fetched
+	s.mu.Unlock()
+	return nil
+}
+
+// Refresh discards the keys read by Prefetch, so that lookups go to Consul
+// again.
+func (s *Source) Refresh() {
+	s.mu.Lock()
+	s.fetched = nil
+	s.mu.Unlock()
+}
+
+// Ping checks that the agent is reachable and its cluster has a leader.
+func (s *Source) Ping(ctx context.Context) error {
+	resp, err := s.do(ctx, "/v1/status/leader")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul returned %s", resp.Status)
+	} else if strings.Trim(string(body), "\"\n") == "" {
+		return fmt.Errorf("consul has no leader")
+	}
+	return nil
+}
+
+// Name identifies the source in errors and reports.
+func (s *Source) Name() string {
+	return "consul(" + s.prefix + ")"
+}
+
+// get reads the key from the KV API. found is false if it does not exist.
+func (s *Source) get(ctx context.Context, key string, query url.Values) (body []byte, found bool, err error) {
+	if len(s.datacenter) > 0 {
+		query.Set("dc", s.datacenter)
+	}
+	resp, err := s.do(ctx, "/v1/kv/"+(&url.URL{Path: key}).EscapedPath()+"?"+query.Encode())
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err = io.ReadAll(resp.Body)
+		return body, err == nil, err
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("consul returned %s for key %s", resp.Status, key)
+	}
+}
+
+// do sends a GET request for path to the agent.
+func (s *Source) do(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.token) > 0 {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+	return s.client.Do(req)
+}
@@ -0,0 +1,151 @@
+package consulsrc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+// fakeConsul serves a KV store over a minimal copy of Consul's HTTP API.
+type fakeConsul struct {
+	kv       map[string]string
+	token    string
+	requests int
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests++
+	if r.Header.Get("X-Consul-Token") != f.token {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+		return
+	}
+	if r.URL.Path == "/v1/status/leader" {
+		fmt.Fprint(w, `"10.0.0.1:8300"`)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	if _, ok := r.URL.Query()["recurse"]; ok {
+		var pairs []string
+		for k, v := range f.kv {
+			if strings.HasPrefix(k, key) {
+				pairs = append(pairs, fmt.Sprintf(`{"Key": %q, "Value": %q}`,
+					k, base64.StdEncoding.EncodeToString([]byte(v))))
+			}
+		}
+		if len(pairs) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "["+strings.Join(pairs, ",")+"]")
+		return
+	}
+
+	if v, ok := f.kv[key]; ok {
+		fmt.Fprint(w, v)
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+type appConfig struct {
+	Server struct {
+		Host string `required:"true"`
+		Port int    `default:"80"`
+	}
+	Debug bool
+}
+
+func TestSource(t *testing.T) {
+	fake := &fakeConsul{
+		kv:    map[string]string{"myapp/server/host": "example.com", "myapp/server/port": "8080"},
+		token: "secret",
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	consul := New("myapp/", WithAddr(srv.URL), WithToken("secret"))
+	if consul.Key("SERVER_PORT") != "myapp/server/port" {
+		t.Errorf("Key(): got %q", consul.Key("SERVER_PORT"))
+		t.Fail()
+	}
+
+	var conf appConfig
+	if err := envconf.ReadConfigSource(&conf, consul); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Server.Host != "example.com" || conf.Server.Port != 8080 || conf.Debug {
+		t.Errorf("Unexpected config %+v", conf)
+		t.Fail()
+	}
+	if fake.requests != 1 {
+		t.Errorf("Expected one prefetch request, got %d", fake.requests)
+		t.Fail()
+	}
+
+	// Layered, each variable is read on its own unless the source is also
+	// the Prefetcher.
+	fake.requests = 0
+	fake.kv["myapp/debug"] = "true"
+	consul.Refresh()
+	layered := envconf.LayerSources(envconf.MapSource{"SERVER_PORT": "9090"}, consul)
+	if err := envconf.ReadConfigSource(&conf, layered); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !conf.Debug || conf.Server.Port != 9090 || fake.requests != 2 {
+		t.Errorf("Expected a request per variable, got %d and %+v", fake.requests, conf)
+		t.Fail()
+	}
+
+	fake.requests = 0
+	consul.Refresh()
+	d := envconf.NewDecoder(envconf.WithSource(layered), envconf.WithPrefetcher(consul))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if fake.requests != 1 {
+		t.Errorf("Expected one prefetch request, got %d", fake.requests)
+		t.Fail()
+	}
+
+	if err := consul.Ping(context.Background()); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	srv := httptest.NewServer(&fakeConsul{kv: map[string]string{}, token: "secret"})
+	defer srv.Close()
+
+	var conf appConfig
+	consul := New("myapp/", WithAddr(srv.URL), WithToken("wrong"))
+	err := envconf.ReadConfigSource(&conf, envconf.LayerSources(consul))
+	match := "consul returned 403 Forbidden for key myapp/server/host"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	consul = New("other/", WithAddr(srv.URL), WithToken("secret"),
+		WithKeyMapper(func(name string) string { return strings.ToLower(name) }))
+	if err := consul.Prefetch(nil); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+	err = envconf.ReadConfigSource(&conf, consul)
+	match = "Missing config fields: SERVER_HOST"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
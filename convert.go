@@ -0,0 +1,75 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A converter rewrites a raw value into the form expected by a field's
+// parser.
+type converter func(string) (string, error)
+
+// converters holds the conversions understood by the "convert" tag. They
+// exist so that legacy variables can keep their old format while the field
+// they populate moves to a better type.
+var converters = map[string]converter{
+	"ns->duration": unitConverter("ns"),
+	"us->duration": unitConverter("us"),
+	"ms->duration": unitConverter("ms"),
+	"s->duration":  unitConverter("s"),
+	"m->duration":  unitConverter("m"),
+	"h->duration":  unitConverter("h"),
+}
+
+// unitConverter returns a converter which turns a bare integer into a
+// duration string with this unit, e.g. "1500" into "1500ms".
+func unitConverter(unit string) converter {
+	return func(s string) (string, error) {
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return "", err
+		}
+		return s + unit, nil
+	}
+}
+
+// noConversion is the converter for fields without a "convert" tag.
+func noConversion(s string) (string, error) { return s, nil }
+
+// converterFor returns the converter named by field's "convert" tag.
+func converterFor(field reflect.StructField) (converter, error) {
+	spec := field.Tag.Get("convert")
+	if len(spec) == 0 {
+		return noConversion, nil
+	}
+	if conv, ok := converters[spec]; ok {
+		return conv, nil
+	}
+	return nil, fmt.Errorf(
+		"Unknown conversion for config field %s: %q", field.Name, spec)
+}
+
+// durationUnits holds the units of the duration conversions.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// formatConverted formats v, the value of a field with a "convert" tag, in
+// the format the conversion reads: the inverse of the field's converter.
+// Durations are truncated to the conversion's unit.
+func formatConverted(field reflect.StructField, v reflect.Value) (string, error) {
+	spec := field.Tag.Get("convert")
+	unit, ok := durationUnits[strings.TrimSuffix(spec, "->duration")]
+	if !ok || !strings.HasSuffix(spec, "->duration") || v.Type() != durationType {
+		return "", fmt.Errorf(
+			"Unknown conversion for config field %s: %q", field.Name, spec)
+	}
+	return strconv.FormatInt(v.Int()/int64(unit), 10), nil
+}
@@ -0,0 +1,69 @@
+package envconf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	var myConf struct {
+		Timeout  time.Duration   `convert:"ms->duration"`
+		Interval time.Duration   `convert:"s->duration" default:"30"`
+		Delays   []time.Duration `convert:"ms->duration"`
+		Deadline time.Duration
+	}
+	input := mapgetter{
+		"TIMEOUT":  "1500",
+		"DELAYS":   "10,20",
+		"DEADLINE": "2m",
+	}
+
+	if err := ReadConfig(&myConf, input.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if myConf.Timeout != 1500*time.Millisecond {
+		t.Errorf("Timeout: expected 1.5s, got %v", myConf.Timeout)
+		t.Fail()
+	}
+	if myConf.Interval != 30*time.Second {
+		t.Errorf("Interval: expected 30s, got %v", myConf.Interval)
+		t.Fail()
+	}
+	if len(myConf.Delays) != 2 || myConf.Delays[1] != 20*time.Millisecond {
+		t.Errorf("Delays: expected [10ms 20ms], got %v", myConf.Delays)
+		t.Fail()
+	}
+	if myConf.Deadline != 2*time.Minute {
+		t.Errorf("Deadline: expected 2m, got %v", myConf.Deadline)
+		t.Fail()
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	tests := []struct {
+		v        interface{}
+		errmatch string
+	}{
+		{
+			&struct {
+				Timeout time.Duration `convert:"ms->duration"`
+			}{}, "strconv.ParseInt: ",
+		},
+		{
+			&struct {
+				Timeout time.Duration `convert:"fortnights->duration"`
+			}{}, "Unknown conversion for config field Timeout",
+		},
+	}
+	input := mapgetter{"TIMEOUT": "1.5s"}
+
+	for _, test := range tests {
+		err := ReadConfig(test.v, input.get)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
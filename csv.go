@@ -0,0 +1,98 @@
+package envconf
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// WithCSV reads the elements of slices and maps as a line of CSV, as
+// encoding/csv does, so that an element holding the separator can be
+// quoted: "a,b",c is read as [a,b c], and a quote within a quoted element is
+// doubled. A field's "csv" tag, "true" or "false", takes precedence.
+//
+// With CSV the separator must be a single character. Write quotes elements
+// in the same way.
+func WithCSV() Option {
+	return func(d *Decoder) { d.csv = true }
+}
+
+// isCSV reports whether field's elements are read as CSV.
+func (d *Decoder) isCSV(field reflect.StructField) bool {
+	switch field.Tag.Get("csv") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return d.csv
+}
+
+// fieldSeparator returns the separator between the elements of field.
+func (d *Decoder) fieldSeparator(field reflect.StructField) string {
+	if s := field.Tag.Get("separator"); len(s) > 0 {
+		return s
+	}
+	return d.separator
+}
+
+// csvComma returns sep as the separator of a CSV line.
+func csvComma(field reflect.StructField, sep string) (rune, error) {
+	r, n := utf8.DecodeRuneInString(sep)
+	if n != len(sep) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf(
+			"Invalid separator %q for CSV config field %s: expected a single character", sep, field.Name)
+	}
+	return r, nil
+}
+
+// split splits input into the elements of field.
+func (d *Decoder) split(field reflect.StructField, input string) ([]string, error) {
+	sep := d.fieldSeparator(field)
+	if !d.isCSV(field) {
+		return strings.Split(input, sep), nil
+	}
+
+	comma, err := csvComma(field, sep)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(strings.NewReader(input))
+	r.Comma = comma
+	r.TrimLeadingSpace = d.isTrimmed(field)
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV for config field %s: %v", field.Name, err)
+	}
+	switch len(records) {
+	case 0:
+		return []string{""}, nil
+	case 1:
+		return records[0], nil
+	}
+	return nil, fmt.Errorf(
+		"Invalid CSV for config field %s: more than one line", field.Name)
+}
+
+// join joins the elements of field as split splits them.
+func (d *Decoder) join(field reflect.StructField, elems []string) (string, error) {
+	sep := d.fieldSeparator(field)
+	if !d.isCSV(field) {
+		return strings.Join(elems, sep), nil
+	}
+
+	comma, err := csvComma(field, sep)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Comma = comma
+	if err := w.Write(elems); err != nil {
+		return "", err
+	}
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n"), w.Error()
+}
@@ -0,0 +1,68 @@
+package envconf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	type config struct {
+		Greetings []string
+		Labels    map[string]string `separator:";"`
+		Hosts     []string          `csv:"false"`
+	}
+	src := MapSource{
+		"GREETINGS": `"hello, world",hi,"say ""hi"""`,
+		"LABELS":    `team=core;"note=a;b"`,
+		"HOSTS":     `"a",b`,
+	}
+	var conf config
+	if err := NewDecoder(WithSource(src), WithCSV()).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	expect := config{
+		Greetings: []string{"hello, world", "hi", `say "hi"`},
+		Labels:    map[string]string{"team": "core", "note": "a;b"},
+		Hosts:     []string{`"a"`, "b"},
+	}
+	if !reflect.DeepEqual(conf, expect) {
+		t.Errorf("Decode(): expected %+v, got %+v", expect, conf)
+		t.Fail()
+	}
+
+	env, err := NewDecoder(WithCSV()).Write(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	var back config
+	if err := NewDecoder(WithSource(MapSource(env)), WithCSV()).Decode(&back); err != nil || !reflect.DeepEqual(back, expect) {
+		t.Errorf("Write(): %v didn't read back, got %+v, %v", env, back, err)
+		t.Fail()
+	}
+
+	tests := []struct {
+		conf     interface{}
+		input    string
+		errmatch string
+	}{
+		{&struct {
+			Greetings []string `csv:"true"`
+		}{}, `"hello,world`, "Invalid CSV for config field Greetings (GREETINGS): "},
+		{&struct {
+			Greetings []string `csv:"true"`
+		}{}, "hello\nworld", "Invalid CSV for config field Greetings (GREETINGS): more than one line"},
+		{&struct {
+			Greetings []string `csv:"true" separator:", "`
+		}{}, "hello", `Invalid separator ", " for CSV config field Greetings (GREETINGS): expected a single character`},
+	}
+	for _, test := range tests {
+		err := NewDecoder(WithSource(MapSource{"GREETINGS": test.input})).Decode(test.conf)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
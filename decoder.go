@@ -0,0 +1,1008 @@
+package envconf
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An EnvDecoder is a config type which decodes itself. Libraries can
+// implement it to own how their config block is read while still being
+// embedded in an application's config struct.
+//
+// When a field's type implements EnvDecoder (with a value or pointer
+// receiver), DecodeEnv is called with the variable name prefix for that field
+// (for example "CACHE_" for a field named Cache) and a Getter backed by the
+// Decoder's Source. Errors are returned from Decode.
+type EnvDecoder interface {
+	DecodeEnv(prefix string, getter Getter) error
+}
+
+// A Decoder reads config into structs. The zero value is not usable; create
+// Decoders with NewDecoder.
+type Decoder struct {
+	source     Source
+	osenv      OSEnv
+	prefix     string
+	prefixSep  string
+	namer      Namer
+	separator  string
+	csv        bool
+	trim       bool
+	overwrite  OverwritePolicy
+	expand     bool
+	canon      map[reflect.Type]func(interface{}) interface{}
+	parsers    map[reflect.Type]Parser
+	errorMode  ErrorMode
+	blank      bool
+	fileSuffix string
+	foldCase   bool
+	command    string
+	analytics  func([]VariableUse)
+	unknown    UnknownMode
+	scanners   []SecretScanner
+	overrides  string
+	// DefaultFuncs registered with WithDefaultFunc
+	defaultFuncs map[string]DefaultFunc
+	// the order of variables in a Schema
+	exportOrder ExportOrder
+
+	secretMinLen     int
+	secretMinEntropy float64
+	// the options the Decoder was created with, for With
+	opts       []Option
+	warn       func(error)
+	prefetcher Prefetcher
+	tracer     Tracer
+	logger     func(Event)
+	debug      string
+	// the context of a DecodeContext, passed to ContextSources; nil for
+	// Decode
+	ctx context.Context
+}
+
+// An Option configures a Decoder.
+type Option func(*Decoder)
+
+// WithSource sets the Source used to look up variables. The default is
+// EnvSource, reading the environment set by WithOSEnv.
+func WithSource(src Source) Option {
+	return func(d *Decoder) { d.source = src }
+}
+
+// WithOSEnv sets the environment read by the default Source, so that a
+// Decoder can run on a platform whose environment isn't reached through
+// package os, or in a test without touching the process environment:
+//
+//	d := envconf.NewDecoder(envconf.WithOSEnv(envconf.MapEnv{"PORT": "8080"}))
+//
+// A Source set by WithSource is used as given.
+func WithOSEnv(env OSEnv) Option {
+	return func(d *Decoder) { d.osenv = env }
+}
+
+// WithGetter sets a Getter as the Decoder's Source.
+func WithGetter(getter Getter) Option {
+	return WithSource(getter)
+}
+
+// WithLookupFunc sets a LookupFunc as the Decoder's Source.
+func WithLookupFunc(fn LookupFunc) Option {
+	return WithSource(fn)
+}
+
+// WithPrefix sets a prefix which is prepended to every variable name. The
+// prefix is mapped by the Namer like a field name, so with DefaultNamer the
+// prefix "myserver_" gives MYSERVER_PORT.
+func WithPrefix(prefix string) Option {
+	return func(d *Decoder) { d.prefix = prefix }
+}
+
+// WithPrefixSeparator appends sep to the prefix unless it already ends in
+// it, so that with a separator of "_" the prefixes "MYSERVER" and
+// "MYSERVER_" both give MYSERVER_PORT.
+func WithPrefixSeparator(sep string) Option {
+	return func(d *Decoder) { d.prefixSep = sep }
+}
+
+// WithNamer sets the Namer used to derive variable names from fields. The
+// default is DefaultNamer.
+func WithNamer(namer Namer) Option {
+	return func(d *Decoder) { d.namer = namer }
+}
+
+// WithSeparator sets the delimiter used to split slice values. The default
+// is a comma. A field's "separator" tag takes precedence.
+func WithSeparator(sep string) Option {
+	return func(d *Decoder) { d.separator = sep }
+}
+
+// WithTrimSpace strips the spaces surrounding values, and surrounding each
+// element of slices and each key and value of maps, before they are parsed,
+// so that "1, 2, 3" is read as the ints 1, 2 and 3. Human-edited .env files
+// and CI settings often have stray spaces. A field's "trim" tag, "true" or
+// "false", takes precedence.
+func WithTrimSpace() Option {
+	return func(d *Decoder) { d.trim = true }
+}
+
+// WithExpand enables ${VAR} expansion in values and defaults. References are
+// resolved through the Decoder's Source without the prefix, so a default of
+// "${HOME}/data" composes with the rest of the environment. As with
+// os.Expand, $VAR is expanded too, and an unset variable expands to the empty
+// string.
+func WithExpand() Option {
+	return func(d *Decoder) { d.expand = true }
+}
+
+// An OverwritePolicy decides whether Decode replaces the values which the
+// fields of a config struct already hold.
+type OverwritePolicy int
+
+const (
+	// OverwriteAlways sets every field whose variable is set or which has a
+	// default, whatever it held before.
+	OverwriteAlways OverwritePolicy = iota
+	// OverwriteIfZero only sets fields which hold their zero value, so that
+	// the variables and defaults fill in the gaps of a struct pre-populated
+	// by the program.
+	OverwriteIfZero
+	// OverwriteIfSet replaces a non-zero value only with a variable which is
+	// set; defaults only apply to fields which hold their zero value. This
+	// layers the variables over values the program loaded first, such as
+	// from a config file.
+	OverwriteIfSet
+)
+
+// WithOverwrite sets how Decode treats fields which already hold a value.
+// The default is OverwriteAlways. With the other policies, a required field
+// which already holds a value is not missing.
+func WithOverwrite(policy OverwritePolicy) Option {
+	return func(d *Decoder) { d.overwrite = policy }
+}
+
+// WithFillOnly leaves alone fields which already hold a non-zero value when
+// Decode is called. It is short for WithOverwrite(OverwriteIfZero).
+func WithFillOnly() Option {
+	return WithOverwrite(OverwriteIfZero)
+}
+
+// WithBlankAsUnset treats values made only of spaces and tabs, as often left
+// by templated manifests, as unset rather than parsing them. Each such value
+// is reported to the warning func, and marked Blank in the decode Report.
+func WithBlankAsUnset() Option {
+	return func(d *Decoder) { d.blank = true }
+}
+
+// WithFileSuffix enables the convention of Docker and Compose secrets, where a
+// variable can instead be given as a path to a file holding its value: with
+// WithFileSuffix("_FILE"), if DB_PASSWORD is unset but DB_PASSWORD_FILE is set,
+// the contents of that file, less one trailing newline, are used. A file
+// which can't be read is a lookup error.
+func WithFileSuffix(suffix string) Option {
+	return func(d *Decoder) { d.fileSuffix = suffix }
+}
+
+// WithCommand selects the fields for a subcommand of a program with
+// several. Fields and nested structs with a "cmd" tag are only read if the
+// tag lists name, so that one config struct can serve every subcommand while
+// each only requires its own variables:
+//
+//	type Config struct {
+//		LogLevel string `default:"info"`
+//		Server   ServerConfig   `cmd:"serve"`
+//		DB       DatabaseConfig `cmd:"serve,migrate"`
+//	}
+//
+// Fields without a "cmd" tag are shared by every subcommand. Without
+// WithCommand, every field is read.
+func WithCommand(name string) Option {
+	return func(d *Decoder) { d.command = name }
+}
+
+// WithSecretStrength rejects values of fields with a "secret" tag which are
+// shorter than minLen bytes or have less than about minEntropy bits of
+// entropy, catching placeholders such as "changeme" before they reach
+// production. A field's own "minlen" and "minentropy" tags take precedence.
+// Zero disables a check. Secrets which are not strings or byte slices are not
+// checked.
+func WithSecretStrength(minLen int, minEntropy float64) Option {
+	return func(d *Decoder) {
+		d.secretMinLen, d.secretMinEntropy = minLen, minEntropy
+	}
+}
+
+// WithCanonicalizer registers a function applied to every parsed value of
+// type t, such as lower-casing hostnames or trimming trailing slashes from
+// URLs. It applies to fields of type t and to elements of slices of t. fn must
+// return a value of type t.
+func WithCanonicalizer(t reflect.Type, fn func(interface{}) interface{}) Option {
+	return func(d *Decoder) {
+		if d.canon == nil {
+			d.canon = make(map[reflect.Type]func(interface{}) interface{})
+		}
+		d.canon[t] = fn
+	}
+}
+
+// WithErrorMode sets how Decode handles invalid fields. The default is
+// FailFast.
+func WithErrorMode(mode ErrorMode) Option {
+	return func(d *Decoder) { d.errorMode = mode }
+}
+
+// WithWarningFunc sets the function which receives warnings, such as the
+// errors downgraded by CollectAllWithWarnings. By default warnings are written
+// with the standard log package.
+func WithWarningFunc(fn func(error)) Option {
+	return func(d *Decoder) { d.warn = fn }
+}
+
+// WithPrefetcher sets a Prefetcher which is told the variables Decode and
+// DecodeLazy are about to read. A Source which implements Prefetcher is used
+// as one automatically.
+func WithPrefetcher(p Prefetcher) Option {
+	return func(d *Decoder) { d.prefetcher = p }
+}
+
+// NewDecoder returns a Decoder configured with these options.
+func NewDecoder(opts ...Option) *Decoder {
+	d := &Decoder{
+		osenv:     ProcessEnv,
+		namer:     DefaultNamer,
+		separator: ",",
+		warn:      logWarning,
+		opts:      opts[:len(opts):len(opts)],
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.source == nil {
+		d.source = EnvSource{Env: d.osenv}
+	}
+	if p, ok := d.source.(Prefetcher); ok && d.prefetcher == nil {
+		d.prefetcher = p
+	}
+	return d
+}
+
+// With returns a new Decoder with the Decoder's options followed by opts,
+// so that later options override earlier ones. The Decoder is unchanged.
+func (d *Decoder) With(opts ...Option) *Decoder {
+	return NewDecoder(append(d.opts, opts...)...)
+}
+
+// Prefix returns the prefix prepended to variable names, as mapped by the
+// Namer and with any separator set by WithPrefixSeparator.
+func (d *Decoder) Prefix() string {
+	if len(d.prefix) == 0 {
+		return ""
+	}
+	p := d.namer.Name([]string{d.prefix})
+	if len(d.prefixSep) > 0 && !strings.HasSuffix(p, d.prefixSep) {
+		p += d.prefixSep
+	}
+	return p
+}
+
+// Source returns the Source the Decoder looks up variables in.
+func (d *Decoder) Source() Source {
+	return d.source
+}
+
+// Decode reads config into conf, which must be a struct or a pointer to a
+// struct.
+//
+// Fields of struct type are decoded recursively; the Namer is given the full
+// path of field names leading to each value. Embedded structs do not add to
+// the path.
+//
+// Fields tagged fetch:"lazy", and the fields of structs so tagged, are
+// skipped; see DecodeLazy.
+//
+// Once every field is set without error, PostLoad and then Validate are
+// called on each struct which implements PostLoader or Validator, innermost
+// first and finishing with conf itself. This is the place to check
+// invariants between fields.
+func (d *Decoder) Decode(conf interface{}) error {
+	return d.decode(conf, false)
+}
+
+// DecodeLazy reads only the fields of conf tagged fetch:"lazy". Together with
+// Decode, this lets a service start with the values it needs immediately and
+// resolve slow or rarely-used values afterwards, for example in a background
+// goroutine. Callers are responsible for synchronizing access to lazy fields
+// while DecodeLazy runs. The hooks of structs tagged fetch:"lazy" are called
+// by DecodeLazy rather than Decode.
+func (d *Decoder) DecodeLazy(conf interface{}) error {
+	return d.decode(conf, true)
+}
+
+// decode reads the fields of conf whose laziness matches lazy.
+func (d *Decoder) decode(conf interface{}, lazy bool) (err error) {
+	if d.tracer != nil {
+		var end func(error)
+		d, end = d.traced(conf, lazy)
+		defer func() { end(err) }()
+	}
+
+	plan, err := d.planType(reflect.TypeOf(conf))
+	if err != nil {
+		return err
+	}
+	if d, err = d.withOverrides(); err != nil {
+		return err
+	}
+	if d, err = d.withDebug(); err != nil {
+		return err
+	}
+
+	var fields []PlannedField
+	for _, pf := range plan {
+		if pf.Lazy == lazy {
+			fields = append(fields, pf)
+		}
+	}
+
+	if err := d.prefetch(fields); err != nil {
+		return err
+	}
+	defer d.sourceWarnings()
+
+	v := reflect.Indirect(reflect.ValueOf(conf))
+	var st decodeState
+	if d.analytics != nil {
+		defer func() { d.analytics(st.uses) }()
+	}
+
+	for _, pf := range fields {
+		if err := d.decodeField(v, pf, &st); err != nil {
+			if d.errorMode == FailFast {
+				return err
+			}
+			st.errs = d.collect(st.errs, pf.Field, err)
+		}
+	}
+
+	if err := d.checkConditions(v, &st); err != nil {
+		return err
+	}
+
+	if !lazy {
+		if err := d.checkUnknown(conf); err != nil {
+			if d.errorMode == FailFast {
+				return err
+			}
+			st.errs = append(st.errs, err)
+		}
+	}
+
+	if len(st.missing) > 0 {
+		err := fmt.Errorf(
+			"Missing config fields: %s", strings.Join(st.missing, ", "))
+		if d.errorMode == FailFast {
+			return err
+		}
+		st.errs = append(st.errs, err)
+	}
+
+	if len(st.errs) > 0 {
+		return st.errs
+	}
+
+	if err := d.postLoad(v, nil, lazy); err != nil {
+		return err
+	}
+
+	d.scanSecrets(v, fields)
+	return nil
+}
+
+// sourceWarnings passes the warnings of the Decoder's source, if it is a
+// Warner, to the warning func.
+func (d *Decoder) sourceWarnings() {
+	if w, ok := d.source.(Warner); ok {
+		for _, warning := range w.Warnings() {
+			d.warn(warning)
+		}
+	}
+}
+
+// decodeState holds the progress of one call to decode.
+type decodeState struct {
+	missing []string
+	errs    Errors
+	// unset fields with a required_if tag, checked once all fields are set
+	conditional []PlannedField
+	// the index of a Composite field set as a whole, whose own fields are
+	// skipped
+	skip []int
+	// the variables read, for WithAnalytics
+	uses []VariableUse
+	// whether the field being decoded had a blank variable, for WithAnalytics
+	blank bool
+}
+
+// decodeField reads the field described by pf into the config struct v.
+// Missing fields are recorded in st; other problems are returned.
+func (d *Decoder) decodeField(v reflect.Value, pf PlannedField, st *decodeState) error {
+	field := pf.labelled()
+	fieldVal, _ := fieldByIndex(v, pf.index, true)
+
+	if st.skip != nil && hasIndexPrefix(pf.index, st.skip) {
+		return nil
+	}
+
+	if d.overwrite == OverwriteIfZero && !fieldVal.IsZero() &&
+		!reflect.PtrTo(field.Type).Implements(lazyBinderType) {
+		if !pf.Composite && !pf.Indexed && !pf.SelfDecoding {
+			d.recordUse(st, pf, UseKept, "")
+		}
+		return nil
+	}
+
+	if pf.Composite {
+		input, err := d.get(pf.Name)
+		if err != nil || len(input) == 0 {
+			return err
+		}
+		st.skip = pf.index
+		d.recordLookup(st, pf, pf.Name)
+		// validated with the other structs by postLoad
+		return d.parse(field, fieldVal, input)
+	}
+
+	if pf.Indexed {
+		return d.decodeIndexed(fieldVal, pf, st)
+	}
+
+	if pf.SelfDecoding {
+		ed := fieldVal.Addr().Interface().(EnvDecoder)
+		if err := ed.DecodeEnv(pf.Name, d.getter); err != nil {
+			return fmt.Errorf("Config field %s: %v", field.Name, err)
+		}
+		return nil
+	}
+
+	if fieldVal.CanAddr() {
+		if lb, ok := fieldVal.Addr().Interface().(lazyBinder); ok {
+			lb.bindLazy(d.lazyResolver(pf))
+			return nil
+		}
+	}
+
+	input, from, blank, err := d.lookupBlank(pf)
+	if err != nil {
+		return err
+	}
+	st.blank = blank
+	defer func() { st.blank = false }()
+	d.recordLookup(st, pf, from)
+
+	if len(from) == 0 && d.overwrite == OverwriteIfSet && !fieldVal.IsZero() {
+		d.recordUse(st, pf, UseKept, "")
+		return nil
+	}
+
+	if len(from) == 0 && len(field.Tag.Get("required_if")) > 0 {
+		st.conditional = append(st.conditional, pf)
+	}
+
+	if len(from) == 0 && field.Tag.Get("required") == "true" {
+		st.missing = append(st.missing, pf.Name)
+		d.recordUse(st, pf, UseUnset, "")
+		return nil
+	} else if len(from) == 0 && len(field.Tag.Get("default")) > 0 {
+		if input, err = d.fieldDefault(field); err != nil {
+			return err
+		}
+		d.recordUse(st, pf, UseDefault, "")
+		if d.logger != nil {
+			d.logger(Event{Kind: EventDefault, Field: strings.Join(pf.Path, "."),
+				Variable: pf.Name, Value: redacted(pf, input)})
+		}
+	} else if len(from) == 0 {
+		d.recordUse(st, pf, UseUnset, "")
+		return nil
+	} else if len(input) == 0 {
+		// an empty value allowed by an allowempty tag
+		fieldVal.Set(reflect.Zero(field.Type))
+		return nil
+	}
+
+	if err := d.assign(field, fieldVal, input); err != nil {
+		if d.logger != nil {
+			d.logger(Event{Kind: EventParseError, Field: strings.Join(pf.Path, "."),
+				Variable: pf.Name, Err: err})
+		}
+		return err
+	}
+	return nil
+}
+
+// checkConditions records the fields of st.conditional, which are unset, as
+// missing if their required_if conditions hold in the struct v. With
+// FailFast, an invalid condition is returned; otherwise it is recorded in st.
+func (d *Decoder) checkConditions(v reflect.Value, st *decodeState) error {
+	for _, pf := range st.conditional {
+		parent, _ := fieldByIndex(v, pf.index[:len(pf.index)-1], true)
+		if required, err := requiredIf(parent, pf.labelled()); err != nil {
+			if d.errorMode == FailFast {
+				return err
+			}
+			st.errs = append(st.errs, err)
+		} else if required {
+			st.missing = append(st.missing, pf.Name)
+		}
+	}
+	return nil
+}
+
+// decodeIndexed reads the elements of the Indexed field pf into fieldVal,
+// stopping at the first index for which none of the element's variables
+// are set. If there are none, fieldVal is left as it is, and a required
+// field is missing.
+func (d *Decoder) decodeIndexed(fieldVal reflect.Value, pf PlannedField, st *decodeState) error {
+	elems := reflect.MakeSlice(pf.Field.Type, 0, 0)
+	for i := 0; ; i++ {
+		plan := d.elementPlan(pf, i)
+		if set, err := d.elementSet(plan, pf.Name+strconv.Itoa(i)+"_"); err != nil {
+			return err
+		} else if !set {
+			break
+		}
+
+		elem := reflect.New(pf.Field.Type.Elem()).Elem()
+		var est decodeState
+		for _, epf := range plan {
+			if err := d.decodeField(elem, epf, &est); err != nil {
+				if d.errorMode == FailFast {
+					return err
+				}
+				est.errs = d.collect(est.errs, epf.Field, err)
+			}
+		}
+		if err := d.checkConditions(elem, &est); err != nil {
+			return err
+		}
+		st.missing = append(st.missing, est.missing...)
+		st.errs = append(st.errs, est.errs...)
+		st.uses = append(st.uses, est.uses...)
+		elems = reflect.Append(elems, elem)
+	}
+
+	if elems.Len() > 0 {
+		fieldVal.Set(elems)
+	} else if pf.Field.Tag.Get("required") == "true" &&
+		(d.overwrite == OverwriteAlways || fieldVal.Len() == 0) {
+		st.missing = append(st.missing, pf.Name+"0_*")
+	}
+	return nil
+}
+
+// elementSet reports whether any variable of an element's plan with the
+// prefix base is set. Variables named outright, by "env" or "alias" tags, are
+// not numbered, and so don't count.
+func (d *Decoder) elementSet(plan []PlannedField, base string) (bool, error) {
+	for _, pf := range plan {
+		if pf.SelfDecoding || !strings.HasPrefix(pf.Name, base) {
+			continue
+		}
+		if pf.Indexed {
+			// a nested slice is set if its first element is
+			if set, err := d.elementSet(d.elementPlan(pf, 0), pf.Name+"0_"); err != nil || set {
+				return set, err
+			}
+			continue
+		}
+		if v, err := d.get(pf.Name); err != nil || len(v) > 0 {
+			return len(v) > 0, err
+		}
+	}
+	return false, nil
+}
+
+// hasIndexPrefix reports whether index starts with prefix.
+func hasIndexPrefix(index, prefix []int) bool {
+	if len(index) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if index[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get looks up name in the Decoder's Source, or in the file named by its
+// WithFileSuffix variable. An empty value is treated as unset, as is a blank
+// one with WithBlankAsUnset.
+func (d *Decoder) get(name string) (string, error) {
+	v, _, err := d.getSet(name)
+	return v, err
+}
+
+// getSet looks up name as get does, and also reports whether the variable is
+// set, even if it is empty, for fields tagged allowempty:"true".
+func (d *Decoder) getSet(name string) (string, bool, error) {
+	v, ok, _, err := d.getBlank(name)
+	return v, ok, err
+}
+
+// getBlank looks up name as getSet does, and also reports whether its value
+// was blank and so treated as unset because of WithBlankAsUnset.
+func (d *Decoder) getBlank(name string) (string, bool, bool, error) {
+	v, ok, err := lookupContext(d.ctx, d.source, name)
+	if err == nil && len(v) == 0 && d.foldCase {
+		var folded bool
+		if v, folded, err = d.lookupFold(name); folded {
+			ok = true
+		}
+	}
+	if err != nil {
+		return "", false, false, &LookupError{Name: name, Err: err}
+	}
+	if len(v) == 0 && len(d.fileSuffix) > 0 {
+		fv, err := d.getFile(name + d.fileSuffix)
+		if err != nil {
+			return "", false, false, err
+		} else if len(fv) > 0 {
+			v, ok = fv, true
+		}
+	}
+	if d.blank && len(v) > 0 && len(strings.Trim(v, " \t")) == 0 {
+		d.warn(fmt.Errorf("Treating blank value of %s as unset", name))
+		return "", false, true, nil
+	}
+	return v, ok || len(v) > 0, false, nil
+}
+
+// getFile reads the file named by the variable name, if it is set.
+func (d *Decoder) getFile(name string) (string, error) {
+	path, _, err := lookupContext(d.ctx, d.source, name)
+	if err != nil {
+		return "", &LookupError{Name: name, Err: err}
+	} else if len(path) == 0 {
+		return "", nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Reading %s failed: %v", name, err)
+	}
+	return trimNewline(string(b)), nil
+}
+
+// getter looks up name in the Decoder's Source, discarding any error. It
+// serves places which need a Getter, such as EnvDecoder.
+func (d *Decoder) getter(name string) string {
+	v, _ := d.get(name)
+	return v
+}
+
+// lookup returns the raw value for pf from its variable or, failing that,
+// from its aliases and then its defaultFrom variable, and the name of the
+// variable it was found in. from is empty if none is set. An empty variable
+// is only found for a field tagged allowempty:"true".
+func (d *Decoder) lookup(pf PlannedField) (input, from string, err error) {
+	input, from, _, err = d.lookupBlank(pf)
+	return input, from, err
+}
+
+// lookupBlank looks up pf as lookup does, and also reports whether any of
+// the variables it tried held a blank value treated as unset.
+func (d *Decoder) lookupBlank(pf PlannedField) (input, from string, blank bool, err error) {
+	allowEmpty := pf.Field.Tag.Get("allowempty") == "true"
+	for _, name := range pf.names() {
+		input, set, wasBlank, err := d.getBlank(name)
+		if err != nil {
+			return "", "", false, err
+		}
+		blank = blank || wasBlank
+		found := len(input) > 0 || (set && allowEmpty)
+		if d.logger != nil {
+			d.logLookup(pf, name, input, found)
+		}
+		if found {
+			return input, name, blank, nil
+		}
+	}
+	return "", "", blank, nil
+}
+
+// assign expands, parses and validates input, and stores it in fieldVal.
+// The value is only stored once it is valid, so that a field which fails is
+// left untouched.
+func (d *Decoder) assign(field reflect.StructField, fieldVal reflect.Value, input string) error {
+	v := reflect.New(fieldVal.Type()).Elem()
+	if err := d.parse(field, v, input); err != nil {
+		return err
+	}
+	if err := d.checkStrength(field, v); err != nil {
+		return err
+	}
+	if err := validateField(field, v); err != nil {
+		return err
+	}
+	fieldVal.Set(v)
+	return nil
+}
+
+// parse expands and parses input, and stores it in fieldVal. For fields
+// tagged file:"true", input is the path of a file holding the value.
+func (d *Decoder) parse(field reflect.StructField, fieldVal reflect.Value, input string) error {
+	if d.expand {
+		var err error
+		input = os.Expand(input, func(name string) string {
+			v, lerr := d.get(name)
+			if err == nil {
+				err = lerr
+			}
+			return v
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if field.Tag.Get("file") == "true" {
+		b, err := os.ReadFile(input)
+		if err != nil {
+			return fmt.Errorf("Reading file for config field %s failed: %v", field.Name, err)
+		}
+		if field.Type == bytesType {
+			fieldVal.SetBytes(b)
+			return nil
+		}
+		input = trimNewline(string(b))
+	}
+	if isJSONField(field) {
+		return d.setJSON(field, fieldVal, input)
+	}
+	return d.setField(field, fieldVal, input)
+}
+
+// collect records err, which occurred while decoding field, according to the
+// Decoder's ErrorMode. It returns the updated errs. A LookupError is never
+// downgraded to a warning, since the field's variable may well be set.
+func (d *Decoder) collect(errs Errors, field reflect.StructField, err error) Errors {
+	var lerr *LookupError
+	if d.errorMode == CollectAllWithWarnings && field.Tag.Get("required") != "true" && !errors.As(err, &lerr) {
+		d.warn(err)
+		return errs
+	}
+	return append(errs, err)
+}
+
+// requiredIf reports whether the condition in field's required_if tag holds.
+// The condition has the form "Field=value", where Field is another field of
+// the struct v and value is parsed as that field's type.
+func requiredIf(v reflect.Value, field reflect.StructField) (bool, error) {
+	cond := field.Tag.Get("required_if")
+	spl := strings.SplitN(cond, "=", 2)
+	if len(spl) != 2 {
+		return false, fmt.Errorf(
+			"Invalid required_if for config field %s: %q", field.Name, cond)
+	}
+
+	other := v.FieldByName(spl[0])
+	if !other.IsValid() {
+		return false, fmt.Errorf(
+			"Invalid required_if for config field %s: no field %s", field.Name, spl[0])
+	}
+
+	expect := reflect.New(other.Type()).Elem()
+	if err := setValue(expect, spl[1]); err != nil {
+		return false, fmt.Errorf(
+			"Invalid required_if for config field %s: %v", field.Name, err)
+	}
+
+	return reflect.DeepEqual(other.Interface(), expect.Interface()), nil
+}
+
+// setField parses input according to the type of field and stores it in
+// fieldVal. Slice values are split on the field's separator.
+func (d *Decoder) setField(field reflect.StructField, fieldVal reflect.Value, input string) error {
+	convert, err := converterFor(field)
+	if err != nil {
+		return err
+	}
+	trim := d.isTrimmed(field)
+	if trim {
+		input = strings.TrimSpace(input)
+	}
+
+	kind := field.Type.Kind()
+	if (kind != reflect.Slice && kind != reflect.Map) || isTextUnmarshaler(field.Type) || d.parses(field.Type) {
+		if err := checkInput(field, input); err != nil {
+			return err
+		}
+		if input, err = convert(input); err != nil {
+			return err
+		}
+		if err := checkRange(field, field.Type, input); err != nil {
+			return err
+		}
+		if err := d.setValue(fieldVal, input); err == errInvalidKind {
+			return fmt.Errorf(
+				"Invalid kind for config field %s: %v", field.Name, field.Type.Kind())
+		} else if err != nil {
+			return fmt.Errorf("Invalid value for config field %s: %v", field.Name, err)
+		}
+		return d.canonicalize(fieldVal)
+	}
+
+	spl, err := d.split(field, input)
+	if err != nil {
+		return err
+	}
+	if kind == reflect.Map {
+		return d.setMap(field, fieldVal, spl, convert)
+	}
+	sl := reflect.MakeSlice(field.Type, len(spl), len(spl))
+	for i, iv := range spl {
+		if trim {
+			iv = strings.TrimSpace(iv)
+		}
+		if err := d.setElem(field, sl.Index(i), iv, convert); err != nil {
+			return err
+		}
+	}
+	fieldVal.Set(sl)
+
+	return d.canonicalize(fieldVal)
+}
+
+// setMap parses entries of the form key=value into a new map for field.
+// Keys are parsed as the map's key type, and values as its element type
+// with the same checks as the elements of a slice.
+func (d *Decoder) setMap(field reflect.StructField, fieldVal reflect.Value, entries []string, convert converter) error {
+	m := reflect.MakeMapWithSize(field.Type, len(entries))
+	for _, entry := range entries {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf(
+				"Invalid entry %q for config field %s: expected key=value", entry, field.Name)
+		}
+		if d.isTrimmed(field) {
+			k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		}
+
+		key := reflect.New(field.Type.Key()).Elem()
+		if err := d.setValue(key, k); err == errInvalidKind {
+			return fmt.Errorf(
+				"Invalid kind for config field %s: %v", field.Name, field.Type)
+		} else if err != nil {
+			return fmt.Errorf("Invalid key for config field %s: %v", field.Name, err)
+		}
+		if m.MapIndex(key).IsValid() {
+			return fmt.Errorf("Duplicate key %q for config field %s", k, field.Name)
+		}
+
+		elem := reflect.New(field.Type.Elem()).Elem()
+		if err := d.setElem(field, elem, v, convert); err != nil {
+			return err
+		}
+		m.SetMapIndex(key, elem)
+	}
+	fieldVal.Set(m)
+
+	return d.canonicalize(fieldVal)
+}
+
+// setElem checks, converts and parses input into v, an element of the slice
+// or map field.
+func (d *Decoder) setElem(field reflect.StructField, v reflect.Value, input string, convert converter) error {
+	if err := checkInput(field, input); err != nil {
+		return err
+	}
+	input, err := convert(input)
+	if err != nil {
+		return err
+	}
+	if err := checkRange(field, v.Type(), input); err != nil {
+		return err
+	}
+	if err := d.setValue(v, input); err == errInvalidKind {
+		return fmt.Errorf(
+			"Invalid kind for config field %s: %v", field.Name, field.Type)
+	} else if err != nil {
+		return fmt.Errorf("Invalid value for config field %s: %v", field.Name, err)
+	}
+	return d.canonicalize(v)
+}
+
+// isTrimmed reports whether spaces are stripped from field's values.
+func (d *Decoder) isTrimmed(field reflect.StructField) bool {
+	switch field.Tag.Get("trim") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return d.trim
+}
+
+// canonicalize applies the canonicalizer registered for v's type, if any.
+func (d *Decoder) canonicalize(v reflect.Value) error {
+	fn, ok := d.canon[v.Type()]
+	if !ok {
+		return nil
+	}
+	cv := reflect.ValueOf(fn(v.Interface()))
+	if !cv.IsValid() || cv.Type() != v.Type() {
+		return fmt.Errorf(
+			"Canonicalizer for %v returned %v", v.Type(), cv.Kind())
+	}
+	v.Set(cv)
+	return nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	bytesType           = reflect.TypeOf([]byte(nil))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isTextUnmarshaler reports whether pointers to t implement
+// encoding.TextUnmarshaler.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// errInvalidKind is returned by setValue for types it can't parse.
+var errInvalidKind = errors.New("invalid kind")
+
+// setValue parses a single value into v.
+func setValue(v reflect.Value, input string) error {
+	if parse, ok := registeredParser(v.Type()); ok {
+		return setParsed(v, parse, input)
+	}
+
+	if v.CanAddr() {
+		if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(input))
+		}
+	}
+
+	if v.Type() == durationType {
+		if dur, err := time.ParseDuration(input); err != nil {
+			return err
+		} else {
+			v.SetInt(int64(dur))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	default:
+		return errInvalidKind
+	case reflect.String:
+		v.SetString(input)
+	case reflect.Int:
+		if i, err := strconv.ParseInt(input, 10, 0); err != nil {
+			return err
+		} else {
+			v.SetInt(i)
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(input); err != nil {
+			return err
+		} else {
+			v.SetBool(b)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(input, v.Type().Bits()); err != nil {
+			return err
+		} else {
+			v.SetFloat(f)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package envconf
+
+import "sync/atomic"
+
+var defaultDecoder atomic.Pointer[Decoder]
+
+func init() {
+	defaultDecoder.Store(NewDecoder())
+}
+
+// Default returns the program's default Decoder, as set by SetDefault, or
+// otherwise a Decoder with the default options. Libraries can read their
+// config with it rather than calling os.Getenv directly, so that the
+// application decides where config comes from:
+//
+//	var conf struct {
+//		Addr string `default:"localhost:6379"`
+//	}
+//	err := envconf.Default().With(envconf.WithPrefix("REDIS_")).Decode(&conf)
+//
+// Options passed to With override the application's, so a library's
+// WithPrefix replaces any prefix the application set.
+func Default() *Decoder {
+	return defaultDecoder.Load()
+}
+
+// SetDefault sets the Decoder returned by Default. Applications should call
+// it once at the start of main, before libraries read their config:
+//
+//	envconf.SetDefault(envconf.NewDecoder(
+//		envconf.WithSource(envconf.LayerSources(envconf.EnvSource{}, vault)),
+//	))
+//
+// SetDefault is safe for concurrent use with Default, but Decoders already
+// returned by Default are not changed.
+func SetDefault(d *Decoder) {
+	defaultDecoder.Store(d)
+}
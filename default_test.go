@@ -0,0 +1,35 @@
+package envconf
+
+import "testing"
+
+func TestDefault(t *testing.T) {
+	saved := Default()
+	defer SetDefault(saved)
+
+	SetDefault(NewDecoder(WithSource(MapSource{"REDIS_ADDR": "cache:6379", "PORT": "80"})))
+
+	var lib struct {
+		Addr string `default:"localhost:6379"`
+	}
+	if err := Default().With(WithPrefix("REDIS_")).Decode(&lib); err != nil || lib.Addr != "cache:6379" {
+		t.Errorf("Expected the library to read the application's source, got %+v, %v", lib, err)
+		t.Fail()
+	}
+
+	var app struct{ Port int }
+	if err := Default().Decode(&app); err != nil || app.Port != 80 {
+		t.Errorf("Expected With to leave the default Decoder unchanged, got %+v, %v", app, err)
+		t.Fail()
+	}
+}
+
+func TestDecoderWith(t *testing.T) {
+	env := MapEnv{"PORT": "8080"}
+	d := NewDecoder(WithPrefix("APP_")).With(WithOSEnv(env), WithPrefix(""))
+
+	var conf struct{ Port int }
+	if err := d.Decode(&conf); err != nil || conf.Port != 8080 {
+		t.Errorf("Expected the new options to apply, got %+v, %v", conf, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,85 @@
+package envconf
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A DefaultFunc provides the default value of fields tagged
+// default:"func:NAME", for defaults which can't be written down, such as the
+// machine's hostname. It is called each time such a field is unset.
+type DefaultFunc func() (string, error)
+
+// defaultFuncs are the DefaultFuncs available to every Decoder.
+var defaultFuncs = map[string]DefaultFunc{
+	"hostname": os.Hostname,
+	"freeport": freePort,
+	"uuid":     newUUID,
+}
+
+// WithDefaultFunc registers fn as the DefaultFunc called name, for fields
+// tagged default:"func:NAME". These are built in:
+//
+//   - hostname, the machine's hostname, from os.Hostname;
+//   - freeport, a TCP port which is free at the time of the call;
+//   - uuid, a random (version 4) UUID.
+//
+// A DefaultFunc registered with a built-in name replaces it.
+func WithDefaultFunc(name string, fn DefaultFunc) Option {
+	return func(d *Decoder) {
+		if d.defaultFuncs == nil {
+			d.defaultFuncs = make(map[string]DefaultFunc)
+		}
+		d.defaultFuncs[name] = fn
+	}
+}
+
+// fieldDefault returns the default for field: its "default" tag or, if the
+// tag has the form func:NAME, the value of the DefaultFunc NAME.
+func (d *Decoder) fieldDefault(field reflect.StructField) (string, error) {
+	def := field.Tag.Get("default")
+	name, ok := strings.CutPrefix(def, "func:")
+	if !ok {
+		return def, nil
+	}
+	fn, ok := d.defaultFuncs[name]
+	if !ok {
+		fn, ok = defaultFuncs[name]
+	}
+	if !ok {
+		return "", fmt.Errorf(
+			"Unknown default func for config field %s: %q", field.Name, name)
+	}
+	v, err := fn()
+	if err != nil {
+		return "", fmt.Errorf(
+			"Default func %s for config field %s failed: %v", name, field.Name, err)
+	}
+	return v, nil
+}
+
+// freePort returns a TCP port which the system has just shown to be free.
+func freePort() (string, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// newUUID returns a random UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,63 @@
+package envconf
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDefaultFunc(t *testing.T) {
+	var conf struct {
+		Host   string       `default:"func:hostname"`
+		Port   int          `default:"func:freeport"`
+		ID     string       `default:"func:uuid"`
+		Region string       `default:"func:region"`
+		Zone   Lazy[string] `default:"func:region"`
+		Name   string       `default:"func:hostname"`
+	}
+	d := NewDecoder(WithSource(MapSource{"NAME": "set"}),
+		WithDefaultFunc("region", func() (string, error) { return "eu-west-1", nil }))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	hostname, _ := os.Hostname()
+	if conf.Host != hostname || conf.Port <= 0 || conf.Region != "eu-west-1" || conf.Name != "set" {
+		t.Errorf("Decode(): unexpected %+v", conf)
+		t.Fail()
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(conf.ID) {
+		t.Errorf("Decode(): expected a random UUID, got %q", conf.ID)
+		t.Fail()
+	}
+	if zone, err := conf.Zone.Get(); err != nil || zone != "eu-west-1" {
+		t.Errorf("Lazy.Get(): expected eu-west-1, got %q, %v", zone, err)
+		t.Fail()
+	}
+
+	tests := []struct {
+		opts     []Option
+		errmatch string
+	}{
+		{nil, `Unknown default func for config field Region (REGION): "region"`},
+		{[]Option{WithDefaultFunc("region", func() (string, error) {
+			return "", errors.New("no metadata service")
+		})}, "Default func region for config field Region (REGION) failed: no metadata service"},
+		{[]Option{WithDefaultFunc("region", func() (string, error) { return "x", nil }),
+			WithDefaultFunc("freeport", func() (string, error) { return "http", nil })}, "strconv.ParseInt"},
+	}
+	for _, test := range tests {
+		var conf struct {
+			Port   int    `default:"func:freeport"`
+			Region string `default:"func:region"`
+		}
+		err := NewDecoder(append(test.opts, WithSource(MapSource{}))...).Decode(&conf)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DirSource is a Source serving a directory of files, one variable per file,
+// as Kubernetes mounts ConfigMaps and Secrets as volumes and Docker mounts
+// secrets. Each file is a variable named by upper-casing the file name,
+// replacing '-' and '.' with '_' and prepending a prefix; its contents, less
+// one trailing newline, are the value. Hidden files, such as the ..data link
+// Kubernetes manages, and directories are skipped.
+//
+// The files are read on the first lookup and cached until Refresh is called,
+// so a long-running process can pick up an updated ConfigMap by calling
+// Refresh before decoding again. A missing directory serves no values, so
+// the same code works outside of a cluster.
+type DirSource struct {
+	dir    string
+	prefix string
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// NewDirSource returns a DirSource serving the files in dir, with prefix
+// prepended to their variable names.
+func NewDirSource(dir, prefix string) *DirSource {
+	return &DirSource{dir: dir, prefix: prefix}
+}
+
+// Lookup returns the contents of the file for key.
+func (s *DirSource) Lookup(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		values, err := s.read()
+		if err != nil {
+			return "", false, err
+		}
+		s.values = values
+	}
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+// read reads every file in the directory.
+func (s *DirSource) read() (map[string]string, error) {
+	return readDir(s.dir, s.prefix)
+}
+
+// readDir reads every file in dir into a map of variables, named and read as
+// described for DirSource. A missing dir yields no values.
+func readDir(dir, prefix string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// follows links, as Kubernetes mounts each key as one
+		if info, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values[prefix+secretName(entry.Name())] = trimNewline(string(b))
+	}
+
+	return values, nil
+}
+
+// Refresh discards the files read, so that they are read again on the next
+// lookup.
+func (s *DirSource) Refresh() {
+	s.mu.Lock()
+	s.values = nil
+	s.mu.Unlock()
+}
+
+// Name identifies the source in errors and reports.
+func (s *DirSource) Name() string {
+	return "dir(" + s.dir + ")"
+}
@@ -0,0 +1,68 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirSource(t *testing.T) {
+	// laid out as Kubernetes mounts a ConfigMap
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2026_10_16_12_00_00.000000000")
+	if err := os.Mkdir(data, 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"db-host": "db.internal\n", "LOG_LEVEL": "debug"}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(data, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Base(data), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		if err := os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := NewDirSource(dir, "")
+	var conf struct {
+		DB  struct{ Host string }
+		Log struct{ Level string }
+	}
+	if err := ReadConfigSource(&conf, src); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.DB.Host != "db.internal" || conf.Log.Level != "debug" {
+		t.Errorf("Unexpected config %+v", conf)
+		t.Fail()
+	}
+	if _, ok, _ := src.Lookup("..DATA"); ok {
+		t.Errorf("Expected hidden entries to be skipped")
+		t.Fail()
+	}
+
+	// Updates are seen after Refresh.
+	if err := os.WriteFile(filepath.Join(data, "LOG_LEVEL"), []byte("info"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := src.Lookup("LOG_LEVEL"); v != "debug" {
+		t.Errorf("Expected the cached value before Refresh, got %q", v)
+		t.Fail()
+	}
+	src.Refresh()
+	if v, _, _ := src.Lookup("LOG_LEVEL"); v != "info" {
+		t.Errorf("Expected the new value after Refresh, got %q", v)
+		t.Fail()
+	}
+
+	src = NewDirSource(filepath.Join(dir, "missing"), "")
+	if _, ok, err := src.Lookup("LOG_LEVEL"); ok || err != nil {
+		t.Errorf("Expected a missing dir to serve nothing, got %v, %v", ok, err)
+		t.Fail()
+	}
+}
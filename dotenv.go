@@ -0,0 +1,164 @@
+package envconf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DotenvGetter returns a Getter serving the variables in the .env file at
+// path. The file is read once, when DotenvGetter is called; see ParseDotenv
+// for the syntax.
+func DotenvGetter(path string) (Getter, error) {
+	m, err := DotenvSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return mapgetter(m).get, nil
+}
+
+// DotenvSource is like DotenvGetter, but returns a Source which reports
+// variables set to the empty string as set.
+func DotenvSource(path string) (MapSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := ParseDotenv(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return MapSource(m), nil
+}
+
+// ReadConfigDotenv reads config from the .env file at path.
+func ReadConfigDotenv(conf interface{}, path string) error {
+	getter, err := DotenvGetter(path)
+	if err != nil {
+		return err
+	}
+	return ReadConfig(conf, getter)
+}
+
+// ParseDotenv parses a .env file. Each line holds a KEY=VALUE assignment,
+// optionally preceded by "export ". Blank lines and lines starting with # are
+// ignored.
+//
+// Unquoted values are trimmed, and a " #" starts a comment. Single-quoted
+// values are taken literally. Double-quoted values may span lines and
+// understand the escapes \n, \r, \t, \" and \\.
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string)
+	src := strings.Replace(string(b), "\r\n", "\n", -1)
+	lineno := 0
+
+	for len(src) > 0 {
+		var line string
+		if i := strings.IndexByte(src, '\n'); i >= 0 {
+			line, src = src[:i], src[i+1:]
+		} else {
+			line, src = src, ""
+		}
+		lineno++
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("Invalid dotenv line %d: %q", lineno, line)
+		}
+		key := strings.TrimSpace(line[:eq])
+		val := strings.TrimSpace(line[eq+1:])
+
+		switch {
+		case strings.HasPrefix(val, "'"):
+			end := strings.IndexByte(val[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated quote on dotenv line %d", lineno)
+			}
+			val = val[1 : end+1]
+		case strings.HasPrefix(val, `"`):
+			start := lineno
+			// the value may continue onto following lines
+			val = val[1:]
+			for {
+				if s, ok := unquoteDotenv(val); ok {
+					val = s
+					break
+				}
+				if len(src) == 0 {
+					return nil, fmt.Errorf("Unterminated quote on dotenv line %d", start)
+				}
+				var next string
+				if i := strings.IndexByte(src, '\n'); i >= 0 {
+					next, src = src[:i], src[i+1:]
+				} else {
+					next, src = src, ""
+				}
+				lineno++
+				val += "\n" + next
+			}
+		default:
+			if i := strings.Index(val, " #"); i >= 0 {
+				val = strings.TrimSpace(val[:i])
+			}
+		}
+
+		m[key] = val
+	}
+
+	return m, nil
+}
+
+// unquoteDotenv interprets the escapes in s up to its closing double quote.
+// ok is false if there is no closing quote.
+func unquoteDotenv(s string) (string, bool) {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return out.String(), true
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				out.WriteByte('\n')
+			case 'r':
+				out.WriteByte('\r')
+			case 't':
+				out.WriteByte('\t')
+			default:
+				out.WriteByte(s[i])
+			}
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return "", false
+}
+
+// quoteDotenv quotes a value, if needed, so that ParseDotenv reads it back
+// unchanged.
+func quoteDotenv(v string) string {
+	if !strings.ContainsAny(v, " \t\n\r#'\"\\") {
+		return v
+	}
+	if !strings.ContainsAny(v, "'\n\r") {
+		return "'" + v + "'"
+	}
+	return `"` + dotenvEscaper.Replace(v) + `"`
+}
+
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
@@ -0,0 +1,95 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDotenv = `# local development settings
+PORT=8080
+export HOST = localhost
+NAME=my app # trailing comment
+GREETING="hello \"world\"\n"
+LITERAL='no $expansion \n here'
+URL=http://example.com/#anchor
+
+CERT="-----BEGIN CERTIFICATE-----
+abc
+-----END CERTIFICATE-----"
+EMPTY=
+`
+
+func TestParseDotenv(t *testing.T) {
+	m, err := ParseDotenv(strings.NewReader(testDotenv))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	expect := map[string]string{
+		"PORT":     "8080",
+		"HOST":     "localhost",
+		"NAME":     "my app",
+		"GREETING": "hello \"world\"\n",
+		"LITERAL":  `no $expansion \n here`,
+		"URL":      "http://example.com/#anchor",
+		"CERT":     "-----BEGIN CERTIFICATE-----\nabc\n-----END CERTIFICATE-----",
+		"EMPTY":    "",
+	}
+	if len(m) != len(expect) {
+		t.Errorf("ParseDotenv(): expected %d variables, got %d: %v", len(expect), len(m), m)
+		t.Fail()
+	}
+	for k, v := range expect {
+		if m[k] != v {
+			t.Errorf("ParseDotenv(): %s: expected %q, got %q", k, v, m[k])
+			t.Fail()
+		}
+	}
+}
+
+func TestParseDotenvInvalid(t *testing.T) {
+	tests := []struct {
+		input    string
+		errmatch string
+	}{
+		{"PORT=1\nnonsense\n", "Invalid dotenv line 2"},
+		{"A='open\n", "Unterminated quote on dotenv line 1"},
+		{"A=1\nB=\"open\nstill open\n", "Unterminated quote on dotenv line 2"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseDotenv(strings.NewReader(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ParseDotenv(%q): expected an error matching '%s', got '%v'", test.input, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestReadConfigDotenv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(testDotenv), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var conf struct {
+		Port int
+		Host string
+	}
+	if err := ReadConfigDotenv(&conf, path); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 8080 || conf.Host != "localhost" {
+		t.Errorf("ReadConfigDotenv(): got %+v", conf)
+		t.Fail()
+	}
+
+	if err := ReadConfigDotenv(&conf, path+".missing"); err == nil {
+		t.Errorf("ReadConfigDotenv(): expected an error for a missing file")
+		t.Fail()
+	}
+}
@@ -0,0 +1,107 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Redacted replaces the values of secret fields in the output of Dump.
+const Redacted = "<redacted>"
+
+// Dump renders conf for logging at startup, as one NAME=value line per
+// variable read by a Decoder with the default options. The values of
+// fields with a "secret" tag are replaced by Redacted. Dump returns the
+// empty string if conf is not a struct or a pointer to a struct.
+func Dump(conf interface{}) string {
+	s, _ := NewDecoder().Dump(conf)
+	return s
+}
+
+// Dump renders conf for logging, with the variable names the Decoder
+// reads. Values are written as by Write, except that types implementing
+// fmt.Stringer are written with String, so that types which mask their own
+// secrets, such as preset.DSN, are shown masked. Secrets which are set are
+// replaced by Redacted. Values are quoted as in a .env file where needed.
+//
+// Fields whose types implement EnvDecoder are shown with a * after their
+// prefix, and Lazy fields as <lazy>; neither is resolved by Dump. The
+// elements of slices of structs are shown with their numbered variables.
+func (d *Decoder) Dump(conf interface{}) (string, error) {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := d.dump(&b, reflect.Indirect(reflect.ValueOf(conf)), plan); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// dump writes the fields of plan in the struct v to b.
+func (d *Decoder) dump(b *strings.Builder, v reflect.Value, plan []PlannedField) (err error) {
+	var skip []int
+	for _, pf := range plan {
+		if skip != nil && hasIndexPrefix(pf.index, skip) {
+			continue
+		}
+		field := pf.Field
+		fieldVal, ok := fieldByIndex(v, pf.index, false)
+		if !ok {
+			// behind a nil pointer
+			continue
+		}
+
+		var value string
+		switch {
+		case pf.SelfDecoding:
+			fmt.Fprintf(b, "%s*=<%v>\n", pf.Name, field.Type)
+			continue
+		case pf.Indexed:
+			for i := 0; i < fieldVal.Len(); i++ {
+				if err := d.dump(b, fieldVal.Index(i), d.elementPlan(pf, i)); err != nil {
+					return err
+				}
+			}
+			continue
+		case reflect.PtrTo(field.Type).Implements(lazyBinderType):
+			value = "<lazy>"
+		case isSecret(field):
+			if !fieldVal.IsZero() {
+				value = Redacted
+			}
+		case field.Type == bytesType:
+			value = fmt.Sprintf("<%d bytes>", fieldVal.Len())
+		default:
+			if s, ok := stringer(fieldVal); ok && (fieldVal.Kind() != reflect.Slice || isTextUnmarshaler(field.Type)) {
+				value = quoteDotenv(s.String())
+			} else if pf.Composite {
+				// dumped field by field
+				continue
+			} else if value, err = d.format(field, fieldVal); err != nil {
+				return err
+			} else {
+				value = quoteDotenv(value)
+			}
+		}
+		if pf.Composite {
+			skip = pf.index
+		}
+		fmt.Fprintf(b, "%s=%s\n", pf.Name, value)
+	}
+	return nil
+}
+
+// stringer returns v, or a pointer to it, as a fmt.Stringer.
+func stringer(v reflect.Value) (fmt.Stringer, bool) {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s, true
+	}
+	if v.CanAddr() {
+		s, ok := v.Addr().Interface().(fmt.Stringer)
+		return s, ok
+	}
+	return nil, false
+}
@@ -0,0 +1,61 @@
+package envconf
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// maskedURL masks itself when printed.
+type maskedURL struct{ url string }
+
+func (u *maskedURL) UnmarshalText(text []byte) error { u.url = string(text); return nil }
+func (u maskedURL) String() string                   { return "https://****@example.com" }
+
+func TestDump(t *testing.T) {
+	var conf struct {
+		Port     int
+		Name     string
+		Greeting string
+		Timeout  time.Duration
+		Bind     net.IP
+		Peers    []net.IP
+		Password string `secret:"true"`
+		APIKey   string `secret:"true"`
+		Cert     []byte
+		Window   window
+		Sentry   maskedURL
+		Cache    cacheConfig
+		Bucket   Lazy[string]
+	}
+	input := MapSource{
+		"PORT": "80", "NAME": "app", "GREETING": "hi there", "TIMEOUT": "5s",
+		"BIND": "10.0.0.1", "PEERS": "10.0.0.2,10.0.0.3", "PASSWORD": "hunter2",
+		"WINDOW": "9-17", "SENTRY": "https://key@example.com",
+	}
+	if err := ReadConfigSource(&conf, input); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	conf.Cert = []byte("-----BEGIN")
+
+	expect := `PORT=80
+NAME=app
+GREETING='hi there'
+TIMEOUT=5s
+BIND=10.0.0.1
+PEERS=10.0.0.2,10.0.0.3
+PASSWORD=<redacted>
+APIKEY=
+CERT=<10 bytes>
+WINDOW_START=9
+WINDOW_END=17
+SENTRY=https://****@example.com
+CACHE_*=<envconf.cacheConfig>
+BUCKET=<lazy>
+`
+	if s := Dump(&conf); s != expect {
+		t.Errorf("Dump(): expected\n%s\ngot\n%s", expect, s)
+		t.Fail()
+	}
+}
@@ -15,7 +15,7 @@ the global process state.
 envconf allows the package user to define a type matching the config
 variables they want to pull out of the environment.
 
-Usage
+# Usage
 
 Define a struct literal or an instance of a struct type and call ReadConfigEnv:
 
@@ -36,11 +36,14 @@ You can also set a prefix:
 
 This will behave in the same way as above, but will look for the environment
 variables MYSERVER_PORT and MYSERVER_BIND. This provides a simple way to
-namespace the environment variables.
+namespace the environment variables. The prefix is used as given, after
+upper-casing; a Decoder created with WithPrefixSeparator("_") also accepts
+"MYSERVER".
 
-Types
+# Types
 
-Three basic types are supported: int, bool and string. Slices of these types
+The basic types int, bool, string, float64 and time.Duration are supported,
+as is any type implementing encoding.TextUnmarshaler. Slices of these types
 are also supported; this struct is valid:
 
 	type AlarmConfig {
@@ -49,132 +52,162 @@ are also supported; this struct is valid:
 		Active     bool
 	}
 
-envconf expects comma-separated values for slice types.
+envconf expects comma-separated values for slice types; see WithSeparator,
+WithCSV and WithTrimSpace for other forms. Maps are read from key=value
+entries, delimited in the same way:
 
-Tags
+	Limits map[string]int // LIMITS=read=100,write=10
+
+Other types can be read once a parser is registered for them with
+RegisterParser. envconf also provides types for values which are awkward to
+pass through the environment, such as PEM, Certificates, PrivateKey and
+Expiring.
+
+# Nested structs
+
+Fields of struct type are decoded recursively. Their variable names are built
+from the full path of field names, so Server.Port is looked up as SERVER_PORT.
+Embedded structs do not add to the path.
+
+The "prefix" tag replaces the part of the names derived from the path up to
+and including a nested struct, so that one struct type can be read several
+times under different names:
+
+	type Config struct {
+		Primary DB `prefix:"PRIMARY_DB_"`
+		Replica DB `prefix:"REPLICA_DB_"`
+	}
+
+Here Primary.Host is read from PRIMARY_DB_HOST. The Decoder's prefix still
+applies, and the tag also sets the prefix passed to an EnvDecoder.
+
+Pointers to structs are read the same way, and Decode points them at new
+structs if they are nil, unless all of their fields are left out, as with the
+"cmd" tag. Embedded structs of unexported types have their exported fields
+read, as encoding/json does, but embedded pointers to them are ignored as they
+can't be set.
+
+Slices of structs are read from numbered variables: for a field Upstreams
+[]Upstream, the first element's Host is read from UPSTREAMS_0_HOST, the
+second's from UPSTREAMS_1_HOST, and so on up to the first number for which
+none of the element's variables are set. A required slice must have at least
+one element.
+
+A struct type which implements encoding.TextUnmarshaler can be set either as a
+whole from its own variable or field by field: if BACKOFF is set it is passed
+to UnmarshalText, otherwise BACKOFF_INITIAL, BACKOFF_MAX and so on are read.
+
+# Tags
 
 As seen above, envconf understands the "required" and "default" tags. These do
-what they sound like.
+what they sound like. A default of the form func:NAME is computed when it is
+needed; see WithDefaultFunc.
 
+The "env" tag replaces the variable name derived from the field, and the
+"alias" tag lists other names which are tried in order when it is unset:
 
-*/
-package envconf
+	DBURL string `env:"DATABASE_URL" alias:"DB_URL,POSTGRES_URL"`
 
-import (
-	"fmt"
-	"os"
-	"reflect"
-	"strconv"
-	"strings"
-)
+The "defaultFrom" tag names another variable to fall back to when the field's
+own variable is unset, ahead of any default. The Decoder's prefix applies to
+all three.
 
-// ReadConfig reads from this getter func into a struct.
-//
-// Must be passed a struct or a pointer to a struct.
-func ReadConfig(conf interface{}, getter func(string) string) error {
-	var (
-		v       = reflect.ValueOf(conf)
-		missing []string
-		err     error
-	)
+An empty variable is normally treated as unset. A field tagged
+allowempty:"true" is instead set to its zero value when its variable is set
+but empty, which satisfies "required" and takes precedence over "default",
+for settings where "" means "disabled". This needs a Source which can tell an
+empty variable from an unset one; it can't work through a Getter.
 
-	if v.Type().Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
+The "required_if" tag makes a field required only when another field of the
+same struct has a given value:
 
-	if v.Type().Kind() != reflect.Struct {
-		return fmt.Errorf(
-			"Invalid kind for config: %v", v.Type().Kind())
-	}
+	TLSCertFile string `required_if:"TLSEnabled=true"`
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Type().Field(i)
-		fieldVal := v.Field(i)
-		kind := field.Type.Kind()
-
-		input := getter(strings.ToUpper(field.Name))
-
-		if len(field.PkgPath) > 0 {
-			// ignore unexported
-			continue
-		} else if len(input) == 0 && field.Tag.Get("required") == "true" {
-			missing = append(missing, strings.ToUpper(field.Name))
-			continue
-		} else if defaul := field.Tag.Get("default"); len(input) == 0 && len(defaul) > 0 {
-			input = defaul
-		} else if len(input) == 0 {
-			continue
-		}
-
-		switch kind {
-		default:
-			return fmt.Errorf(
-				"Invalid kind for config field %s: %v", field.Name, kind)
-		case reflect.String:
-			fieldVal.Set(reflect.ValueOf(input))
-		case reflect.Int:
-			if i, err := strconv.Atoi(input); err != nil {
-				return err
-			} else {
-				fieldVal.Set(reflect.ValueOf(i))
-			}
-		case reflect.Bool:
-			if b, err := strconv.ParseBool(input); err != nil {
-				return err
-			} else {
-				fieldVal.SetBool(b)
-			}
-		case reflect.Slice:
-			// Complex case
-			spl := strings.Split(input, ",")
-			switch field.Type {
-			default:
-				return fmt.Errorf(
-					"Invalid kind for config field %s: %v", field.Name, field.Type)
-			case reflect.SliceOf(reflect.TypeOf("")):
-				sl := make([]string, len(spl))
-				for i, iv := range spl {
-					sl[i] = iv
-				}
-				fieldVal.Set(reflect.ValueOf(sl))
-			case reflect.SliceOf(reflect.TypeOf(1)):
-				sl := make([]int, len(spl))
-				for i, iv := range spl {
-					if intval, err := strconv.Atoi(iv); err != nil {
-						return err
-					} else {
-						sl[i] = intval
-					}
-				}
-				fieldVal.Set(reflect.ValueOf(sl))
-			case reflect.SliceOf(reflect.TypeOf(true)):
-				sl := make([]bool, len(spl))
-				for i, iv := range spl {
-					if bval, err := strconv.ParseBool(iv); err != nil {
-						return err
-					} else {
-						sl[i] = bval
-					}
-
-				}
-				fieldVal.Set(reflect.ValueOf(sl))
-			}
-		}
+The "convert" tag rewrites a value before it is parsed, so that a legacy
+variable can keep its old format; convert:"ms->duration" reads an int of
+milliseconds into a time.Duration, and likewise from ns, us, s, m and h.
 
-	}
+The "oneof", "pattern", "min" and "max" tags restrict the values a field
+accepts, and "minlen" and "minentropy" catch placeholder secrets such as
+"changeme":
 
-	if len(missing) > 0 {
-		err = fmt.Errorf(
-			"Missing config fields: %s", strings.Join(missing, ", "))
-	}
+	LogLevel   string `oneof:"debug,info,warn,error" default:"info"`
+	Bucket     string `pattern:"[a-z0-9][a-z0-9.-]{2,62}"`
+	Workers    int    `min:"1" max:"64"`
+	SigningKey string `secret:"true" minlen:"32"`
+
+Fields tagged secret:"true" are redacted wherever envconf shows values, and
+are left out of caches and snapshots.
+
+A field tagged json:"true" is set by unmarshalling its variable as JSON,
+whatever the field's type. As encoding/json reads the tag too, go vet reports
+a struct with more than one such field; a nested struct can hold the others.
+
+A field tagged file:"true" is read from the file whose path its variable
+holds; a []byte field holds the file as it is, and for other types one
+trailing newline is removed. A field tagged "deprecated" is still read, but
+setting its variable is reported to the warning func, with the tag's value as
+a hint. Fields tagged fetch:"lazy", and the fields of structs so tagged, are
+skipped by Decode and read by Decoder.DecodeLazy. The "desc" and "cmd" tags
+are described under BindFlags and WithCommand.
 
-	return err
+# Validation
+
+If a field's type implements Validator, its Validate method is called once
+the field is set. Once every field is set, structs implementing PostLoader or
+Validator have their hooks called, so that invariants between fields can be
+checked inside the call to ReadConfig. Structs implementing Warner can then
+report conditions which are allowed but deserve attention.
+
+Errors about a field name it by its full path and its variable, so that the
+fix is clear even when several nested structs share field names:
+
+	Invalid value for config field Server.TLS.Port (SERVER_TLS_PORT): ...
+
+# Decoders
+
+ReadConfig and friends cover the common cases. For more control, create a
+Decoder with options and call Decode:
+
+	d := envconf.NewDecoder(
+		envconf.WithPrefix("MYSERVER_"),
+		envconf.WithNamer(myNamer),
+		envconf.WithExpand(),
+	)
+	err := d.Decode(&serverConfig)
+
+A Namer decides how field paths map to variable names. WithExpand expands
+${VAR} references in values and defaults. Decoder.Resolve reports how each
+field got its value, and Usage, Decoder.Schema and WriteTemplate describe the
+variables a struct reads.
+
+# Sources
+
+Values come from a Source, which looks up variables by name and can report
+lookup failures as distinct from unset variables. The process environment is
+EnvSource; a map is MapSource; and any Getter is a Source too. Layers combine
+several sources in order of precedence, and DirSource, JSONSource and the
+flags of BindFlags are sources as well. Other formats and remote stores have
+subpackages of their own, so that their dependencies stay optional: yamlsrc,
+tomlsrc, consulsrc, etcdsrc, vaultsrc, ssmsrc, gcpsecretsrc, azkeyvaultsrc
+and socksrc.
+*/
+package envconf
+
+import "context"
+
+// ReadConfig reads from this getter func into a struct.
+//
+// Must be passed a struct or a pointer to a struct.
+func ReadConfig(conf interface{}, getter func(string) string) error {
+	return NewDecoder(WithGetter(getter)).Decode(conf)
 }
 
 // ReadConfigEnv reads config from the process environment. A shortcut for:
-//	envconf.ReadConfig(conf, os.GetEnv)
+//
+//	envconf.ReadConfigSource(conf, envconf.EnvSource{})
 func ReadConfigEnv(conf interface{}) error {
-	return ReadConfig(conf, os.Getenv)
+	return ReadConfigSource(conf, EnvSource{})
 }
 
 // a map wrapper for testing
@@ -184,14 +217,37 @@ func (t mapgetter) get(s string) string { return t[s] }
 
 // ReadConfigMap reads config from this map.
 func ReadConfigMap(conf interface{}, m map[string]string) error {
-	return ReadConfig(conf, mapgetter(m).get)
+	return ReadConfigSource(conf, MapSource(m))
+}
+
+// ReadConfigSource reads config from this Source.
+func ReadConfigSource(conf interface{}, src Source) error {
+	return NewDecoder(WithSource(src)).Decode(conf)
+}
+
+// ReadConfigReport reads config from this Source, and returns a Report of
+// how each field got its value: the variable and source it was read from, or
+// whether its default was used or it was left unset.
+func ReadConfigReport(conf interface{}, src Source) (Report, error) {
+	d := NewDecoder(WithSource(src))
+	res := d.Resolve(conf)
+	for _, w := range res.Warnings {
+		d.warn(w)
+	}
+	return res.Report, res.Err
+}
+
+// ReadConfigContext reads config from this Source, passing ctx to it if it
+// is a ContextSource or ContextPrefetcher so that lookups in a remote store
+// are cancelled with ctx or time out at its deadline. Unlike
+// Decoder.DecodeContext, it doesn't wait for the source to be reachable.
+func ReadConfigContext(ctx context.Context, conf interface{}, src Source) error {
+	return NewDecoder(WithSource(src)).withContext(ctx).Decode(conf)
 }
 
 // ReadConfigenvPrefix reads config from the environment with a set prefix on
-// every environment variable.
+// every environment variable. The prefix is upper-cased like field names, so
+// "myserver_" and "MYSERVER_" are equivalent.
 func ReadConfigEnvPrefix(prefix string, conf interface{}) error {
-	getter := func(k string) string {
-		return os.Getenv(fmt.Sprintf("%s%s", prefix, k))
-	}
-	return ReadConfig(conf, getter)
+	return NewDecoder(WithPrefix(prefix)).Decode(conf)
 }
@@ -2,9 +2,12 @@ package envconf
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestInvalidConfig(t *testing.T) {
@@ -16,13 +19,13 @@ func TestInvalidConfig(t *testing.T) {
 		{[]string{}, "Invalid kind for config: "},
 		{
 			struct {
-				M map[string]string `required:"true"`
+				M map[string]chan int `required:"true"`
 			}{
-				make(map[string]string),
+				make(map[string]chan int),
 			}, "Invalid kind for config field",
 		},
 	}
-	tm := mapgetter{"M": "hi"}
+	tm := mapgetter{"M": "hi=there"}
 
 	for _, test := range tests {
 		err := ReadConfig(test.v, tm.get)
@@ -107,7 +110,6 @@ func TestConfigDefaults(t *testing.T) {
 	}
 }
 
-
 func TestConfigMap(t *testing.T) {
 	var myConf struct {
 		K string
@@ -182,13 +184,13 @@ func TestConfigSlice(t *testing.T) {
 	}
 	for i, bv := range expectBools {
 		if ebv := myConf.Bools[i]; ebv != bv {
-			t.Errorf("Bools[%d]: expected %d, got %d", i, bv, ebv)
+			t.Errorf("Bools[%d]: expected %t, got %t", i, bv, ebv)
 			t.Fail()
 		}
 	}
 	for i, sv := range expectStrings {
 		if esv := myConf.Strings[i]; esv != sv {
-			t.Errorf("Strings[%d]: expected %d, got %d", i, sv, esv)
+			t.Errorf("Strings[%d]: expected %q, got %q", i, sv, esv)
 			t.Fail()
 		}
 	}
@@ -250,3 +252,840 @@ func ExampleReadConfigEnv() {
 	// hi
 	// yes
 }
+
+func TestConfigSliceSeparator(t *testing.T) {
+	var myConf struct {
+		DSNs  []string `separator:";"`
+		Ports []int
+	}
+	input := mapgetter{
+		"DSNS":  "postgres://a/db?sslmode=disable,x;postgres://b/db",
+		"PORTS": "80|443",
+	}
+
+	err := NewDecoder(WithGetter(input.get), WithSeparator("|")).Decode(&myConf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	expectDSNs := []string{"postgres://a/db?sslmode=disable,x", "postgres://b/db"}
+	if len(myConf.DSNs) != len(expectDSNs) {
+		t.Errorf("Wrong length for DSNs: wanted %d, got %d", len(expectDSNs), len(myConf.DSNs))
+		t.FailNow()
+	}
+	for i, sv := range expectDSNs {
+		if esv := myConf.DSNs[i]; esv != sv {
+			t.Errorf("DSNs[%d]: expected %q, got %q", i, sv, esv)
+			t.Fail()
+		}
+	}
+	if len(myConf.Ports) != 2 || myConf.Ports[0] != 80 || myConf.Ports[1] != 443 {
+		t.Errorf("Ports: expected [80 443], got %v", myConf.Ports)
+		t.Fail()
+	}
+}
+
+func TestConfigExpand(t *testing.T) {
+	var myConf struct {
+		DataDir string `default:"${HOME}/data"`
+		LogPath string
+		Literal string
+	}
+	input := mapgetter{
+		"HOME":        "/home/app",
+		"RUNTIME_DIR": "/run/app",
+		"LOGPATH":     "${RUNTIME_DIR}/app.log",
+		"LITERAL":     "$RUNTIME_DIR",
+	}
+
+	if err := ReadConfig(&myConf, input.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if myConf.LogPath != "${RUNTIME_DIR}/app.log" {
+		t.Errorf("ReadConfig(): expected no expansion by default, got %q", myConf.LogPath)
+		t.Fail()
+	}
+
+	if err := NewDecoder(WithGetter(input.get), WithExpand()).Decode(&myConf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	for _, test := range []struct{ got, expect string }{
+		{myConf.DataDir, "/home/app/data"},
+		{myConf.LogPath, "/run/app/app.log"},
+		{myConf.Literal, "/run/app"},
+	} {
+		if test.got != test.expect {
+			t.Errorf("Decode(): expected %q, got %q", test.expect, test.got)
+			t.Fail()
+		}
+	}
+}
+
+func TestConfigCanonicalizer(t *testing.T) {
+	type Hostname string
+	var myConf struct {
+		Host      Hostname
+		Peers     []Hostname
+		URL       string
+		Addresses []string
+	}
+	input := mapgetter{
+		"HOST":      "DB.Example.COM",
+		"PEERS":     "A.example.com,b.EXAMPLE.com",
+		"URL":       "http://example.com/",
+		"ADDRESSES": "http://a/,http://b/",
+	}
+	lower := func(v interface{}) interface{} {
+		return Hostname(strings.ToLower(string(v.(Hostname))))
+	}
+	trim := func(v interface{}) interface{} {
+		return strings.TrimSuffix(v.(string), "/")
+	}
+
+	d := NewDecoder(
+		WithGetter(input.get),
+		WithCanonicalizer(reflect.TypeOf(Hostname("")), lower),
+		WithCanonicalizer(reflect.TypeOf(""), trim),
+	)
+	if err := d.Decode(&myConf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if myConf.Host != "db.example.com" {
+		t.Errorf("Host: expected 'db.example.com', got %q", myConf.Host)
+		t.Fail()
+	}
+	if len(myConf.Peers) != 2 || myConf.Peers[0] != "a.example.com" || myConf.Peers[1] != "b.example.com" {
+		t.Errorf("Peers: expected lower-cased hostnames, got %v", myConf.Peers)
+		t.Fail()
+	}
+	if myConf.URL != "http://example.com" {
+		t.Errorf("URL: expected 'http://example.com', got %q", myConf.URL)
+		t.Fail()
+	}
+	if len(myConf.Addresses) != 2 || myConf.Addresses[0] != "http://a" || myConf.Addresses[1] != "http://b" {
+		t.Errorf("Addresses: expected trimmed URLs, got %v", myConf.Addresses)
+		t.Fail()
+	}
+
+	bad := NewDecoder(
+		WithGetter(input.get),
+		WithCanonicalizer(reflect.TypeOf(""), func(v interface{}) interface{} { return 1 }),
+	)
+	match := "Canonicalizer for string returned int"
+	if err := bad.Decode(&myConf); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Decode(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+// cacheConfig decodes itself, as a library-owned config type might.
+type cacheConfig struct {
+	Addrs []string
+	DB    int
+}
+
+func (c *cacheConfig) DecodeEnv(prefix string, getter Getter) error {
+	return NewDecoder(WithGetter(getter), WithPrefix(prefix+"REDIS_")).Decode(c)
+}
+
+func TestConfigEnvDecoder(t *testing.T) {
+	var myConf struct {
+		Cache cacheConfig
+		Port  int
+	}
+	input := mapgetter{
+		"APP_CACHE_REDIS_ADDRS": "a:6379,b:6379",
+		"APP_CACHE_REDIS_DB":    "2",
+		"APP_PORT":              "80",
+	}
+
+	if err := NewDecoder(WithGetter(input.get), WithPrefix("APP_")).Decode(&myConf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if len(myConf.Cache.Addrs) != 2 || myConf.Cache.DB != 2 {
+		t.Errorf("Cache: expected DecodeEnv to populate the field, got %+v", myConf.Cache)
+		t.Fail()
+	}
+	if myConf.Port != 80 {
+		t.Errorf("Port: expected 80, got %d", myConf.Port)
+		t.Fail()
+	}
+
+	input["APP_CACHE_REDIS_DB"] = "two"
+	match := "Config field Cache (APP_CACHE_*): Invalid value for config field DB (APP_CACHE_REDIS_DB): strconv.ParseInt: "
+	if err := NewDecoder(WithGetter(input.get), WithPrefix("APP_")).Decode(&myConf); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Decode(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestConfigDefaultFrom(t *testing.T) {
+	type DBConfig struct {
+		DBURL        string `required:"true"`
+		ReplicaDBURL string `defaultFrom:"DBURL" required:"true"`
+		Timeout      string `defaultFrom:"DEFAULT_TIMEOUT" default:"5s"`
+	}
+	tests := []struct {
+		vals                   mapgetter
+		expectReplica, timeout string
+	}{
+		{mapgetter{"APP_DBURL": "pg://main"}, "pg://main", "5s"},
+		{mapgetter{"APP_DBURL": "pg://main", "APP_REPLICADBURL": "pg://replica"}, "pg://replica", "5s"},
+		{mapgetter{"APP_DBURL": "pg://main", "APP_DEFAULT_TIMEOUT": "1s"}, "pg://main", "1s"},
+	}
+
+	for _, test := range tests {
+		var c DBConfig
+		if err := NewDecoder(WithGetter(test.vals.get), WithPrefix("APP_")).Decode(&c); err != nil {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+			continue
+		}
+		if c.ReplicaDBURL != test.expectReplica {
+			t.Errorf("ReplicaDBURL: expected %q, got %q", test.expectReplica, c.ReplicaDBURL)
+			t.Fail()
+		}
+		if c.Timeout != test.timeout {
+			t.Errorf("Timeout: expected %q, got %q", test.timeout, c.Timeout)
+			t.Fail()
+		}
+	}
+}
+
+func TestConfigRequiredIf(t *testing.T) {
+	type TLSConfig struct {
+		TLSCertFile string `required_if:"TLSEnabled=true"`
+		TLSKeyFile  string `required_if:"TLSEnabled=true"`
+		TLSEnabled  bool
+		Mode        string `default:"plain"`
+		Upstream    string `required_if:"Mode=proxy"`
+	}
+	tests := []struct {
+		vals     mapgetter
+		valid    bool
+		errmatch string
+	}{
+		{mapgetter{}, true, ""},
+		{mapgetter{"TLSENABLED": "false"}, true, ""},
+		{mapgetter{"TLSENABLED": "true", "TLSCERTFILE": "c", "TLSKEYFILE": "k"}, true, ""},
+		{mapgetter{"TLSENABLED": "true", "TLSCERTFILE": "c"}, false, "Missing config fields: TLSKEYFILE"},
+		{mapgetter{"TLSENABLED": "1"}, false, "Missing config fields: TLSCERTFILE, TLSKEYFILE"},
+		{mapgetter{"MODE": "proxy"}, false, "Missing config fields: UPSTREAM"},
+	}
+
+	for _, test := range tests {
+		c := TLSConfig{}
+		err := ReadConfig(&c, test.vals.get)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+	}
+
+	var bad struct {
+		Key string `required_if:"Enabled=true"`
+	}
+	match := "Invalid required_if for config field Key (KEY): no field Enabled"
+	if err := ReadConfig(&bad, mapgetter{}.get); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+// window is a composite config type: "9-17" or WINDOW_START and WINDOW_END.
+type window struct {
+	Start int
+	End   int `default:"24"`
+}
+
+func (w *window) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d-%d", &w.Start, &w.End)
+	return err
+}
+
+func TestConfigTextUnmarshaler(t *testing.T) {
+	var myConf struct {
+		Bind    net.IP
+		Peers   []net.IP
+		Ratio   float64
+		Window  window
+		Quiet   window
+		Maint   window
+		Unknown window
+	}
+	input := mapgetter{
+		"BIND":        "10.0.0.1",
+		"PEERS":       "10.0.0.2,10.0.0.3",
+		"RATIO":       "0.75",
+		"WINDOW":      "9-17",
+		"QUIET_START": "22",
+		"MAINT":       "1-3",
+		"MAINT_START": "5",
+	}
+
+	if err := ReadConfig(&myConf, input.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !myConf.Bind.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Bind: expected 10.0.0.1, got %v", myConf.Bind)
+		t.Fail()
+	}
+	if len(myConf.Peers) != 2 || !myConf.Peers[1].Equal(net.ParseIP("10.0.0.3")) {
+		t.Errorf("Peers: expected [10.0.0.2 10.0.0.3], got %v", myConf.Peers)
+		t.Fail()
+	}
+	if myConf.Ratio != 0.75 {
+		t.Errorf("Ratio: expected 0.75, got %v", myConf.Ratio)
+		t.Fail()
+	}
+	for _, test := range []struct {
+		name        string
+		got, expect window
+	}{
+		{"Window", myConf.Window, window{9, 17}},
+		{"Quiet", myConf.Quiet, window{22, 24}},
+		{"Maint", myConf.Maint, window{1, 3}},
+		{"Unknown", myConf.Unknown, window{0, 24}},
+	} {
+		if test.got != test.expect {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expect, test.got)
+			t.Fail()
+		}
+	}
+
+	if err := ReadConfig(&myConf, mapgetter{"BIND": "nope"}.get); err == nil {
+		t.Errorf("ReadConfig(): expected an error for an invalid IP")
+		t.Fail()
+	}
+}
+
+func TestConfigBlankAsUnset(t *testing.T) {
+	type MyConf struct {
+		Port  int    `default:"80"`
+		Name  string `required:"true"`
+		Label string
+	}
+	input := mapgetter{"PORT": "  ", "NAME": "app", "LABEL": " \t"}
+
+	var conf MyConf
+	if err := ReadConfig(&conf, input.get); err == nil {
+		t.Errorf("ReadConfig(): expected blank PORT to fail to parse")
+		t.Fail()
+	}
+
+	var warnings []error
+	d := NewDecoder(WithGetter(input.get), WithBlankAsUnset(),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }))
+	conf = MyConf{}
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 80 || conf.Label != "" {
+		t.Errorf("Expected blank values to be unset, got %+v", conf)
+		t.Fail()
+	}
+	if len(warnings) != 2 || warnings[0].Error() != "Treating blank value of PORT as unset" {
+		t.Errorf("Expected a warning per blank value, got %v", warnings)
+		t.Fail()
+	}
+
+	input["NAME"] = "\t"
+	match := "Missing config fields: NAME"
+	if err := d.Decode(&conf); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Decode(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestConfigFileSuffix(t *testing.T) {
+	type MyConf struct {
+		User     string `default:"app"`
+		Password string `required:"true"`
+	}
+	path := t.TempDir() + "/password"
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	input := MapSource{"USER_FILE": path, "PASSWORD_FILE": path}
+
+	var conf MyConf
+	match := "Missing config fields: PASSWORD"
+	if err := ReadConfigSource(&conf, input); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfigSource(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	d := NewDecoder(WithSource(input), WithFileSuffix("_FILE"))
+	input["USER"] = "admin"
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.User != "admin" || conf.Password != "hunter2" {
+		t.Errorf("Expected the password from its file, got %+v", conf)
+		t.Fail()
+	}
+
+	input["PASSWORD_FILE"] = path + ".missing"
+	match = "Reading PASSWORD_FILE failed: open " + path + ".missing"
+	if err := d.Decode(&conf); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Decode(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestConfigFileTag(t *testing.T) {
+	dir := t.TempDir()
+	cert := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	if err := os.WriteFile(dir+"/tls.crt", []byte(cert), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/token", []byte("abc123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/workers", []byte("8\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var conf struct {
+		TLSCert []byte `file:"true" required:"true"`
+		Token   string `file:"true"`
+		Workers int    `file:"true" default:"/nonexistent"`
+		Path    string
+	}
+	input := mapgetter{
+		"TLSCERT": dir + "/tls.crt",
+		"TOKEN":   dir + "/token",
+		"WORKERS": dir + "/workers",
+		"PATH":    dir + "/token",
+	}
+	if err := ReadConfig(&conf, input.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if string(conf.TLSCert) != cert || conf.Token != "abc123" || conf.Workers != 8 || conf.Path != dir+"/token" {
+		t.Errorf("Expected values from the files, got %+v", conf)
+		t.Fail()
+	}
+
+	delete(input, "WORKERS")
+	match := "Reading file for config field Workers (WORKERS) failed: open /nonexistent"
+	if err := ReadConfig(&conf, input.get); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestConfigEnvAndAlias(t *testing.T) {
+	type aliasConfig struct {
+		DBURL   string `env:"DATABASE_URL" alias:"DB_URL, POSTGRES_URL" required:"true"`
+		Replica string `alias:"READ_REPLICA" defaultFrom:"DATABASE_URL"`
+	}
+
+	tests := []struct {
+		input    map[string]string
+		expect   aliasConfig
+		errmatch string
+	}{
+		{
+			map[string]string{"APP_DATABASE_URL": "a", "APP_DB_URL": "b", "APP_POSTGRES_URL": "c"},
+			aliasConfig{"a", "a"}, "",
+		},
+		{
+			map[string]string{"APP_DB_URL": "b", "APP_POSTGRES_URL": "c", "APP_READ_REPLICA": "r"},
+			aliasConfig{"b", "r"}, "",
+		},
+		{
+			map[string]string{"APP_POSTGRES_URL": "c", "APP_REPLICA": "r", "APP_READ_REPLICA": "x"},
+			aliasConfig{"c", "r"}, "",
+		},
+		{
+			map[string]string{"APP_DBURL": "a"},
+			aliasConfig{}, "Missing config fields: APP_DATABASE_URL",
+		},
+	}
+	for _, test := range tests {
+		var conf aliasConfig
+		err := NewDecoder(WithPrefix("APP_"), WithSource(MapSource(test.input))).Decode(&conf)
+		if len(test.errmatch) > 0 {
+			if err == nil || err.Error() != test.errmatch {
+				t.Errorf("Decode(%v): expected an error matching '%s', got '%v'", test.input, test.errmatch, err)
+				t.Fail()
+			}
+			continue
+		}
+		if err != nil || conf != test.expect {
+			t.Errorf("Decode(%v): expected %+v, got %+v, %v", test.input, test.expect, conf, err)
+			t.Fail()
+		}
+	}
+
+	var uses []VariableUse
+	d := NewDecoder(WithSource(MapSource{"POSTGRES_URL": "c"}), WithAnalytics(func(u []VariableUse) { uses = u }))
+	if err := d.Decode(&aliasConfig{}); err != nil || uses[0].From != "POSTGRES_URL" || uses[0].Status != UseSet {
+		t.Errorf("Expected DBURL set from POSTGRES_URL, got %+v, %v", uses, err)
+		t.Fail()
+	}
+}
+
+type upstream struct {
+	Host   string `required:"true"`
+	Port   int    `default:"80"`
+	Routes []struct {
+		Path string
+	}
+}
+
+func (u upstream) Validate() error {
+	if u.Host == "localhost" && u.Port == 80 {
+		return fmt.Errorf("port 80 is taken locally")
+	}
+	return nil
+}
+
+func TestConfigIndexedSlice(t *testing.T) {
+	type config struct {
+		Upstreams []upstream
+		Tenants   []struct {
+			ID string
+		} `required:"true" prefix:"T_"`
+	}
+	env := map[string]string{
+		"UPSTREAMS_0_HOST":          "a",
+		"UPSTREAMS_0_ROUTES_0_PATH": "/x",
+		"UPSTREAMS_0_ROUTES_1_PATH": "/y",
+		"UPSTREAMS_1_PORT":          "8080",
+		"UPSTREAMS_1_HOST":          "b",
+		"UPSTREAMS_3_HOST":          "after a gap",
+		"T_0_ID":                    "t1",
+	}
+	var conf config
+	if err := ReadConfigMap(&conf, env); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if len(conf.Upstreams) != 2 || conf.Upstreams[0].Host != "a" || conf.Upstreams[0].Port != 80 ||
+		len(conf.Upstreams[0].Routes) != 2 || conf.Upstreams[0].Routes[1].Path != "/y" ||
+		conf.Upstreams[1].Host != "b" || conf.Upstreams[1].Port != 8080 || conf.Upstreams[1].Routes != nil {
+		t.Errorf("ReadConfigMap(): unexpected upstreams %+v", conf.Upstreams)
+		t.Fail()
+	}
+	if len(conf.Tenants) != 1 || conf.Tenants[0].ID != "t1" {
+		t.Errorf("ReadConfigMap(): unexpected tenants %+v", conf.Tenants)
+		t.Fail()
+	}
+
+	strict := NewDecoder(WithPrefix("APP_"), WithUnknown(RejectUnknown),
+		WithOSEnv(MapEnv{"APP_UPSTREAMS_0_HOST": "a", "APP_T_0_ID": "t"}))
+	if err := strict.Decode(&config{}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+
+	written, err := Write(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	var reread config
+	if err := ReadConfigMap(&reread, written); err != nil || !reflect.DeepEqual(reread, conf) {
+		t.Errorf("Write(): expected %+v to read back, got %+v, %v", conf, reread, err)
+		t.Fail()
+	}
+	if dump := Dump(&conf); !strings.Contains(dump, "UPSTREAMS_1_PORT=8080\n") {
+		t.Errorf("Dump(): expected the elements' variables, got\n%s", dump)
+		t.Fail()
+	}
+
+	tests := []struct {
+		env      map[string]string
+		errmatch string
+	}{
+		{map[string]string{"UPSTREAMS_0_HOST": "a"}, "Missing config fields: T_0_*"},
+		{map[string]string{"UPSTREAMS_0_PORT": "81", "T_0_ID": "t"}, "Missing config fields: UPSTREAMS_0_HOST"},
+		{map[string]string{"UPSTREAMS_0_HOST": "a", "UPSTREAMS_0_PORT": "x", "T_0_ID": "t"}, "strconv.ParseInt"},
+		{map[string]string{"UPSTREAMS_0_HOST": "localhost", "T_0_ID": "t"}, "Invalid config field Upstreams.0: port 80 is taken locally"},
+	}
+	for _, test := range tests {
+		var conf config
+		err := ReadConfigMap(&conf, test.env)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestConfigMapValues(t *testing.T) {
+	var conf struct {
+		Limits   map[string]int
+		Features map[string]bool `separator:";"`
+		Timeouts map[string]time.Duration
+		Weights  map[int]float64 `max:"1"`
+		Labels   map[string]string
+		Modes    map[string]string `oneof:"r,w"`
+	}
+	input := mapgetter{
+		"LIMITS":   "read=100,write=10",
+		"FEATURES": "beta=true;dark=false",
+		"TIMEOUTS": "dial=1s,read=250ms",
+		"WEIGHTS":  "1=0.5,2=0.25",
+		"LABELS":   "team=core,expr=a=b",
+	}
+	if err := ReadConfig(&conf, input.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	expect := []interface{}{
+		map[string]int{"read": 100, "write": 10},
+		map[string]bool{"beta": true, "dark": false},
+		map[string]time.Duration{"dial": time.Second, "read": 250 * time.Millisecond},
+		map[int]float64{1: 0.5, 2: 0.25},
+		map[string]string{"team": "core", "expr": "a=b"},
+	}
+	got := []interface{}{conf.Limits, conf.Features, conf.Timeouts, conf.Weights, conf.Labels}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("ReadConfig(): expected %v, got %v", expect, got)
+		t.Fail()
+	}
+
+	written, err := Write(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if written["LIMITS"] != "read=100,write=10" || written["FEATURES"] != "beta=true;dark=false" {
+		t.Errorf("Write(): expected sorted entries, got %v", written)
+		t.Fail()
+	}
+
+	tests := []struct {
+		env      mapgetter
+		errmatch string
+	}{
+		{mapgetter{"LIMITS": "read"}, `Invalid entry "read" for config field Limits (LIMITS): expected key=value`},
+		{mapgetter{"LIMITS": "read=1,read=2"}, `Duplicate key "read" for config field Limits`},
+		{mapgetter{"LIMITS": "read=x"}, "strconv.ParseInt"},
+		{mapgetter{"WEIGHTS": "x=1"}, "strconv.ParseInt"},
+		{mapgetter{"WEIGHTS": "1=2"}, "Weights"},
+		{mapgetter{"MODES": "a=x"}, "Modes"},
+	}
+	for _, test := range tests {
+		err := ReadConfig(&conf, test.env.get)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	type config struct {
+		Ports  []int
+		Limits map[string]int
+		Name   string
+		Greets []string `csv:"true"`
+		Raw    string   `trim:"false"`
+	}
+	src := MapSource{
+		"PORTS":  " 80, 443 ",
+		"LIMITS": "read = 100, write=10 ",
+		"NAME":   "\tapi \n",
+		"GREETS": `"hi, there", "bye"`,
+		"RAW":    " as is ",
+	}
+	var conf config
+	if err := NewDecoder(WithSource(src), WithTrimSpace()).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	expect := config{
+		Ports:  []int{80, 443},
+		Limits: map[string]int{"read": 100, "write": 10},
+		Name:   "api",
+		Greets: []string{"hi, there", "bye"},
+		Raw:    " as is ",
+	}
+	if !reflect.DeepEqual(conf, expect) {
+		t.Errorf("Decode(): expected %+v, got %+v", expect, conf)
+		t.Fail()
+	}
+
+	var tagged struct {
+		Ports []int `trim:"true"`
+		Other []int
+	}
+	err := NewDecoder(WithSource(MapSource{"PORTS": "80, 443", "OTHER": "80, 443"})).Decode(&tagged)
+	errmatch := `strconv.ParseInt: parsing " 443"`
+	if err == nil || !strings.Contains(err.Error(), errmatch) {
+		t.Errorf("expected an error matching '%s', got '%v'", errmatch, err)
+		t.Fail()
+	}
+	if !reflect.DeepEqual(tagged.Ports, []int{80, 443}) {
+		t.Errorf("Decode(): expected the tagged field to be trimmed, got %v", tagged.Ports)
+		t.Fail()
+	}
+}
+
+func TestAllowEmpty(t *testing.T) {
+	type config struct {
+		ProxyURL string   `default:"http://proxy:3128" allowempty:"true"`
+		Token    string   `required:"true" allowempty:"true"`
+		Peers    []string `default:"a,b" allowempty:"true"`
+		Region   string   `default:"eu-west-1"`
+		Unset    string   `default:"kept" allowempty:"true"`
+	}
+	src := MapSource{"PROXYURL": "", "TOKEN": "", "PEERS": "", "REGION": ""}
+	var conf config
+	if err := NewDecoder(WithSource(src)).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	expect := config{Region: "eu-west-1", Unset: "kept"}
+	if !reflect.DeepEqual(conf, expect) {
+		t.Errorf("Decode(): expected %+v, got %+v", expect, conf)
+		t.Fail()
+	}
+
+	// a Getter can't tell an empty variable from an unset one
+	getter := Getter(func(key string) string { return src[key] })
+	err := NewDecoder(WithSource(getter)).Decode(&conf)
+	errmatch := "Missing config fields: TOKEN"
+	if err == nil || !strings.Contains(err.Error(), errmatch) {
+		t.Errorf("expected an error matching '%s', got '%v'", errmatch, err)
+		t.Fail()
+	}
+}
+
+func TestFieldPathErrors(t *testing.T) {
+	type tls struct {
+		CertFile string `file:"true"`
+		Port     int
+	}
+	type config struct {
+		Server struct {
+			TLS tls
+		}
+		Admin struct {
+			TLS tls
+		}
+		Upstreams []upstream
+	}
+	tests := []struct {
+		src      MapSource
+		errmatch string
+	}{
+		{MapSource{"SERVER_TLS_CERTFILE": "/nonexistent"},
+			"Reading file for config field Server.TLS.CertFile (SERVER_TLS_CERTFILE) failed: open /nonexistent"},
+		{MapSource{"ADMIN_TLS_PORT": "https"},
+			`Invalid value for config field Admin.TLS.Port (ADMIN_TLS_PORT): strconv.ParseInt: parsing "https"`},
+		{MapSource{"UPSTREAMS_0_HOST": "a", "UPSTREAMS_1_HOST": "b", "UPSTREAMS_1_PORT": "x"},
+			"config field Upstreams.1.Port (UPSTREAMS_1_PORT): "},
+	}
+	for _, test := range tests {
+		var conf config
+		err := NewDecoder(WithSource(test.src)).Decode(&conf)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestFillOnly(t *testing.T) {
+	type config struct {
+		Host    string `required:"true"`
+		Port    int    `default:"80"`
+		Workers int    `default:"4"`
+		Debug   bool
+		Peers   []string
+		Limits  map[string]int
+	}
+	conf := config{Host: "localhost", Port: 8080, Peers: []string{"a"}}
+	src := MapSource{"HOST": "db", "PORT": "9090", "DEBUG": "true", "PEERS": "b,c", "LIMITS": "read=1"}
+	var uses []VariableUse
+	d := NewDecoder(WithSource(src), WithFillOnly(),
+		WithAnalytics(func(u []VariableUse) { uses = u }))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	expect := config{
+		Host:    "localhost",
+		Port:    8080,
+		Workers: 4,
+		Debug:   true,
+		Peers:   []string{"a"},
+		Limits:  map[string]int{"read": 1},
+	}
+	if !reflect.DeepEqual(conf, expect) {
+		t.Errorf("Decode(): expected %+v, got %+v", expect, conf)
+		t.Fail()
+	}
+	if len(uses) != 6 || uses[0].Status != UseKept || uses[2].Status != UseDefault {
+		t.Errorf("Expected Host to be kept, got %+v", uses)
+		t.Fail()
+	}
+}
+
+func TestOverwritePolicy(t *testing.T) {
+	type config struct {
+		Host    string `required:"true"`
+		Port    int    `default:"80"`
+		Workers int    `default:"4"`
+		Debug   bool
+		Name    string
+	}
+	preset := config{Host: "localhost", Port: 8080, Name: "from-file"}
+	src := MapSource{"PORT": "9090", "DEBUG": "true"}
+	tests := []struct {
+		policy OverwritePolicy
+		expect config
+	}{
+		{OverwriteAlways, config{Host: "localhost", Port: 9090, Workers: 4, Debug: true, Name: "from-file"}},
+		{OverwriteIfZero, config{Host: "localhost", Port: 8080, Workers: 4, Debug: true, Name: "from-file"}},
+		{OverwriteIfSet, config{Host: "localhost", Port: 9090, Workers: 4, Debug: true, Name: "from-file"}},
+	}
+	for _, test := range tests {
+		conf := preset
+		err := NewDecoder(WithSource(src), WithOverwrite(test.policy)).Decode(&conf)
+		if test.policy == OverwriteAlways {
+			// the preset Host doesn't satisfy required
+			if err == nil || err.Error() != "Missing config fields: HOST" {
+				t.Errorf("expected an error matching '%s', got '%v'", "Missing config fields: HOST", err)
+				t.Fail()
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error %v", err)
+			t.FailNow()
+		}
+		if !reflect.DeepEqual(conf, test.expect) {
+			t.Errorf("Decode() with policy %d: expected %+v, got %+v", test.policy, test.expect, conf)
+			t.Fail()
+		}
+	}
+
+	// defaults only fill zero values with OverwriteIfSet
+	conf := config{Host: "localhost", Workers: 16}
+	if err := NewDecoder(WithSource(MapSource{}), WithOverwrite(OverwriteIfSet)).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Workers != 16 || conf.Port != 80 {
+		t.Errorf("Decode(): expected Workers kept and Port defaulted, got %+v", conf)
+		t.Fail()
+	}
+}
@@ -0,0 +1,160 @@
+package envconftest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// ErrInjected is the error returned by failures injected with Fail when no
+// other error is given.
+var ErrInjected = errors.New("injected failure")
+
+// Chaos wraps a Source and injects failures on demand: variables which go
+// missing, slow lookups and transient errors. It lets tests exercise a
+// program's handling of an unreliable config store at startup and reload.
+//
+//	src := envconftest.NewChaos(envconf.MapSource{"DB_URL": "postgres://"})
+//	src.Fail(nil, 2, "DB_URL")
+//	// The next two lookups of DB_URL fail with ErrInjected.
+//
+// Rules given no keys apply to every variable. A Chaos is safe for concurrent
+// use.
+type Chaos struct {
+	src envconf.Source
+
+	mu      sync.Mutex
+	missing map[string]bool
+	delays  []chaosDelay
+	fails   []*chaosFail
+	lookups int
+}
+
+type chaosDelay struct {
+	d    time.Duration
+	keys map[string]bool
+}
+
+type chaosFail struct {
+	err  error
+	n    int
+	keys map[string]bool
+}
+
+// NewChaos returns a Chaos wrapping src, which injects no failures until
+// told to.
+func NewChaos(src envconf.Source) *Chaos {
+	return &Chaos{src: src, missing: make(map[string]bool)}
+}
+
+// Drop makes keys look unset.
+func (c *Chaos) Drop(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		c.missing[k] = true
+	}
+}
+
+// Delay makes lookups of keys take d longer.
+func (c *Chaos) Delay(d time.Duration, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delays = append(c.delays, chaosDelay{d, keySet(keys)})
+}
+
+// Fail makes the next n lookups of keys fail with err, or ErrInjected if err
+// is nil. If n is negative, they fail until Reset.
+func (c *Chaos) Fail(err error, n int, keys ...string) {
+	if err == nil {
+		err = ErrInjected
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fails = append(c.fails, &chaosFail{err, n, keySet(keys)})
+}
+
+// Reset removes every injected failure.
+func (c *Chaos) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.missing = make(map[string]bool)
+	c.delays, c.fails = nil, nil
+}
+
+// Lookups returns the number of lookups made, including failed ones.
+func (c *Chaos) Lookups() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lookups
+}
+
+// Lookup looks up key in the wrapped source, after applying the failures
+// injected for it.
+func (c *Chaos) Lookup(key string) (string, bool, error) {
+	c.mu.Lock()
+	c.lookups++
+	var delay time.Duration
+	for _, d := range c.delays {
+		if matches(d.keys, key) {
+			delay += d.d
+		}
+	}
+	err := c.takeFailure(key)
+	missing := c.missing[key]
+	c.mu.Unlock()
+
+	time.Sleep(delay)
+	if err != nil {
+		return "", false, err
+	} else if missing {
+		return "", false, nil
+	}
+	return c.src.Lookup(key)
+}
+
+// Ping fails as a lookup of a variable with no failures of its own would,
+// so that envconf.WaitForSources sees failures injected for every key.
+func (c *Chaos) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.takeFailure("")
+}
+
+// Name names the source after the one it wraps.
+func (c *Chaos) Name() string {
+	return "chaos(" + envconf.SourceName(c.src) + ")"
+}
+
+// takeFailure returns the first injected failure for key, if any, and counts
+// it. c.mu must be held.
+func (c *Chaos) takeFailure(key string) error {
+	for _, f := range c.fails {
+		if f.n != 0 && matches(f.keys, key) {
+			if f.n > 0 {
+				f.n--
+			}
+			return f.err
+		}
+	}
+	return nil
+}
+
+// keySet returns the set of keys, or nil for every key.
+func keySet(keys []string) map[string]bool {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
+
+func matches(keys map[string]bool, key string) bool {
+	return keys == nil || keys[key]
+}
@@ -0,0 +1,76 @@
+package envconftest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+type appConfig struct {
+	Host string `required:"true"`
+	Port int    `default:"80"`
+}
+
+func TestChaos(t *testing.T) {
+	src := NewChaos(envconf.MapSource{"HOST": "a", "PORT": "8080"})
+	var conf appConfig
+
+	src.Drop("PORT")
+	if err := envconf.ReadConfigSource(&conf, src); err != nil || conf.Port != 80 {
+		t.Errorf("Expected a dropped variable to look unset, got %+v, %v", conf, err)
+		t.Fail()
+	}
+
+	timeout := errors.New("i/o timeout")
+	src.Fail(timeout, 1, "HOST")
+	err := envconf.ReadConfigSource(&conf, src)
+	if err == nil || !strings.Contains(err.Error(), "i/o timeout") {
+		t.Errorf("expected an error matching '%s', got '%v'", "i/o timeout", err)
+		t.Fail()
+	}
+	if err := envconf.ReadConfigSource(&conf, src); err != nil {
+		t.Errorf("Expected a transient failure to pass, got %v", err)
+		t.Fail()
+	}
+
+	src.Reset()
+	src.Delay(20*time.Millisecond, "HOST")
+	start := time.Now()
+	if err := envconf.ReadConfigSource(&conf, src); err != nil || conf.Port != 8080 {
+		t.Errorf("Unexpected result %+v, %v", conf, err)
+		t.Fail()
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("Expected a slow lookup")
+		t.Fail()
+	}
+	if src.Lookups() != 7 {
+		t.Errorf("Expected 7 lookups, got %d", src.Lookups())
+		t.Fail()
+	}
+}
+
+func TestChaosWait(t *testing.T) {
+	src := NewChaos(envconf.MapSource{"HOST": "a"})
+	src.Fail(nil, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var conf appConfig
+	if err := envconf.NewDecoder(envconf.WithSource(src)).DecodeContext(ctx, &conf); err != nil {
+		t.Errorf("Expected startup to wait out the failures, got %v", err)
+		t.Fail()
+	}
+
+	src.Fail(nil, -1, "HOST")
+	for i := 0; i < 3; i++ {
+		if _, _, err := src.Lookup("HOST"); err != ErrInjected {
+			t.Errorf("Expected ErrInjected until Reset, got %v", err)
+			t.Fail()
+		}
+	}
+}
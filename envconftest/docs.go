@@ -0,0 +1,67 @@
+package envconftest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ceralena/envconf"
+)
+
+// CheckDocs checks that none of the documentation envconf generates for
+// conf, which is its Usage, Markdown, exported schema and .env template,
+// contains the default of a field tagged as a secret, unless the field is
+// also tagged showdefault:"true". opts configure the Decoder as for the
+// program under test. A program's tests can call it to catch a real
+// credential left as a default before it is published:
+//
+//	if err := envconftest.CheckDocs(&Config{}); err != nil {
+//		t.Error(err)
+//	}
+func CheckDocs(conf interface{}, opts ...envconf.Option) error {
+	d := envconf.NewDecoder(opts...)
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	usage, err := d.Usage(conf)
+	if err != nil {
+		return err
+	}
+	var markdown, schema, template bytes.Buffer
+	if err := d.Markdown(&markdown, conf); err != nil {
+		return err
+	}
+	if err := d.ExportSchema(&schema, conf); err != nil {
+		return err
+	}
+	if err := d.WriteTemplate(&template, conf); err != nil {
+		return err
+	}
+	docs := []struct{ name, text string }{
+		{"Usage", usage},
+		{"Markdown", markdown.String()},
+		{"ExportSchema", schema.String()},
+		{"WriteTemplate", template.String()},
+	}
+
+	var leaks []string
+	for _, pf := range plan {
+		tag := pf.Field.Tag
+		secret, ok := tag.Lookup("secret")
+		def := tag.Get("default")
+		if !ok || secret == "false" || len(def) == 0 || tag.Get("showdefault") == "true" {
+			continue
+		}
+		for _, doc := range docs {
+			if strings.Contains(doc.text, def) {
+				leaks = append(leaks, fmt.Sprintf("%s shows the default of %s", doc.name, pf.Name))
+			}
+		}
+	}
+	if len(leaks) > 0 {
+		return fmt.Errorf("Secret defaults in docs: %s", strings.Join(leaks, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package envconftest
+
+import (
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+func TestCheckDocs(t *testing.T) {
+	var safe struct {
+		Password string `secret:"true" default:"dev-password-1"`
+		Region   string `default:"eu-west-1"`
+		Token    string `secret:"true" default:"public-demo-token" showdefault:"true"`
+	}
+	if err := CheckDocs(&safe); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+
+	// A secret field whose default is repeated in its description leaks.
+	var leaky struct {
+		Password string `secret:"true" default:"dev-password-1" desc:"defaults to dev-password-1"`
+	}
+	errmatch := "Secret defaults in docs: Usage shows the default of APP_PASSWORD, " +
+		"Markdown shows the default of APP_PASSWORD, ExportSchema shows the default of APP_PASSWORD, " +
+		"WriteTemplate shows the default of APP_PASSWORD"
+	if err := CheckDocs(&leaky, envconf.WithPrefix("APP_")); err == nil || err.Error() != errmatch {
+		t.Errorf("expected an error matching '%s', got '%v'", errmatch, err)
+		t.Fail()
+	}
+}
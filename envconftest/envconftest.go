@@ -0,0 +1,7 @@
+/*
+Package envconftest provides utilities for testing programs configured with
+envconf: Chaos, a Source which injects lookup failures; Generator, which
+makes random valid and invalid environments for a config struct; and
+CheckDocs, which checks that generated documentation doesn't reveal secrets.
+*/
+package envconftest
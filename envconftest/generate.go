@@ -0,0 +1,299 @@
+package envconftest
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// A Generator makes random environments for a config struct, for
+// property-based tests of a program's startup. Valid environments respect
+// each field's type and its "required", "oneof", "min", "max" and "convert"
+// tags; invalid ones break exactly one of those rules.
+//
+//	g := envconftest.NewGenerator(seed)
+//	for i := 0; i < 100; i++ {
+//		env, err := g.Valid(&conf)
+//		...
+//	}
+//
+// Fields which the Generator can't make values for, such as those with a
+// "pattern" tag or of a type implementing encoding.TextUnmarshaler, are set
+// from their "default" tag if they have one and are otherwise left unset. It
+// is an error for such a field to be required. Validate and PostLoad hooks
+// are not known to the Generator, so a valid environment may still be
+// rejected by them.
+type Generator struct {
+	rand *rand.Rand
+	d    *envconf.Decoder
+}
+
+// NewGenerator returns a Generator seeded with seed. opts configure the
+// Decoder which names the variables, as for the program under test.
+func NewGenerator(seed int64, opts ...envconf.Option) *Generator {
+	return &Generator{
+		rand: rand.New(rand.NewSource(seed)),
+		d:    envconf.NewDecoder(opts...),
+	}
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// Valid returns a random environment which conf's fields accept. Required
+// fields are always set; the others are set about half of the time.
+func (g *Generator) Valid(conf interface{}) (envconf.MapSource, error) {
+	plan, err := g.d.Plan(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	env := envconf.MapSource{}
+	for _, pf := range plan {
+		if pf.SelfDecoding || pf.Composite {
+			continue
+		}
+		tag := pf.Field.Tag
+		required := tag.Get("required") == "true" || len(tag.Get("required_if")) > 0
+		if !required && g.rand.Intn(2) == 0 {
+			continue
+		}
+
+		if v, ok := g.value(pf); ok {
+			env[pf.Name] = v
+		} else if def := tag.Get("default"); len(def) > 0 {
+			env[pf.Name] = def
+		} else if tag.Get("required") == "true" {
+			return nil, fmt.Errorf("Can't generate a value for required config field %s", pf.Field.Name)
+		}
+	}
+	return env, nil
+}
+
+// Invalid returns a random environment which conf's fields reject, and the
+// name of the variable which makes it invalid. It is a valid environment
+// with one required variable removed or one value made unparseable, not one
+// of its choices, or out of range. Lazy fields are never made invalid, since
+// Decode does not read them.
+func (g *Generator) Invalid(conf interface{}) (env envconf.MapSource, name string, err error) {
+	if env, err = g.Valid(conf); err != nil {
+		return nil, "", err
+	}
+	plan, err := g.d.Plan(conf)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var breakers []func()
+	for _, pf := range plan {
+		if pf.SelfDecoding || pf.Composite || pf.Lazy || valueType(pf.Field.Type) != pf.Field.Type {
+			continue
+		}
+		pf := pf
+		set := func(v string) func() {
+			return func() { env[pf.Name], name = v, pf.Name }
+		}
+
+		tag := pf.Field.Tag
+		if tag.Get("required") == "true" && len(tag.Get("default")) == 0 && len(pf.DefaultFrom) == 0 {
+			breakers = append(breakers, func() { delete(env, pf.Name); name = pf.Name })
+		}
+
+		t := valueType(pf.Field.Type)
+		if t.Kind() == reflect.Slice && !reflect.PtrTo(t).Implements(textUnmarshalerType) {
+			t = t.Elem()
+		}
+		if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+			continue
+		}
+		if choices := tag.Get("oneof"); len(choices) > 0 {
+			breakers = append(breakers, set("not-"+strings.ReplaceAll(choices, ",", "-")))
+		}
+		if len(tag.Get("convert")) > 0 {
+			breakers = append(breakers, set("1.5x"))
+			continue
+		}
+		switch t.Kind() {
+		case reflect.Bool:
+			breakers = append(breakers, set("maybe"))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Float32, reflect.Float64:
+			breakers = append(breakers, set("NaN?"))
+			if lo, hi, ok := bounds(t, tag); ok {
+				if min := tag.Get("min"); len(min) > 0 {
+					breakers = append(breakers, set(format(t, lo-unit(t))))
+				}
+				if max := tag.Get("max"); len(max) > 0 {
+					breakers = append(breakers, set(format(t, hi+unit(t))))
+				}
+			}
+		}
+	}
+
+	if len(breakers) == 0 {
+		return nil, "", fmt.Errorf("No config field can be made invalid")
+	}
+	breakers[g.rand.Intn(len(breakers))]()
+	return env, name, nil
+}
+
+// value returns a random valid value for pf, or false if the Generator can't
+// make one.
+func (g *Generator) value(pf envconf.PlannedField) (string, bool) {
+	tag := pf.Field.Tag
+	t := valueType(pf.Field.Type)
+	if len(tag.Get("pattern")) > 0 {
+		return "", false
+	}
+	if t.Kind() != reflect.Slice || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return g.scalar(t, tag)
+	}
+
+	sep := tag.Get("separator")
+	if len(sep) == 0 {
+		sep = ","
+	}
+	elems := make([]string, 1+g.rand.Intn(3))
+	for i := range elems {
+		v, ok := g.scalar(t.Elem(), tag)
+		if !ok {
+			return "", false
+		}
+		elems[i] = v
+	}
+	return strings.Join(elems, sep), true
+}
+
+// scalar returns a random valid value of type t for a field with tag.
+func (g *Generator) scalar(t reflect.Type, tag reflect.StructTag) (string, bool) {
+	if choices := tag.Get("oneof"); len(choices) > 0 {
+		c := strings.Split(choices, ",")
+		return c[g.rand.Intn(len(c))], true
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return "", false
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+		b := make([]byte, 1+g.rand.Intn(12))
+		for i := range b {
+			b[i] = letters[g.rand.Intn(len(letters))]
+		}
+		return string(b), true
+	case reflect.Bool:
+		return strconv.FormatBool(g.rand.Intn(2) == 0), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64:
+		lo, hi, ok := bounds(t, tag)
+		if !ok {
+			return "", false
+		}
+		v := lo + g.rand.Float64()*(hi-lo)
+		if t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+			v = float64(int64(v))
+		}
+		if conv := tag.Get("convert"); len(conv) > 0 {
+			return convertFrom(conv, v, hi)
+		}
+		return format(t, v), true
+	default:
+		return "", false
+	}
+}
+
+// bounds returns the range of values to generate for a number or duration,
+// from the "min" and "max" tags or a default range.
+func bounds(t reflect.Type, tag reflect.StructTag) (lo, hi float64, ok bool) {
+	lo, hi = 0, 100
+	if t == durationType {
+		lo, hi = float64(time.Millisecond), float64(time.Hour)
+	}
+
+	parse := func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	if t == durationType {
+		parse = func(s string) (float64, error) {
+			d, err := time.ParseDuration(s)
+			return float64(d), err
+		}
+	}
+	var err error
+	if min := tag.Get("min"); len(min) > 0 {
+		if lo, err = parse(min); err != nil {
+			return 0, 0, false
+		}
+		if hi < lo {
+			hi = lo + 100*unit(t)
+		}
+	}
+	if max := tag.Get("max"); len(max) > 0 {
+		if hi, err = parse(max); err != nil {
+			return 0, 0, false
+		}
+		if lo > hi {
+			lo = hi - 100*unit(t)
+		}
+	}
+	return lo, hi, true
+}
+
+// unit returns the smallest step between values of a number or duration
+// type worth generating.
+func unit(t reflect.Type) float64 {
+	if t == durationType {
+		return float64(time.Millisecond)
+	}
+	return 1
+}
+
+// format formats a number or duration of type t.
+func format(t reflect.Type, v float64) string {
+	switch {
+	case t == durationType:
+		return time.Duration(v).String()
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return strconv.FormatInt(int64(v), 10)
+	}
+}
+
+// convertFrom formats the duration v in the source unit of the "convert"
+// tag conv, such as "ms->duration", rounding it up unless that would pass
+// the upper bound hi.
+func convertFrom(conv string, v, hi float64) (string, bool) {
+	units := map[string]time.Duration{
+		"ns": time.Nanosecond, "us": time.Microsecond, "ms": time.Millisecond,
+		"s": time.Second, "m": time.Minute, "h": time.Hour,
+	}
+	u, ok := units[strings.TrimSuffix(conv, "->duration")]
+	if !ok {
+		return "", false
+	}
+	n := math.Ceil(v / float64(u))
+	if n*float64(u) > hi {
+		n = math.Floor(hi / float64(u))
+	}
+	return strconv.FormatInt(int64(n), 10), true
+}
+
+// valueType returns the type a field's variable is parsed into, looking
+// through envconf.Lazy.
+func valueType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Struct && t.PkgPath() == "github.com/ceralena/envconf" && strings.HasPrefix(t.Name(), "Lazy[") {
+		state, _ := t.FieldByName("state")
+		val, _ := state.Type.Elem().FieldByName("val")
+		return val.Type
+	}
+	return t
+}
@@ -0,0 +1,97 @@
+package envconftest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+type genConfig struct {
+	Name     string `required:"true"`
+	Level    string `oneof:"debug,info,warn" default:"info"`
+	Workers  int    `min:"1" max:"16" required:"true"`
+	Ratio    float64
+	Debug    bool
+	Timeout  time.Duration `min:"100ms" max:"30s"`
+	Delay    time.Duration `convert:"s->duration" max:"1m"`
+	Hosts    []string      `separator:";"`
+	Ports    []int         `min:"1024"`
+	Region   string        `pattern:"[a-z]+-[0-9]" default:"eu-1"`
+	Deadline envconf.Lazy[time.Duration]
+	Server   struct {
+		Port int `max:"65535"`
+	}
+}
+
+func TestGeneratorValid(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		env, err := NewGenerator(seed).Valid(&genConfig{})
+		if err != nil {
+			t.Errorf("Valid(): unexpected error %v", err)
+			t.FailNow()
+		}
+		var conf genConfig
+		if err := envconf.ReadConfigSource(&conf, env); err != nil {
+			t.Errorf("seed %d: expected a valid environment, got %v for %v", seed, err, env)
+			t.Fail()
+		}
+		if _, err := conf.Deadline.Get(); err != nil {
+			t.Errorf("seed %d: expected a valid lazy value, got %v", seed, err)
+			t.Fail()
+		}
+	}
+
+	a, _ := NewGenerator(1).Valid(&genConfig{})
+	b, _ := NewGenerator(1).Valid(&genConfig{})
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Expected the same environment from the same seed, got %v and %v", a, b)
+		t.Fail()
+	}
+
+	prefixed, _ := NewGenerator(1, envconf.WithPrefix("APP_")).Valid(&genConfig{})
+	if _, ok := prefixed["APP_NAME"]; !ok {
+		t.Errorf("Expected variables named by the Decoder, got %v", prefixed)
+		t.Fail()
+	}
+
+	var bad struct {
+		Zone string `pattern:"[a-z]+" required:"true"`
+	}
+	match := "Can't generate a value for required config field Zone"
+	if _, err := NewGenerator(1).Valid(&bad); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Valid(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestGeneratorInvalid(t *testing.T) {
+	broken := make(map[string]bool)
+	for seed := int64(0); seed < 200; seed++ {
+		env, name, err := NewGenerator(seed).Invalid(&genConfig{})
+		if err != nil {
+			t.Errorf("Invalid(): unexpected error %v", err)
+			t.FailNow()
+		}
+		broken[name] = true
+		var conf genConfig
+		if err := envconf.ReadConfigSource(&conf, env); err == nil {
+			t.Errorf("seed %d: expected %s to make %v invalid", seed, name, env)
+			t.Fail()
+		}
+	}
+	if broken["DEADLINE"] || broken["REGION"] || !broken["SERVER_PORT"] || !broken["NAME"] {
+		t.Errorf("Unexpected variables made invalid: %v", broken)
+		t.Fail()
+	}
+
+	var none struct {
+		Name string
+	}
+	if _, _, err := NewGenerator(1).Invalid(&none); err == nil {
+		t.Errorf("Invalid(): expected an error for a struct which can't be invalid")
+		t.Fail()
+	}
+}
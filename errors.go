@@ -0,0 +1,61 @@
+package envconf
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// An ErrorMode decides how a Decoder handles fields that fail to decode.
+type ErrorMode int
+
+const (
+	// FailFast stops at the first field that fails to parse or validate.
+	// Missing required fields are still reported together.
+	FailFast ErrorMode = iota
+	// CollectAll decodes every field and returns all of the problems as
+	// Errors.
+	CollectAll
+	// CollectAllWithWarnings is like CollectAll, but problems with fields
+	// which are not required are passed to the Decoder's warning func
+	// instead, leaving those fields untouched.
+	CollectAllWithWarnings
+)
+
+// Errors is returned by Decode when using CollectAll or
+// CollectAllWithWarnings.
+type Errors []error
+
+// Error joins the messages of every error in the list.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors in the list, for use with errors.Is and
+// errors.As.
+func (e Errors) Unwrap() []error { return e }
+
+// A LookupError reports that a Source failed to look up a variable, such as
+// with a network or permission error. It is distinct from the variable being
+// unset, which is not an error, and from its value failing to parse.
+type LookupError struct {
+	// Name is the variable which was looked up.
+	Name string
+	Err  error
+}
+
+func (e *LookupError) Error() string {
+	return fmt.Sprintf("Lookup of %s failed: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the Source's error.
+func (e *LookupError) Unwrap() error { return e.Err }
+
+// logWarning is the default warning func.
+func logWarning(err error) {
+	log.Printf("envconf: warning: %v", err)
+}
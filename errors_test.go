@@ -0,0 +1,84 @@
+package envconf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestErrorMode(t *testing.T) {
+	type MyConf struct {
+		Port    int `required:"true"`
+		Debug   bool
+		Workers int
+		Host    string `required:"true"`
+	}
+	input := mapgetter{"PORT": "http", "DEBUG": "maybe", "WORKERS": "4"}
+
+	var c MyConf
+	err := NewDecoder(WithGetter(input.get)).Decode(&c)
+	if err == nil || strings.Contains(err.Error(), "Missing") {
+		t.Errorf("FailFast: expected only the first parse error, got '%v'", err)
+		t.Fail()
+	}
+
+	c = MyConf{}
+	err = NewDecoder(WithGetter(input.get), WithErrorMode(CollectAll)).Decode(&c)
+	if errs, ok := err.(Errors); !ok || len(errs) != 3 {
+		t.Errorf("CollectAll: expected 3 Errors, got '%v'", err)
+		t.Fail()
+	} else if !strings.Contains(errs[2].Error(), "Missing config fields: HOST") {
+		t.Errorf("CollectAll: expected missing fields last, got '%v'", errs[2])
+		t.Fail()
+	}
+	if c.Workers != 4 {
+		t.Errorf("CollectAll: expected valid fields to be set, got Workers=%d", c.Workers)
+		t.Fail()
+	}
+
+	c = MyConf{}
+	var warnings []error
+	err = NewDecoder(
+		WithGetter(input.get),
+		WithErrorMode(CollectAllWithWarnings),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }),
+	).Decode(&c)
+	if errs, ok := err.(Errors); !ok || len(errs) != 2 {
+		t.Errorf("CollectAllWithWarnings: expected 2 Errors, got '%v'", err)
+		t.Fail()
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "strconv.ParseBool: ") {
+		t.Errorf("CollectAllWithWarnings: expected a warning for Debug, got %v", warnings)
+		t.Fail()
+	}
+
+	input = mapgetter{"PORT": "80", "HOST": "localhost"}
+	if err := NewDecoder(WithGetter(input.get), WithErrorMode(CollectAll)).Decode(&c); err != nil {
+		t.Errorf("CollectAll: unexpected error %v", err)
+		t.Fail()
+	}
+}
+
+func TestErrorModeLeavesFieldsUntouched(t *testing.T) {
+	var conf struct {
+		Workers  int    `min:"1"`
+		LogLevel string `oneof:"debug,info"`
+		Password string `minlen:"12"`
+		Port     port
+	}
+	conf.Workers, conf.LogLevel, conf.Password, conf.Port = 4, "info", "correct horse battery", 8080
+	src := MapSource{"WORKERS": "0", "LOGLEVEL": "verbose", "PASSWORD": "changeme", "PORT": "70000"}
+	var warnings []error
+	err := NewDecoder(
+		WithSource(src),
+		WithErrorMode(CollectAllWithWarnings),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }),
+	).Decode(&conf)
+	if err != nil || len(warnings) != 4 {
+		t.Errorf("CollectAllWithWarnings: expected 4 warnings, got %v, %v", warnings, err)
+		t.Fail()
+	}
+	if conf.Workers != 4 || conf.LogLevel != "info" || conf.Password != "correct horse battery" || conf.Port != 8080 {
+		t.Errorf("CollectAllWithWarnings: expected invalid fields to keep their values, got %+v", conf)
+		t.Fail()
+	}
+}
@@ -0,0 +1,330 @@
+/*
+Package etcdsrc provides an envconf Source backed by etcd v3.
+
+Each variable is mapped to a key under a prefix, by default by lower-casing it
+and turning underscores into slashes, so that with the prefix "/myapp/" the
+variable SERVER_PORT is read from the key /myapp/server/port:
+
+	etcd := etcdsrc.New("/myapp/")
+	err := envconf.NewDecoder(
+		envconf.WithSource(envconf.LayerSources(envconf.EnvSource{}, etcd)),
+		envconf.WithPrefetcher(etcd),
+	).Decode(&conf)
+
+Decode reads the whole prefix in one request when the source is the
+Decoder's own or is passed to WithPrefetcher. Watch reports changes under the
+prefix, so that a program can read its config again when it changes.
+
+The source talks to etcd's JSON gateway rather than its gRPC API, so using it
+adds no dependencies. The endpoint is taken from ETCDCTL_ENDPOINTS, as by
+etcdctl, unless set with WithEndpoint; for client certificates, pass an
+http.Client with a suitable transport to WithHTTPClient.
+*/
+package etcdsrc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultEndpoint is the endpoint used when none is configured.
+const DefaultEndpoint = "http://127.0.0.1:2379"
+
+// Source looks up variables in etcd.
+type Source struct {
+	endpoint string
+	prefix   string
+	client   *http.Client
+	keyFor   func(name string) string
+
+	mu      sync.Mutex
+	fetched map[string]string
+}
+
+// An Option configures a Source.
+type Option func(*Source)
+
+// WithEndpoint sets the address of the etcd server, such as
+// "https://etcd.internal:2379".
+func WithEndpoint(endpoint string) Option {
+	return func(s *Source) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient sets the client used for requests. The default is
+// http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Source) {
+		s.client = c
+	}
+}
+
+// WithKeyMapper sets how variable names are mapped to keys, relative to the
+// prefix.
+func WithKeyMapper(fn func(name string) string) Option {
+	return func(s *Source) {
+		s.keyFor = fn
+	}
+}
+
+// DefaultKeyMapper lower-cases name and turns underscores into slashes, so
+// SERVER_PORT becomes server/port.
+func DefaultKeyMapper(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "/")
+}
+
+// New returns a Source reading keys under prefix.
+func New(prefix string, opts ...Option) *Source {
+	s := &Source{
+		prefix: prefix,
+		client: http.DefaultClient,
+		keyFor: DefaultKeyMapper,
+	}
+	if eps := os.Getenv("ETCDCTL_ENDPOINTS"); len(eps) > 0 {
+		s.endpoint = strings.Split(eps, ",")[0]
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.endpoint) == 0 {
+		s.endpoint = DefaultEndpoint
+	} else if !strings.Contains(s.endpoint, "://") {
+		s.endpoint = "http://" + s.endpoint
+	}
+	s.endpoint = strings.TrimSuffix(s.endpoint, "/")
+	return s
+}
+
+// Key returns the etcd key name is read from.
+func (s *Source) Key(name string) string {
+	return s.prefix + s.keyFor(name)
+}
+
+// keyValue is a key and value from the gateway, which encodes both in base64.
+type keyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// rangeRequest reads one key, or with RangeEnd the keys from Key up to but
+// excluding RangeEnd.
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+// Lookup reads the key for name. A missing key is an unset variable.
+func (s *Source) Lookup(name string) (string, bool, error) {
+	return s.LookupContext(context.Background(), name)
+}
+
+// LookupContext is like Lookup, making its request with ctx.
+func (s *Source) LookupContext(ctx context.Context, name string) (string, bool, error) {
+	key := s.Key(name)
+
+	s.mu.Lock()
+	if s.fetched != nil {
+		v, ok := s.fetched[key]
+		s.mu.Unlock()
+		return v, ok, nil
+	}
+	s.mu.Unlock()
+
+	kvs, err := s.rangeKeys(ctx, rangeRequest{Key: []byte(key)})
+	if err != nil || len(kvs) == 0 {
+		return "", false, err
+	}
+	return string(kvs[0].Value), true, nil
+}
+
+// Prefetch reads every key under the prefix in one request. Later lookups
+// are served from what was read, until Refresh is called or Watch sees a
+// change.
+func (s *Source) Prefetch(names []string) error {
+	return s.PrefetchContext(context.Background(), names)
+}
+
+// PrefetchContext is like Prefetch, making its request with ctx.
+func (s *Source) PrefetchContext(ctx context.Context, names []string) error {
+	kvs, err := s.rangeKeys(ctx, s.prefixRange())
+	if err != nil {
+		return err
+	}
+
+	fetched := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		fetched[string(kv.Key)] = string(kv.Value)
+	}
+
+	s.mu.Lock()
+	s.fetched = fetched
+	s.mu.Unlock()
+	return nil
+}
+
+// Refresh discards the keys read by Prefetch, so that lookups go to etcd
+// again.
+func (s *Source) Refresh() {
+	s.mu.Lock()
+	s.fetched = nil
+	s.mu.Unlock()
+}
+
+// Ping checks that the server is reachable.
+func (s *Source) Ping(ctx context.Context) error {
+	resp, err := s.post(ctx, "/v3/maintenance/status", struct{}{})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Name identifies the source in errors and reports.
+func (s *Source) Name() string {
+	return "etcd(" + s.prefix + ")"
+}
+
+// A Watcher reports changes to the keys under a Source's prefix.
+type Watcher struct {
+	// C receives a value after each change. Changes which arrive while a
+	// value is waiting to be received are merged into it. C is closed when
+	// the watch ends.
+	C <-chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err returns the error which ended the watch, once C is closed. It is nil
+// if the watch ended because its context was done.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Watch watches the keys under the prefix until ctx is done. Each change
+// also discards the keys read by Prefetch, so that the next decode sees it.
+func (s *Source) Watch(ctx context.Context) (*Watcher, error) {
+	r := s.prefixRange()
+	resp, err := s.post(ctx, "/v3/watch", map[string]interface{}{
+		"create_request": map[string][]byte{"key": r.Key, "range_end": r.RangeEnd},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan struct{}, 1)
+	w := &Watcher{C: c}
+	go func() {
+		defer close(c)
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var msg struct {
+				Result struct {
+					Canceled bool       `json:"canceled"`
+					Reason   string     `json:"cancel_reason"`
+					Events   []struct{} `json:"events"`
+				} `json:"result"`
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := dec.Decode(&msg); err != nil {
+				if ctx.Err() == nil {
+					w.setErr(err)
+				}
+				return
+			}
+			if msg.Error != nil {
+				w.setErr(fmt.Errorf("etcd watch failed: %s", msg.Error.Message))
+				return
+			} else if msg.Result.Canceled {
+				w.setErr(fmt.Errorf("etcd watch canceled: %s", msg.Result.Reason))
+				return
+			}
+			if len(msg.Result.Events) > 0 {
+				s.Refresh()
+				select {
+				case c <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return w, nil
+}
+
+func (w *Watcher) setErr(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
+
+// prefixRange returns the range of keys under the prefix.
+func (s *Source) prefixRange() rangeRequest {
+	key := []byte(s.prefix)
+	end := append([]byte(nil), key...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return rangeRequest{key, end[:i+1]}
+		}
+	}
+	// Every byte is 0xff, or the prefix is empty: read to the end.
+	return rangeRequest{key, []byte{0}}
+}
+
+// rangeKeys reads the keys in r.
+func (s *Source) rangeKeys(ctx context.Context, r rangeRequest) ([]keyValue, error) {
+	resp, err := s.post(ctx, "/v3/kv/range", r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Kvs []keyValue `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Invalid response from etcd: %v", err)
+	}
+	return result.Kvs, nil
+}
+
+// post sends a request to the gateway. Responses other than 200 OK are
+// returned as errors.
+func (s *Source) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd returned %s for %s: %s",
+			resp.Status, path, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,194 @@
+package etcdsrc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// fakeEtcd serves a key/value store over a minimal copy of etcd's JSON
+// gateway.
+type fakeEtcd struct {
+	mu       sync.Mutex
+	kv       map[string]string
+	ranges   int
+	changes  chan string
+	failWith int
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	fail := f.failWith
+	f.mu.Unlock()
+	if fail != 0 {
+		http.Error(w, `{"error": "etcdserver: user name is empty"}`, fail)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v3/maintenance/status":
+		fmt.Fprint(w, `{"version": "3.5.0"}`)
+
+	case "/v3/kv/range":
+		var req rangeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.ranges++
+		var kvs []keyValue
+		for k, v := range f.kv {
+			inRange := k == string(req.Key)
+			if len(req.RangeEnd) > 0 {
+				inRange = k >= string(req.Key) && k < string(req.RangeEnd)
+			}
+			if inRange {
+				kvs = append(kvs, keyValue{[]byte(k), []byte(v)})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"kvs": kvs})
+
+	case "/v3/watch":
+		fmt.Fprintln(w, `{"result": {"created": true}}`)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case key := <-f.changes:
+				fmt.Fprintf(w, `{"result": {"events": [{"kv": {"key": %q}}]}}`+"\n", key)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+type appConfig struct {
+	Server struct {
+		Host string `required:"true"`
+		Port int    `default:"80"`
+	}
+}
+
+func TestSource(t *testing.T) {
+	fake := &fakeEtcd{kv: map[string]string{
+		"/myapp/server/host": "example.com",
+		"/myapp/server/port": "8080",
+		"/other/server/host": "other.com",
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	etcd := New("/myapp/", WithEndpoint(srv.URL))
+	if etcd.Key("SERVER_PORT") != "/myapp/server/port" {
+		t.Errorf("Key(): got %q", etcd.Key("SERVER_PORT"))
+		t.Fail()
+	}
+
+	var conf appConfig
+	if err := envconf.ReadConfigSource(&conf, envconf.LayerSources(etcd)); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Server.Host != "example.com" || conf.Server.Port != 8080 || fake.ranges != 2 {
+		t.Errorf("Expected a request per variable, got %d and %+v", fake.ranges, conf)
+		t.Fail()
+	}
+
+	fake.ranges = 0
+	if err := envconf.ReadConfigSource(&conf, etcd); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Server.Host != "example.com" || fake.ranges != 1 {
+		t.Errorf("Expected one prefetch request, got %d and %+v", fake.ranges, conf)
+		t.Fail()
+	}
+
+	if err := etcd.Ping(context.Background()); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+}
+
+func TestWatch(t *testing.T) {
+	fake := &fakeEtcd{kv: map[string]string{"/myapp/server/host": "a"}, changes: make(chan string)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	etcd := New("/myapp/", WithEndpoint(srv.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := etcd.Watch(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	var conf appConfig
+	envconf.ReadConfigSource(&conf, etcd)
+
+	fake.mu.Lock()
+	fake.kv["/myapp/server/host"] = "b"
+	fake.mu.Unlock()
+	fake.changes <- "/myapp/server/host"
+
+	select {
+	case <-w.C:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected a change to be reported")
+		t.FailNow()
+	}
+	if err := envconf.ReadConfigSource(&conf, envconf.LayerSources(etcd)); err != nil || conf.Server.Host != "b" {
+		t.Errorf("Expected the changed value after a watch event, got %+v, %v", conf, err)
+		t.Fail()
+	}
+
+	cancel()
+	for range w.C {
+	}
+	if w.Err() != nil {
+		t.Errorf("Expected no error once the context is done, got %v", w.Err())
+		t.Fail()
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	fake := &fakeEtcd{kv: map[string]string{}, failWith: http.StatusUnauthorized}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var conf appConfig
+	err := envconf.ReadConfigSource(&conf, New("/myapp/", WithEndpoint(srv.URL)))
+	match := "Prefetch failed: etcd returned 401 Unauthorized for /v3/kv/range"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	fake.failWith = 0
+	err = envconf.ReadConfigSource(&conf, New("/empty/", WithEndpoint(srv.URL)))
+	match = "Missing config fields: SERVER_HOST"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	if r := New("").prefixRange(); string(r.RangeEnd) != "\x00" {
+		t.Errorf("Expected an empty prefix to read every key, got %q", r.RangeEnd)
+		t.Fail()
+	}
+	if r := New("/a/").prefixRange(); string(r.RangeEnd) != "/a0" {
+		t.Errorf("Expected the range to end after the prefix, got %q", r.RangeEnd)
+		t.Fail()
+	}
+}
@@ -0,0 +1,106 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Expiring holds a value which applies only until an expiry given with it,
+// for temporary overrides, such as raising a rate limit during an incident,
+// which must not silently persist. Its variable is set to the value, an @ and
+// the expiry as a date or an RFC 3339 time:
+//
+//	var conf struct {
+//		RateLimit envconf.Expiring[int]
+//	}
+//
+//	RATE_LIMIT=5000@2025-07-01
+//
+// A date expires at the start of that day in UTC. T may be any type envconf
+// parses from a single value, other than slices. A value without an expiry is
+// an error, and an override which has already expired is reported to the
+// Decoder's warning func.
+type Expiring[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// Get returns the value and true before the expiry, and otherwise the zero
+// value and false. It also returns false if no value was set.
+func (e Expiring[T]) Get() (T, bool) {
+	if e.expires.IsZero() || !time.Now().Before(e.expires) {
+		var zero T
+		return zero, false
+	}
+	return e.value, true
+}
+
+// GetOr returns the value before the expiry, and otherwise def.
+func (e Expiring[T]) GetOr(def T) T {
+	if v, ok := e.Get(); ok {
+		return v
+	}
+	return def
+}
+
+// Expires returns the expiry, or the zero time if no value was set.
+func (e Expiring[T]) Expires() time.Time {
+	return e.expires
+}
+
+// UnmarshalText parses a value and its expiry, separated by the last @.
+func (e *Expiring[T]) UnmarshalText(text []byte) error {
+	s := string(text)
+	at := strings.LastIndexByte(s, '@')
+	if at < 0 {
+		return fmt.Errorf("%q has no expiry (expected value@2006-01-02)", s)
+	}
+
+	expires, err := time.Parse("2006-01-02", s[at+1:])
+	if err != nil {
+		if expires, err = time.Parse(time.RFC3339, s[at+1:]); err != nil {
+			return fmt.Errorf("invalid expiry %q (expected a date or RFC 3339 time)", s[at+1:])
+		}
+	}
+
+	var value T
+	if err := setValue(reflect.ValueOf(&value).Elem(), s[:at]); err == errInvalidKind {
+		return fmt.Errorf("invalid kind for Expiring value: %v", reflect.TypeOf(value))
+	} else if err != nil {
+		return err
+	}
+	e.value, e.expires = value, expires
+	return nil
+}
+
+// MarshalText formats the value and expiry as UnmarshalText parses them.
+func (e Expiring[T]) MarshalText() ([]byte, error) {
+	if e.expires.IsZero() {
+		return nil, nil
+	}
+	v, err := formatValue(reflect.ValueOf(&e.value).Elem())
+	if err != nil {
+		return nil, err
+	}
+	expires := e.expires.Format(time.RFC3339)
+	if e.expires.Equal(e.expires.Truncate(24 * time.Hour)) {
+		expires = e.expires.Format("2006-01-02")
+	}
+	return []byte(v + "@" + expires), nil
+}
+
+// String formats the value and expiry as MarshalText does.
+func (e Expiring[T]) String() string {
+	b, _ := e.MarshalText()
+	return string(b)
+}
+
+// Warnings reports an override which has already expired.
+func (e *Expiring[T]) Warnings() []error {
+	if e.expires.IsZero() || time.Now().Before(e.expires) {
+		return nil
+	}
+	return []error{fmt.Errorf("override expired at %v and is ignored", e.expires)}
+}
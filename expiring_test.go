@@ -0,0 +1,69 @@
+package envconf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiring(t *testing.T) {
+	var conf struct {
+		RateLimit Expiring[int]
+		Banner    Expiring[string]
+		Timeout   Expiring[time.Duration]
+		Unset     Expiring[bool]
+	}
+	var warnings []error
+	d := NewDecoder(
+		WithSource(MapSource{
+			"RATELIMIT": "5000@2999-01-01",
+			"BANNER":    "maintenance@ops@2000-01-01",
+			"TIMEOUT":   "30s@2999-01-01T12:00:00Z",
+		}),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }),
+	)
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	if v, ok := conf.RateLimit.Get(); !ok || v != 5000 {
+		t.Errorf("RateLimit: expected 5000 before the expiry, got %v, %v", v, ok)
+		t.Fail()
+	}
+	if v, ok := conf.Banner.Get(); ok || v != "" {
+		t.Errorf("Banner: expected nothing after the expiry, got %q, %v", v, ok)
+		t.Fail()
+	}
+	if conf.Banner.GetOr("none") != "none" || conf.Timeout.GetOr(0) != 30*time.Second {
+		t.Errorf("GetOr(): unexpected %v, %v", conf.Banner.GetOr("none"), conf.Timeout.GetOr(0))
+		t.Fail()
+	}
+	if _, ok := conf.Unset.Get(); ok || !conf.Unset.Expires().IsZero() {
+		t.Errorf("Unset: expected no value")
+		t.Fail()
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "Config field Banner: override expired at 2000-01-01") {
+		t.Errorf("Expected a warning for the expired override, got %v", warnings)
+		t.Fail()
+	}
+
+	// It is written back as it was given.
+	env, err := Write(&conf)
+	if err != nil || env["RATELIMIT"] != "5000@2999-01-01" || env["TIMEOUT"] != "30s@2999-01-01T12:00:00Z" || env["UNSET"] != "" {
+		t.Errorf("Write(): unexpected %v, %v", env, err)
+		t.Fail()
+	}
+
+	for _, test := range []struct{ input, errmatch string }{
+		{"5000", `"5000" has no expiry`},
+		{"5000@tomorrow", `invalid expiry "tomorrow"`},
+		{"many@2999-01-01", `parsing "many"`},
+	} {
+		err := ReadConfigMap(&conf, map[string]string{"RATELIMIT": test.input})
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfigMap(%q): expected an error matching '%s', got '%v'", test.input, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
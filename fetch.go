@@ -0,0 +1,56 @@
+package envconf
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// A Prefetcher is told which variables are about to be read, so that a slow
+// source can fetch them in one batch rather than one at a time. Prefetch is
+// called once by Decode with the eager variables and once by DecodeLazy with
+// the lazy ones.
+type Prefetcher interface {
+	Prefetch(names []string) error
+}
+
+// A ContextPrefetcher is a Prefetcher whose batches can be cancelled or
+// bounded by a deadline. ReadConfigContext and Decoder.DecodeContext call
+// PrefetchContext in place of Prefetch.
+type ContextPrefetcher interface {
+	Prefetcher
+	PrefetchContext(ctx context.Context, names []string) error
+}
+
+// prefetch passes the names of these fields to the Decoder's Prefetcher.
+func (d *Decoder) prefetch(fields []PlannedField) error {
+	if d.prefetcher == nil {
+		return nil
+	}
+
+	var names []string
+	for _, pf := range fields {
+		if pf.readsPrefix() || reflect.PtrTo(pf.Field.Type).Implements(lazyBinderType) {
+			continue
+		}
+		names = append(names, pf.names()...)
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+	ctx, end := d.prefetchSpan(len(names))
+	var err error
+	if cp, ok := d.prefetcher.(ContextPrefetcher); ok && ctx != nil {
+		err = cp.PrefetchContext(ctx, names)
+	} else if ctx != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	} else {
+		err = d.prefetcher.Prefetch(names)
+	}
+	end(err)
+	if err != nil {
+		return fmt.Errorf("Prefetch failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package envconf
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// batchGetter records the batches it is asked to prefetch.
+type batchGetter struct {
+	vals    mapgetter
+	batches [][]string
+	err     error
+}
+
+func (b *batchGetter) Prefetch(names []string) error {
+	b.batches = append(b.batches, names)
+	return b.err
+}
+
+func TestLazyFetch(t *testing.T) {
+	var myConf struct {
+		Port    int
+		Report  string `fetch:"lazy" required:"true"`
+		Archive string `fetch:"lazy" defaultFrom:"REPORT"`
+	}
+	b := &batchGetter{vals: mapgetter{"PORT": "80", "REPORT": "s3://reports"}}
+	d := NewDecoder(WithGetter(b.vals.get), WithPrefetcher(b))
+
+	if err := d.Decode(&myConf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if myConf.Port != 80 || myConf.Report != "" {
+		t.Errorf("Decode(): expected only eager fields, got %+v", myConf)
+		t.Fail()
+	}
+
+	if err := d.DecodeLazy(&myConf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if myConf.Report != "s3://reports" || myConf.Archive != "s3://reports" {
+		t.Errorf("DecodeLazy(): expected lazy fields, got %+v", myConf)
+		t.Fail()
+	}
+
+	expect := [][]string{{"PORT"}, {"REPORT", "ARCHIVE", "REPORT"}}
+	if !reflect.DeepEqual(b.batches, expect) {
+		t.Errorf("Prefetch: expected batches %v, got %v", expect, b.batches)
+		t.Fail()
+	}
+
+	b.err = errors.New("timeout")
+	match := "Prefetch failed: timeout"
+	if err := d.Decode(&myConf); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Decode(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+// lazyDB is read by DecodeLazy, and is only valid once it has been.
+type lazyDB struct {
+	Host string `required:"true"`
+	Port int    `required:"true"`
+}
+
+func (db lazyDB) Validate() error {
+	if db.Port == 0 {
+		return errors.New("port must be set")
+	}
+	return nil
+}
+
+func TestLazyFetchStruct(t *testing.T) {
+	var myConf struct {
+		Port  int
+		DB    lazyDB  `fetch:"lazy"`
+		Cache *lazyDB `fetch:"lazy"`
+	}
+	vals := mapgetter{"PORT": "80", "DB_HOST": "db", "DB_PORT": "5432"}
+	d := NewDecoder(WithGetter(vals.get))
+
+	if err := d.Decode(&myConf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if myConf.Port != 80 || myConf.DB.Host != "" || myConf.Cache != nil {
+		t.Errorf("Decode(): expected only eager fields, got %+v", myConf)
+		t.Fail()
+	}
+
+	match := "Missing config fields: CACHE_HOST, CACHE_PORT"
+	if err := d.DecodeLazy(&myConf); err == nil || err.Error() != match {
+		t.Errorf("DecodeLazy(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	vals["CACHE_HOST"], vals["CACHE_PORT"] = "cache", "0"
+	match = "Invalid config field Cache: port must be set"
+	if err := d.DecodeLazy(&myConf); err == nil || err.Error() != match {
+		t.Errorf("DecodeLazy(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	vals["CACHE_PORT"] = "6379"
+	if err := d.DecodeLazy(&myConf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if myConf.DB.Port != 5432 || myConf.Cache.Host != "cache" {
+		t.Errorf("DecodeLazy(): expected lazy fields, got %+v", myConf)
+		t.Fail()
+	}
+}
@@ -0,0 +1,123 @@
+package envconf
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FlagSource is a Source serving the command-line flags registered by
+// BindFlags. It serves only the flags which were set, so that anything not
+// given on the command line falls through to the sources beneath it.
+type FlagSource struct {
+	values map[string]*flagValue
+}
+
+// BindFlags registers a flag on fs for each field of conf and returns the
+// Source serving them once fs has been parsed. A shortcut for:
+//
+//	envconf.NewDecoder().BindFlags(fs, conf)
+func BindFlags(fs *flag.FlagSet, conf interface{}) (*FlagSource, error) {
+	return NewDecoder().BindFlags(fs, conf)
+}
+
+// BindFlags registers a flag on fs for each field of conf and returns the
+// Source serving them once fs has been parsed. Layered above the environment,
+// the flags then override it:
+//
+//	flags, err := envconf.BindFlags(flag.CommandLine, &conf)
+//	if err != nil {
+//		return err
+//	}
+//	flag.Parse()
+//	err = envconf.ReadConfigSource(&conf,
+//		envconf.LayerSources(flags, envconf.EnvSource{}))
+//
+// Flags are named from the lower-cased field path joined with "-", so
+// Server.Port becomes -server-port. The help text is taken from the field's
+// "desc" tag and names the variable the flag stands in for, and the default
+// shown is the field's "default" tag. Flag values are checked against the
+// field's type and tags as the flags are parsed, so a bad value is reported
+// with the flag package's usage message.
+//
+// Fields implementing EnvDecoder read their own variables, so they have no
+// flags. The cobraconf subpackage does the same for pflag and cobra commands.
+func (d *Decoder) BindFlags(fs *flag.FlagSet, conf interface{}) (*FlagSource, error) {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &FlagSource{values: make(map[string]*flagValue)}
+	for _, pf := range plan {
+		if pf.readsPrefix() {
+			continue
+		}
+		fv := &flagValue{d: d, field: pf.Field, def: pf.Field.Tag.Get("default")}
+		if reflect.PtrTo(pf.Field.Type).Implements(lazyBinderType) {
+			fv.field.Type = lazyValueType(pf.Field.Type)
+		}
+
+		usage := fmt.Sprintf("(env %s)", pf.Name)
+		if desc := pf.Field.Tag.Get("desc"); len(desc) > 0 {
+			usage = desc + " " + usage
+		}
+		fs.Var(fv, flagName(pf.Path), usage)
+		src.values[pf.Name] = fv
+	}
+	return src, nil
+}
+
+// Lookup returns the value of the flag standing in for the variable key, if
+// it was set.
+func (f *FlagSource) Lookup(key string) (string, bool, error) {
+	if fv, ok := f.values[key]; ok && fv.set {
+		return fv.value, true, nil
+	}
+	return "", false, nil
+}
+
+// Name identifies the source in errors and reports.
+func (f *FlagSource) Name() string {
+	return "flags"
+}
+
+// flagName returns the flag name for a field path.
+func flagName(path []string) string {
+	return strings.ToLower(strings.Join(path, "-"))
+}
+
+// flagValue is the flag.Value registered for a field.
+type flagValue struct {
+	d     *Decoder
+	field reflect.StructField
+	def   string
+	value string
+	set   bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil || f.d == nil {
+		return ""
+	} else if f.set {
+		return f.value
+	}
+	return f.def
+}
+
+// Set checks the value by parsing it into a scratch value of the field's
+// type, and keeps it for Lookup.
+func (f *flagValue) Set(s string) error {
+	scratch := reflect.New(f.field.Type).Elem()
+	if err := f.d.parse(f.field, scratch, s); err != nil {
+		return err
+	}
+	f.value, f.set = s, true
+	return nil
+}
+
+// IsBoolFlag lets bool fields be set with a bare -flag.
+func (f *flagValue) IsBoolFlag() bool {
+	return f.field.Type.Kind() == reflect.Bool
+}
@@ -0,0 +1,97 @@
+package envconf
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type flagConfig struct {
+	Port    int    `default:"8080" desc:"port to listen on"`
+	Debug   bool   `desc:"enable debug logging"`
+	Level   string `oneof:"debug,info,warn"`
+	Timeout Lazy[time.Duration]
+	Server  struct {
+		Host string `desc:"upstream host"`
+	}
+	Cache cacheConfig
+}
+
+func TestBindFlags(t *testing.T) {
+	var conf flagConfig
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags, err := BindFlags(fs, &conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	args := []string{"-debug", "-server-host", "flag.example.com", "-timeout", "5s"}
+	if err := fs.Parse(args); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	env := MapSource{"PORT": "9090", "SERVER_HOST": "env.example.com", "DEBUG": "false"}
+	if err := ReadConfigSource(&conf, LayerSources(flags, env)); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 9090 || !conf.Debug || conf.Server.Host != "flag.example.com" {
+		t.Errorf("Expected flags to override the environment, got %+v", conf)
+		t.Fail()
+	}
+	if d, err := conf.Timeout.Get(); err != nil || d != 5*time.Second {
+		t.Errorf("Expected the lazy field from its flag, got %v, %v", d, err)
+		t.Fail()
+	}
+	if fs.Lookup("cache") != nil || fs.Lookup("cache-host") != nil {
+		t.Errorf("Expected no flags for an EnvDecoder field")
+		t.Fail()
+	}
+}
+
+func TestBindFlagsUsage(t *testing.T) {
+	var conf flagConfig
+	var out bytes.Buffer
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&out)
+	if _, err := BindFlags(fs, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	fs.PrintDefaults()
+
+	for _, expect := range []string{
+		"port to listen on (env PORT) (default 8080)",
+		"upstream host (env SERVER_HOST)",
+		"-level value\n    \t(env LEVEL)",
+	} {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("Expected usage to contain %q, got:\n%s", expect, out.String())
+			t.Fail()
+		}
+	}
+
+	tests := []struct {
+		args     []string
+		errmatch string
+	}{
+		{[]string{"-port", "eighty"}, `invalid value "eighty" for flag -port`},
+		{[]string{"-level", "trace"}, "must be one of debug, info, warn"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(&out)
+		BindFlags(fs, &conf)
+		err := fs.Parse(test.args)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Parse(%v): expected an error matching '%s', got '%v'", test.args, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package envconf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flatten turns a nested document, as decoded from JSON, YAML or TOML into
+// maps and slices, into variables named by namer from the path of keys
+// leading to each value. With DefaultNamer, {"server": {"port": 80}} becomes
+// SERVER_PORT=80, matching the name Decode uses for a Server.Port field.
+//
+// Lists of plain values are joined with commas. Lists containing maps or
+// lists are flattened with each index as a path element, as in
+// UPSTREAMS_0_HOST. Null values are omitted.
+func Flatten(doc interface{}, namer Namer) map[string]string {
+	m := make(map[string]string)
+	flatten(m, namer, nil, doc)
+	return m
+}
+
+func flatten(m map[string]string, namer Namer, path []string, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, ev := range v {
+			flatten(m, namer, append(path[:len(path):len(path)], k), ev)
+		}
+	case map[interface{}]interface{}:
+		for k, ev := range v {
+			flatten(m, namer, append(path[:len(path):len(path)], fmt.Sprint(k)), ev)
+		}
+	case []interface{}:
+		if !isPlainList(v) {
+			for i, ev := range v {
+				flatten(m, namer, append(path[:len(path):len(path)], strconv.Itoa(i)), ev)
+			}
+			return
+		}
+		elems := make([]string, len(v))
+		for i, ev := range v {
+			elems[i] = scalarString(ev)
+		}
+		m[namer.Name(path)] = strings.Join(elems, ",")
+	default:
+		if len(path) > 0 {
+			m[namer.Name(path)] = scalarString(v)
+		}
+	}
+}
+
+// isPlainList reports whether l holds no maps or lists.
+func isPlainList(l []interface{}) bool {
+	for _, v := range l {
+		switch v.(type) {
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// scalarString formats a plain value from a decoded document.
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Package gcpsecretsrc provides an envconf Source backed by Google Cloud Secret
+Manager.
+
+A field names the secret it is read from with the "secret" tag, once the tags
+are read with UseTags:
+
+	var conf struct {
+		Port       int
+		DBPassword string `secret:"projects/my-project/secrets/db-password"`
+	}
+	src := gcpsecretsrc.New(client)
+	d := envconf.NewDecoder(envconf.WithSource(
+		envconf.LayerSources(envconf.EnvSource{}, src)))
+	if err := src.UseTags(d, &conf); err != nil {
+		return err
+	}
+	err := d.Decode(&conf)
+
+The latest version of a secret is read unless the tag names a version, as in
+projects/p/secrets/name/versions/3. With WithProject, variables without a tag
+are read from the secret of the same name in that project, or of the name
+given by WithSecretMapper.
+
+The package does not depend on the Google Cloud libraries. Instead it takes a
+Client, which an application adapts from the library it already uses; with
+cloud.google.com/go/secretmanager:
+
+	type smClient struct{ *secretmanager.Client }
+
+	func (c smClient) AccessSecretVersion(ctx context.Context, name string) ([]byte, error) {
+		resp, err := c.Client.AccessSecretVersion(ctx,
+			&secretmanagerpb.AccessSecretVersionRequest{Name: name})
+		if status.Code(err) == codes.NotFound {
+			return nil, gcpsecretsrc.ErrNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		return resp.Payload.Data, nil
+	}
+*/
+package gcpsecretsrc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// ErrNotFound is returned by a Client for a secret or version which does not
+// exist. The Source treats its variable as unset.
+var ErrNotFound = errors.New("secret not found")
+
+// A Client reads the data of a secret version, given its full resource name
+// such as projects/p/secrets/name/versions/latest.
+type Client interface {
+	AccessSecretVersion(ctx context.Context, name string) ([]byte, error)
+}
+
+// DefaultTimeout is the default time limit on each call to the Client.
+const DefaultTimeout = 10 * time.Second
+
+// Source looks up variables in Secret Manager.
+type Source struct {
+	client   Client
+	ctx      context.Context
+	timeout  time.Duration
+	project  string
+	secretOf func(name string) string
+
+	mu      sync.Mutex
+	tagged  map[string]string
+	fetched map[string]string
+}
+
+// An Option configures a Source.
+type Option func(*Source)
+
+// WithContext sets the context of each call to the Client. Lookups fail once
+// it is done. The default is context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(s *Source) {
+		s.ctx = ctx
+	}
+}
+
+// WithTimeout sets the time limit on each call to the Client. The default is
+// DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Source) {
+		s.timeout = d
+	}
+}
+
+// WithProject serves variables without a "secret" tag from the secrets of
+// project.
+func WithProject(project string) Option {
+	return func(s *Source) {
+		s.project = project
+	}
+}
+
+// WithSecretMapper sets how variable names are mapped to secret ids within
+// the project given to WithProject. The default uses the name unchanged.
+func WithSecretMapper(fn func(name string) string) Option {
+	return func(s *Source) {
+		s.secretOf = fn
+	}
+}
+
+// New returns a Source reading secrets with client.
+func New(client Client, opts ...Option) *Source {
+	s := &Source{
+		client:   client,
+		ctx:      context.Background(),
+		timeout:  DefaultTimeout,
+		secretOf: func(name string) string { return name },
+		tagged:   make(map[string]string),
+		fetched:  make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// UseTags reads the "secret" tags of conf's fields, as named by d, so that
+// each field tagged with a secret's resource name is read from that secret.
+// Tags of "true", which only mark a field as secret, are skipped.
+func (s *Source) UseTags(d *envconf.Decoder, conf interface{}) error {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pf := range plan {
+		tag := pf.Field.Tag.Get("secret")
+		if len(tag) == 0 || tag == "true" || tag == "false" {
+			continue
+		}
+		resource, err := versionName(tag)
+		if err != nil {
+			return fmt.Errorf("Invalid secret for config field %s: %v", pf.Field.Name, err)
+		}
+		s.tagged[pf.Name] = resource
+	}
+	return nil
+}
+
+// versionName checks a secret's resource name and returns the name of the
+// version to read.
+func versionName(name string) (string, error) {
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets":
+		return name + "/versions/latest", nil
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions":
+		return name, nil
+	default:
+		return "", fmt.Errorf("%q (expected projects/p/secrets/name)", name)
+	}
+}
+
+// Locate returns the secret version name is read from.
+func (s *Source) Locate(name string) (string, bool) {
+	s.mu.Lock()
+	resource, ok := s.tagged[name]
+	s.mu.Unlock()
+	if ok {
+		return resource, true
+	} else if len(s.project) > 0 {
+		return "projects/" + s.project + "/secrets/" + s.secretOf(name) + "/versions/latest", true
+	}
+	return "", false
+}
+
+// Lookup reads the secret for name. A variable without a secret, or whose
+// secret does not exist, is unset.
+func (s *Source) Lookup(name string) (string, bool, error) {
+	return s.LookupContext(s.ctx, name)
+}
+
+// LookupContext is like Lookup, reading the secret with ctx in place of the
+// context set by WithContext. The timeout still applies.
+func (s *Source) LookupContext(ctx context.Context, name string) (string, bool, error) {
+	resource, ok := s.Locate(name)
+	if !ok {
+		return "", false, nil
+	}
+
+	s.mu.Lock()
+	v, ok := s.fetched[resource]
+	s.mu.Unlock()
+	if ok {
+		return v, true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	data, err := s.client.AccessSecretVersion(ctx, resource)
+	if errors.Is(err, ErrNotFound) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("Reading %s failed: %v", resource, err)
+	}
+
+	s.mu.Lock()
+	s.fetched[resource] = string(data)
+	s.mu.Unlock()
+	return string(data), true, nil
+}
+
+// Refresh discards the secrets read, so that they are read again on their
+// next lookup.
+func (s *Source) Refresh() {
+	s.mu.Lock()
+	s.fetched = make(map[string]string)
+	s.mu.Unlock()
+}
+
+// Name identifies the source in errors and reports.
+func (s *Source) Name() string {
+	if len(s.project) > 0 {
+		return "gcp-secret-manager(" + s.project + ")"
+	}
+	return "gcp-secret-manager"
+}
@@ -0,0 +1,137 @@
+package gcpsecretsrc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// fakeSecretManager serves secret versions from a map, optionally slowly.
+type fakeSecretManager struct {
+	versions map[string]string
+	delay    time.Duration
+	calls    int
+}
+
+func (f *fakeSecretManager) AccessSecretVersion(ctx context.Context, name string) ([]byte, error) {
+	f.calls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if v, ok := f.versions[name]; ok {
+		return []byte(v), nil
+	}
+	return nil, ErrNotFound
+}
+
+type appConfig struct {
+	Port       int
+	DBPassword string `secret:"projects/shared/secrets/db-password" required:"true"`
+	APIKey     string `secret:"projects/shared/secrets/api-key/versions/2"`
+	Token      string `secret:"true"`
+}
+
+func newFake() *fakeSecretManager {
+	return &fakeSecretManager{versions: map[string]string{
+		"projects/shared/secrets/db-password/versions/latest": "hunter2",
+		"projects/shared/secrets/api-key/versions/2":          "k-2",
+		"projects/myapp/secrets/TOKEN/versions/latest":        "t-1",
+		"projects/myapp/secrets/PORT/versions/latest":         "1",
+	}}
+}
+
+func TestSource(t *testing.T) {
+	fake := newFake()
+	src := New(fake)
+	d := envconf.NewDecoder(envconf.WithSource(
+		envconf.LayerSources(envconf.MapSource{"PORT": "8080"}, src)))
+	if err := src.UseTags(d, &appConfig{}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	var conf appConfig
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if expect := (appConfig{8080, "hunter2", "k-2", ""}); conf != expect {
+		t.Errorf("Expected %+v, got %+v", expect, conf)
+		t.Fail()
+	}
+	if fake.calls != 2 {
+		t.Errorf("Expected only tagged fields to be read, got %d calls", fake.calls)
+		t.Fail()
+	}
+
+	// With a project, untagged variables are read from it.
+	src = New(fake, WithProject("myapp"))
+	d = envconf.NewDecoder(envconf.WithSource(src))
+	src.UseTags(d, &appConfig{})
+	conf = appConfig{}
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 1 || conf.Token != "t-1" {
+		t.Errorf("Expected untagged fields from the project, got %+v", conf)
+		t.Fail()
+	}
+
+	resources, _ := d.Manifest(&conf, src)
+	expect := []string{
+		"projects/myapp/secrets/PORT/versions/latest",
+		"projects/myapp/secrets/TOKEN/versions/latest",
+		"projects/shared/secrets/api-key/versions/2",
+		"projects/shared/secrets/db-password/versions/latest",
+	}
+	if !reflect.DeepEqual(resources, expect) {
+		t.Errorf("Manifest(): expected %v, got %v", expect, resources)
+		t.Fail()
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	fake := newFake()
+	fake.delay = time.Second
+	src := New(fake, WithTimeout(10*time.Millisecond))
+	d := envconf.NewDecoder(envconf.WithSource(src))
+	src.UseTags(d, &appConfig{})
+
+	var conf appConfig
+	err := d.Decode(&conf)
+	match := "Reading projects/shared/secrets/db-password/versions/latest failed: context deadline exceeded"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src = New(newFake(), WithContext(ctx))
+	d = envconf.NewDecoder(envconf.WithSource(src))
+	src.UseTags(d, &appConfig{})
+	if err := d.Decode(&conf); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected an error matching '%s', got '%v'", "context canceled", err)
+		t.Fail()
+	}
+
+	var bad struct {
+		Key string `secret:"secrets/key"`
+	}
+	err = New(fake).UseTags(envconf.NewDecoder(), &bad)
+	match = `Invalid secret for config field Key: "secrets/key" (expected projects/p/secrets/name)`
+	if err == nil || err.Error() != match {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,113 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A PostLoader is a config struct with work to do once it has been decoded,
+// such as checking that MinWorkers <= MaxWorkers or deriving values from
+// other fields.
+type PostLoader interface {
+	PostLoad() error
+}
+
+// A Warner reports conditions which are allowed but deserve attention, such
+// as disabled certificate verification. Once a struct implementing Warner has
+// been loaded and validated, each of its warnings is passed to the Decoder's
+// warning func. A Source implementing Warner, as LastKnownGood does, has its
+// warnings passed on after each decode.
+type Warner interface {
+	Warnings() []error
+}
+
+// postLoad calls the PostLoad, Validate and Warnings hooks of v's nested
+// structs and then of v itself. path leads to v from the config struct.
+//
+// Structs tagged fetch:"lazy" are skipped unless lazy is true, as they are
+// read by DecodeLazy. If lazy is true, only the hooks of those structs, and
+// of the structs within them, are called.
+func (d *Decoder) postLoad(v reflect.Value, path []string, lazy bool) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		fieldVal := v.Field(i)
+		if !d.forCommand(field) {
+			// not loaded for this command
+			continue
+		}
+		fieldLazy := field.Tag.Get("fetch") == "lazy"
+		if fieldLazy && !lazy {
+			// not loaded until DecodeLazy
+			continue
+		}
+		// within a lazy struct, every hook is called
+		nestedLazy := lazy && !fieldLazy
+		if isIndexable(field.Type) && len(field.PkgPath) == 0 {
+			for j := 0; j < fieldVal.Len(); j++ {
+				elemPath := append(path[:len(path):len(path)], field.Name, strconv.Itoa(j))
+				if err := d.postLoad(fieldVal.Index(j), elemPath, nestedLazy); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if isStructPtr(field.Type) && !fieldVal.IsNil() {
+			fieldVal = fieldVal.Elem()
+		} else if field.Type.Kind() != reflect.Struct {
+			continue
+		}
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+		if reflect.PtrTo(field.Type).Implements(envDecoderType) ||
+			reflect.PtrTo(field.Type).Implements(lazyBinderType) {
+			continue
+		}
+
+		fieldPath := path
+		if !field.Anonymous {
+			fieldPath = append(path[:len(path):len(path)], field.Name)
+		}
+		if err := d.postLoad(fieldVal, fieldPath, nestedLazy); err != nil {
+			return err
+		}
+	}
+
+	if lazy || !v.CanAddr() || !v.CanInterface() {
+		// unexported embedded structs' hooks can't be called
+		return nil
+	}
+	conf := v.Addr().Interface()
+	if err := callHooks(conf); err == nil {
+		if w, ok := conf.(Warner); ok {
+			for _, warning := range w.Warnings() {
+				if len(path) > 0 {
+					warning = fmt.Errorf(
+						"Config field %s: %v", strings.Join(path, "."), warning)
+				}
+				d.warn(warning)
+			}
+		}
+		return nil
+	} else if len(path) == 0 {
+		return fmt.Errorf("Invalid config: %v", err)
+	} else {
+		return fmt.Errorf(
+			"Invalid config field %s: %v", strings.Join(path, "."), err)
+	}
+}
+
+// callHooks calls PostLoad and Validate on conf, if it implements them.
+func callHooks(conf interface{}) error {
+	if pl, ok := conf.(PostLoader); ok {
+		if err := pl.PostLoad(); err != nil {
+			return err
+		}
+	}
+	if val, ok := conf.(Validator); ok {
+		return val.Validate()
+	}
+	return nil
+}
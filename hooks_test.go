@@ -0,0 +1,133 @@
+package envconf
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type workerConfig struct {
+	MinWorkers int `default:"1"`
+	MaxWorkers int `default:"4"`
+}
+
+func (w workerConfig) Validate() error {
+	if w.MinWorkers > w.MaxWorkers {
+		return errors.New("MinWorkers must not exceed MaxWorkers")
+	}
+	return nil
+}
+
+type hookedConfig struct {
+	Workers workerConfig
+	Host    string
+	Port    int    `default:"80"`
+	Addr    string // derived by PostLoad
+
+	calls []string
+}
+
+func (h *hookedConfig) PostLoad() error {
+	h.calls = append(h.calls, "PostLoad")
+	h.Addr = h.Host + ":" + strconv.Itoa(h.Port)
+	return nil
+}
+
+func (h *hookedConfig) Validate() error {
+	h.calls = append(h.calls, "Validate")
+	if len(h.Host) == 0 {
+		return errors.New("Host must be set")
+	}
+	return nil
+}
+
+func TestPostLoadHooks(t *testing.T) {
+	var c hookedConfig
+	if err := ReadConfig(&c, mapgetter{"HOST": "localhost"}.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if c.Addr != "localhost:80" {
+		t.Errorf("PostLoad: expected Addr 'localhost:80', got %q", c.Addr)
+		t.Fail()
+	}
+	if len(c.calls) != 2 || c.calls[0] != "PostLoad" || c.calls[1] != "Validate" {
+		t.Errorf("Expected PostLoad then Validate, got %v", c.calls)
+		t.Fail()
+	}
+
+	tests := []struct {
+		vals     mapgetter
+		errmatch string
+	}{
+		{mapgetter{}, "Invalid config: Host must be set"},
+		{mapgetter{"HOST": "h", "WORKERS_MINWORKERS": "8"}, "Invalid config field Workers: MinWorkers must not exceed MaxWorkers"},
+	}
+	for _, test := range tests {
+		c = hookedConfig{}
+		err := ReadConfig(&c, test.vals.get)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+
+	// hooks don't run when fields failed to decode
+	c = hookedConfig{}
+	if err := ReadConfig(&c, mapgetter{"PORT": "http"}.get); err == nil || len(c.calls) != 0 {
+		t.Errorf("Expected a parse error and no hooks, got '%v' and %v", err, c.calls)
+		t.Fail()
+	}
+}
+
+type warnedConfig struct {
+	Insecure bool
+}
+
+func (w warnedConfig) Warnings() []error {
+	if w.Insecure {
+		return []error{errors.New("insecure mode is enabled")}
+	}
+	return nil
+}
+
+func TestWarner(t *testing.T) {
+	var conf struct {
+		Upstream warnedConfig
+	}
+	var warnings []error
+	d := NewDecoder(
+		WithGetter(mapgetter{"UPSTREAM_INSECURE": "true"}.get),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }),
+	)
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if len(warnings) != 1 || warnings[0].Error() != "Config field Upstream: insecure mode is enabled" {
+		t.Errorf("Expected one warning for Upstream, got %v", warnings)
+		t.Fail()
+	}
+}
+
+func TestPostLoadHooksForCommand(t *testing.T) {
+	var conf struct {
+		Port    int
+		Workers workerConfig `cmd:"worker"`
+	}
+	// the worker's config is invalid, but the serve command doesn't load it
+	conf.Workers = workerConfig{MinWorkers: 8, MaxWorkers: 2}
+	src := MapSource{"PORT": "80", "WORKERS_MINWORKERS": "8", "WORKERS_MAXWORKERS": "2"}
+	if err := NewDecoder(WithSource(src), WithCommand("serve")).Decode(&conf); err != nil {
+		t.Errorf("Decode() for serve: unexpected error %v", err)
+		t.Fail()
+	}
+
+	err := NewDecoder(WithSource(src), WithCommand("worker")).Decode(&conf)
+	match := "Invalid config field Workers: MinWorkers must not exceed MaxWorkers"
+	if err == nil || err.Error() != match {
+		t.Errorf("Decode() for worker: expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
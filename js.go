@@ -0,0 +1,42 @@
+//go:build js && wasm
+
+package envconf
+
+import "syscall/js"
+
+// JSSource is a Source backed by the properties of a JavaScript object, for
+// programs compiled to WebAssembly with GOOS=js. In a browser there is no
+// process environment, so the host page can pass config in an object:
+//
+//	// in the host page
+//	globalThis.appConfig = {PORT: 8080, LOG_LEVEL: "debug"};
+//
+//	// in Go
+//	src := envconf.JSSource{Object: js.Global().Get("appConfig")}
+//	err := envconf.ReadConfigSource(&conf, src)
+//
+// Under Node, the process environment is already given to the program by
+// wasm_exec.js, so EnvSource can be used as elsewhere.
+type JSSource struct {
+	Object js.Value
+}
+
+// Lookup returns the property key of the object, converted to a string as by
+// JavaScript's String. Properties which are undefined or null are unset, as
+// are all properties of an undefined or null object.
+func (s JSSource) Lookup(key string) (string, bool, error) {
+	if s.Object.IsUndefined() || s.Object.IsNull() {
+		return "", false, nil
+	}
+	v := s.Object.Get(key)
+	if v.IsUndefined() || v.IsNull() {
+		return "", false, nil
+	}
+	if v.Type() == js.TypeString {
+		return v.String(), true, nil
+	}
+	return js.Global().Get("String").Invoke(v).String(), true, nil
+}
+
+// Name returns "js".
+func (JSSource) Name() string { return "js" }
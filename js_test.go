@@ -0,0 +1,36 @@
+//go:build js && wasm
+
+package envconf
+
+import (
+	"syscall/js"
+	"testing"
+)
+
+func TestJSSource(t *testing.T) {
+	obj := js.Global().Get("Object").New()
+	obj.Set("PORT", 8080)
+	obj.Set("NAME", "app")
+	obj.Set("DEBUG", true)
+	obj.Set("LABEL", js.Null())
+
+	var conf struct {
+		Port  int
+		Name  string
+		Debug bool
+		Label string `default:"none"`
+	}
+	if err := ReadConfigSource(&conf, JSSource{Object: obj}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 8080 || conf.Name != "app" || !conf.Debug || conf.Label != "none" {
+		t.Errorf("Unexpected config %+v", conf)
+		t.Fail()
+	}
+
+	if _, ok, _ := (JSSource{Object: js.Undefined()}).Lookup("PORT"); ok {
+		t.Errorf("Expected an undefined object to serve nothing")
+		t.Fail()
+	}
+}
@@ -0,0 +1,62 @@
+package envconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// JSONSource returns a Source serving the values in a JSON document, which
+// may be flat or nested. Keys are mapped to variable names as by Flatten with
+// DefaultNamer, so {"server": {"port": 8080}} serves SERVER_PORT. This lets a
+// JSON config file and the environment feed the same struct, for example
+// through LayerSources.
+func JSONSource(data []byte) (MapSource, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("Invalid JSON config: expected an object")
+	}
+
+	return MapSource(Flatten(doc, DefaultNamer)), nil
+}
+
+// JSONFileSource is like JSONSource, but reads the document from the file at
+// path.
+func JSONFileSource(path string) (MapSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := JSONSource(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return src, nil
+}
+
+// isJSONField reports whether field is tagged json:"true", and so is read
+// from a JSON value. Other values of the tag, such as the names given to
+// encoding/json, are ignored.
+func isJSONField(field reflect.StructField) bool {
+	return field.Tag.Get("json") == "true"
+}
+
+// setJSON unmarshals the JSON value input into fieldVal, replacing its
+// value.
+func (d *Decoder) setJSON(field reflect.StructField, fieldVal reflect.Value, input string) error {
+	v := reflect.New(field.Type)
+	if err := json.Unmarshal([]byte(input), v.Interface()); err != nil {
+		return fmt.Errorf("Invalid JSON for config field %s: %v", field.Name, err)
+	}
+	fieldVal.Set(v.Elem())
+	return d.canonicalize(fieldVal)
+}
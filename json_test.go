@@ -0,0 +1,147 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testJSON = `{
+	"port": 8080,
+	"ratio": 0.5,
+	"debug": true,
+	"server": {"host": "localhost", "tls": {"certFile": "/etc/cert.pem"}},
+	"tags": ["a", "b"],
+	"upstreams": [{"host": "a", "port": 1}, {"host": "b"}],
+	"nothing": null
+}`
+
+func TestJSONSource(t *testing.T) {
+	src, err := JSONSource([]byte(testJSON))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	expect := MapSource{
+		"PORT":                "8080",
+		"RATIO":               "0.5",
+		"DEBUG":               "true",
+		"SERVER_HOST":         "localhost",
+		"SERVER_TLS_CERTFILE": "/etc/cert.pem",
+		"TAGS":                "a,b",
+		"UPSTREAMS_0_HOST":    "a",
+		"UPSTREAMS_0_PORT":    "1",
+		"UPSTREAMS_1_HOST":    "b",
+	}
+	if !reflect.DeepEqual(src, expect) {
+		t.Errorf("JSONSource(): expected %v, got %v", expect, src)
+		t.Fail()
+	}
+
+	for _, bad := range []string{`[1, 2]`, `{"a": `} {
+		if _, err := JSONSource([]byte(bad)); err == nil {
+			t.Errorf("JSONSource(%q): expected an error", bad)
+			t.Fail()
+		}
+	}
+}
+
+func TestJSONFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(testJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := JSONFileSource(path)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	var conf struct {
+		Port   int
+		Debug  bool
+		Tags   []string
+		Server struct {
+			Host string
+			TLS  struct {
+				CertFile string
+			}
+		}
+	}
+	// the environment takes precedence over the file
+	layers := LayerSources(MapSource{"PORT": "9000"}, src)
+	if err := ReadConfigSource(&conf, layers); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 9000 || !conf.Debug || len(conf.Tags) != 2 || conf.Server.TLS.CertFile != "/etc/cert.pem" {
+		t.Errorf("ReadConfigSource(): got %+v", conf)
+		t.Fail()
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JSONFileSource(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("JSONFileSource(): expected an error naming the file, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestJSONTag(t *testing.T) {
+	type route struct {
+		Path    string   `json:"path"`
+		Methods []string `json:"methods"`
+	}
+	// go vet reports more than one json:"true" field in a struct, as
+	// encoding/json would read them all from the key "true"
+	var conf struct {
+		Matrix [][]int `json:"true"`
+		Nested struct {
+			Routes []route `json:"true"`
+		}
+		Default struct {
+			Route route `json:"true" default:"{\"path\": \"/\"}"`
+		}
+		Meta struct {
+			Flags map[string]bool `json:"true"`
+		}
+		Name string `json:"name"`
+	}
+	env := map[string]string{
+		"MATRIX":        "[[1, 2], [3]]",
+		"NESTED_ROUTES": `[{"path": "/a", "methods": ["GET"]}]`,
+		"META_FLAGS":    `{"beta": true}`,
+		"NAME":          "plain",
+	}
+	if err := ReadConfigMap(&conf, env); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(conf.Matrix, [][]int{{1, 2}, {3}}) ||
+		!reflect.DeepEqual(conf.Nested.Routes, []route{{"/a", []string{"GET"}}}) ||
+		conf.Default.Route.Path != "/" || !conf.Meta.Flags["beta"] || conf.Name != "plain" {
+		t.Errorf("ReadConfigMap(): unexpected %+v", conf)
+		t.Fail()
+	}
+
+	written, err := Write(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if written["MATRIX"] != "[[1,2],[3]]" || written["DEFAULT_ROUTE"] != `{"path":"/","methods":null}` {
+		t.Errorf("Write(): unexpected %v", written)
+		t.Fail()
+	}
+
+	match := "Invalid JSON for config field Matrix"
+	err = ReadConfigMap(&conf, map[string]string{"MATRIX": "[[1, 2]"})
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
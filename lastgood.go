@@ -0,0 +1,233 @@
+package envconf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LastKnownGood wraps a remote Source, such as a secrets manager, with a
+// cache file of the values it last served to a successful decode. When the
+// remote source fails a lookup, the value is served from the cache instead,
+// so that a service can still start while its remote config is unavailable.
+//
+//	lkg := envconf.NewLastKnownGood(remote, "/var/cache/app/config.json")
+//	d := envconf.NewDecoder(envconf.WithSource(
+//		envconf.LayerSources(envconf.EnvSource{}, lkg)))
+//	if err := d.Decode(&conf); err != nil {
+//		return err
+//	}
+//	err := lkg.Save(d, &conf)
+//
+// Serving stale values is reported through Warnings, which the Decoder passes
+// to its warning func. Values of fields with a "secret" tag are never
+// written to the cache file, so a secret which can't be fetched is still
+// reported as a lookup failure.
+type LastKnownGood struct {
+	src  Source
+	path string
+
+	mu     sync.Mutex
+	fresh  map[string]string
+	cache  *goodCache
+	stale  []string
+	srcErr error
+}
+
+// goodCache is the format of a LastKnownGood cache file.
+type goodCache struct {
+	Saved  time.Time         `json:"saved"`
+	Values map[string]string `json:"values"`
+}
+
+// NewLastKnownGood returns a LastKnownGood serving src, with its cache file
+// at path.
+func NewLastKnownGood(src Source, path string) *LastKnownGood {
+	return &LastKnownGood{src: src, path: path, fresh: make(map[string]string)}
+}
+
+// Lookup looks up key in the remote source. If that fails, the cached value
+// is returned; if there is none, the remote source's error is.
+func (c *LastKnownGood) Lookup(key string) (string, bool, error) {
+	return c.LookupContext(context.Background(), key)
+}
+
+// LookupContext is like Lookup, passing ctx to the remote source if it is a
+// ContextSource. A lookup which times out is served from the cache like any
+// other failure.
+func (c *LastKnownGood) LookupContext(ctx context.Context, key string) (string, bool, error) {
+	v, ok, err := lookupContext(ctx, c.src, key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		if ok {
+			c.fresh[key] = v
+		}
+		return v, ok, nil
+	}
+
+	if c.cache == nil {
+		if c.cache, err = c.load(); err != nil {
+			return "", false, err
+		}
+	}
+	v, ok = c.cache.Values[key]
+	if !ok {
+		return "", false, err
+	}
+	if c.srcErr == nil {
+		c.srcErr = err
+	}
+	if !slices.Contains(c.stale, key) {
+		c.stale = append(c.stale, key)
+	}
+	return v, true, nil
+}
+
+// load reads the cache file. A missing file is an empty cache.
+func (c *LastKnownGood) load() (*goodCache, error) {
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return &goodCache{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cache goodCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("Invalid last-known-good cache %s: %v", c.path, err)
+	}
+	return &cache, nil
+}
+
+// Name identifies the source in errors and reports.
+func (c *LastKnownGood) Name() string {
+	return "last-known-good(" + SourceName(c.src) + ")"
+}
+
+// Refresh refreshes the remote source, if it is a Refresher, and forgets
+// the values served so far, so that a later decode starts afresh: Save
+// then writes only what the remote source serves from now on.
+func (c *LastKnownGood) Refresh() {
+	if r, ok := c.src.(Refresher); ok {
+		r.Refresh()
+	}
+	c.mu.Lock()
+	c.fresh = make(map[string]string)
+	c.stale, c.srcErr = nil, nil
+	c.mu.Unlock()
+}
+
+// Stale reports whether any value has been served from the cache file.
+func (c *LastKnownGood) Stale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.stale) > 0
+}
+
+// Warnings reports the values which were served from the cache file.
+func (c *LastKnownGood) Warnings() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.stale) == 0 {
+		return nil
+	}
+	return []error{fmt.Errorf(
+		"Serving stale config from %s saved %v ago, because %s failed (%v): %s",
+		c.path, time.Since(c.cache.Saved).Round(time.Second), SourceName(c.src),
+		c.srcErr, strings.Join(c.stale, ", "))}
+}
+
+// Save writes the values the remote source served to the cache file, leaving
+// out the variables of conf's fields with a "secret" tag, including their
+// aliases and those of secret fields in the elements of slices. It should be
+// called once conf has been decoded successfully by d. Nothing is written
+// while any value is stale, so that a good cache is never replaced by a
+// partial one.
+func (c *LastKnownGood) Save(d *Decoder, conf interface{}) error {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.stale) > 0 {
+		return nil
+	}
+
+	values := make(map[string]string, len(c.fresh))
+	for k, v := range c.fresh {
+		values[k] = v
+	}
+	d.dropSecrets(values, plan)
+
+	data, err := json.MarshalIndent(goodCache{time.Now(), values}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// dropSecrets deletes the variables of plan's secret fields from values:
+// their aliases and defaultFrom variables, every variable read by a secret
+// EnvDecoder or Indexed field, and those of the secret fields in the
+// elements of Indexed fields.
+func (d *Decoder) dropSecrets(values map[string]string, plan []PlannedField) {
+	for _, pf := range plan {
+		switch {
+		case isSecret(pf.Field) && pf.readsPrefix():
+			for k := range values {
+				if strings.HasPrefix(k, pf.Name) {
+					delete(values, k)
+				}
+			}
+		case isSecret(pf.Field):
+			for _, name := range pf.names() {
+				delete(values, name)
+			}
+		case pf.Indexed:
+			for i := 0; hasKeyPrefix(values, pf.Name+strconv.Itoa(i)+"_"); i++ {
+				d.dropSecrets(values, d.elementPlan(pf, i))
+			}
+		}
+	}
+}
+
+// hasKeyPrefix reports whether any key of values starts with prefix.
+func hasKeyPrefix(values map[string]string, prefix string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileAtomic replaces the file at path with data, by way of a temporary
+// file in the same directory, so that readers never see a partial file. The
+// file is readable only by its owner.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,118 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type lkgConfig struct {
+	Host     string `required:"true"`
+	Port     int
+	Password string `secret:"true"`
+}
+
+func TestLastKnownGood(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	remote := MapSource{"HOST": "db.internal", "PORT": "5432", "PASSWORD": "hunter2"}
+
+	var conf lkgConfig
+	lkg := NewLastKnownGood(remote, path)
+	d := NewDecoder(WithSource(lkg))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if err := lkg.Save(d, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "db.internal") || strings.Contains(string(data), "hunter2") {
+		t.Errorf("Expected the cache to hold everything but the secret, got %s", data)
+		t.Fail()
+	}
+
+	// The remote source is down at the next startup.
+	down := failingSource{}
+	var warnings []error
+	lkg = NewLastKnownGood(down, path)
+	d = NewDecoder(
+		WithSource(LayerSources(MapSource{"PASSWORD": "from-env"}, lkg)),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }),
+	)
+	conf = lkgConfig{}
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Host != "db.internal" || conf.Port != 5432 || conf.Password != "from-env" {
+		t.Errorf("Expected config from the cache, got %+v", conf)
+		t.Fail()
+	}
+	if !lkg.Stale() || len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "Serving stale config") ||
+		!strings.Contains(warnings[0].Error(), "connection refused): HOST, PORT") {
+		t.Errorf("Expected a staleness warning, got %v", warnings)
+		t.Fail()
+	}
+
+	// Saving while stale leaves the cache alone.
+	if err := lkg.Save(d, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(data) {
+		t.Errorf("Expected the cache to be left alone while stale")
+		t.Fail()
+	}
+
+	// A secret is never served from the cache.
+	conf = lkgConfig{}
+	err := NewDecoder(WithSource(NewLastKnownGood(down, path)), WithWarningFunc(func(error) {})).Decode(&conf)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected an error matching '%s', got '%v'", "connection refused", err)
+		t.Fail()
+	}
+}
+
+func TestLastKnownGoodSecrets(t *testing.T) {
+	type upstream struct {
+		Host  string
+		Token string `secret:"true"`
+	}
+	var conf struct {
+		Password  string `secret:"true" alias:"PGPASSWORD"`
+		Upstreams []upstream
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	remote := MapSource{
+		"PGPASSWORD":        "hunter2",
+		"UPSTREAMS_0_HOST":  "a.internal",
+		"UPSTREAMS_0_TOKEN": "tok0",
+		"UPSTREAMS_1_HOST":  "b.internal",
+		"UPSTREAMS_1_TOKEN": "tok1",
+	}
+
+	lkg := NewLastKnownGood(remote, path)
+	d := NewDecoder(WithSource(lkg))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if err := lkg.Save(d, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"hunter2", "tok0", "tok1"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected the cache to leave out %s, got %s", secret, data)
+			t.Fail()
+		}
+	}
+	if !strings.Contains(string(data), "b.internal") {
+		t.Errorf("Expected the cache to hold the hosts, got %s", data)
+		t.Fail()
+	}
+}
@@ -0,0 +1,130 @@
+package envconf
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Layers resolves variables from several sources in order of precedence,
+// such as the process environment, then a .env file, then a shared file of
+// defaults. It records which layer supplied each variable.
+type Layers struct {
+	sources []Source
+
+	mu      sync.Mutex
+	origins map[string]int
+}
+
+// Layer returns Layers which look up each variable in getters, in order, and
+// return the first value which is set.
+func Layer(getters ...Getter) *Layers {
+	sources := make([]Source, len(getters))
+	for i, g := range getters {
+		sources[i] = g
+	}
+	return LayerSources(sources...)
+}
+
+// LayerSources returns Layers which look up each variable in sources, in
+// order, and return the first value which is set.
+func LayerSources(sources ...Source) *Layers {
+	return &Layers{
+		sources: sources,
+		origins: make(map[string]int),
+	}
+}
+
+// Lookup returns the first value set for key. A lookup error from any layer
+// is returned immediately, rather than falling through to later layers.
+func (l *Layers) Lookup(key string) (string, bool, error) {
+	return l.LookupContext(context.Background(), key)
+}
+
+// LookupContext is like Lookup, passing ctx to the layers which are
+// ContextSources.
+func (l *Layers) LookupContext(ctx context.Context, key string) (string, bool, error) {
+	for i, src := range l.sources {
+		v, ok, err := lookupContext(ctx, src, key)
+		if err != nil {
+			l.setOrigin(key, -1)
+			return "", false, err
+		}
+		if ok {
+			l.setOrigin(key, i)
+			return v, true, nil
+		}
+	}
+	l.setOrigin(key, -1)
+	return "", false, nil
+}
+
+// setOrigin records that the layer at index i supplied key, or forgets
+// where key came from if i is negative.
+func (l *Layers) setOrigin(key string, i int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i < 0 {
+		delete(l.origins, key)
+	} else {
+		l.origins[key] = i
+	}
+}
+
+// Get returns the first value set for name. It has the signature of a
+// Getter, so a Layers can be used anywhere a Getter is expected:
+//
+//	layers := envconf.Layer(os.Getenv, dotenv)
+//	err := envconf.ReadConfig(&conf, layers.Get)
+func (l *Layers) Get(name string) string {
+	v, _, _ := l.Lookup(name)
+	return v
+}
+
+// Name lists the names of the layers.
+func (l *Layers) Name() string {
+	names := make([]string, len(l.sources))
+	for i, src := range l.sources {
+		names[i] = SourceName(src)
+	}
+	return "layers(" + strings.Join(names, ", ") + ")"
+}
+
+// Origin returns the index of the source which supplied name when it was
+// last looked up. ok is false if name was not found in any layer, or has not
+// been looked up.
+func (l *Layers) Origin(name string) (layer int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	layer, ok = l.origins[name]
+	return
+}
+
+// OriginName is like Origin, but returns the name of the source, or the
+// empty string.
+func (l *Layers) OriginName(name string) string {
+	if i, ok := l.Origin(name); ok {
+		return SourceName(l.sources[i])
+	}
+	return ""
+}
+
+// Warnings collects the warnings of the layers which are Warners.
+func (l *Layers) Warnings() []error {
+	var warnings []error
+	for _, src := range l.sources {
+		if w, ok := src.(Warner); ok {
+			warnings = append(warnings, w.Warnings()...)
+		}
+	}
+	return warnings
+}
+
+// Refresh refreshes the layers which are Refreshers.
+func (l *Layers) Refresh() {
+	for _, src := range l.sources {
+		if r, ok := src.(Refresher); ok {
+			r.Refresh()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package envconf
+
+import "testing"
+
+func TestLayer(t *testing.T) {
+	env := mapgetter{"PORT": "9000"}
+	dotenv := mapgetter{"PORT": "8080", "HOST": "localhost"}
+	defaults := mapgetter{"HOST": "0.0.0.0", "DEBUG": "false"}
+	layers := Layer(env.get, dotenv.get, defaults.get)
+
+	var conf struct {
+		Port  int
+		Host  string
+		Debug bool
+		Name  string
+	}
+	if err := ReadConfig(&conf, layers.Get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 9000 || conf.Host != "localhost" {
+		t.Errorf("ReadConfig(): expected earlier layers to win, got %+v", conf)
+		t.Fail()
+	}
+
+	tests := []struct {
+		name  string
+		layer int
+		ok    bool
+	}{
+		{"PORT", 0, true},
+		{"HOST", 1, true},
+		{"DEBUG", 2, true},
+		{"NAME", 0, false},
+		{"UNREAD", 0, false},
+	}
+	for _, test := range tests {
+		layer, ok := layers.Origin(test.name)
+		if layer != test.layer || ok != test.ok {
+			t.Errorf("Origin(%q): expected %d, %t; got %d, %t", test.name, test.layer, test.ok, layer, ok)
+			t.Fail()
+		}
+	}
+
+	// A variable which is no longer set is forgotten on the next lookup.
+	delete(defaults, "DEBUG")
+	if err := ReadConfig(&conf, layers.Get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if layer, ok := layers.Origin("DEBUG"); ok || layers.OriginName("DEBUG") != "" {
+		t.Errorf("Origin(%q): expected no layer after it was unset, got %d", "DEBUG", layer)
+		t.Fail()
+	}
+}
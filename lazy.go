@@ -0,0 +1,102 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Lazy holds a config value which is looked up and parsed on the first call
+// to Get, rather than during Decode. Decode only records the variable name and
+// the source. Use it for expensive or rarely-used settings:
+//
+//	var conf struct {
+//		ReportBucket envconf.Lazy[string] `required:"true"`
+//	}
+//
+// Tags apply as they would to a field of type T, but are only checked when
+// the value is resolved; a missing required value is an error from Get, not
+// from Decode.
+//
+// Copies of a Lazy share the resolved value. The zero Lazy, which has not
+// been decoded, returns an error from Get.
+type Lazy[T any] struct {
+	state *lazyState[T]
+}
+
+type lazyState[T any] struct {
+	once    sync.Once
+	name    string
+	resolve func(reflect.Value) error
+	val     T
+	err     error
+}
+
+// Get resolves the value on the first call and caches the result, including
+// any error. It is safe for concurrent use.
+func (l Lazy[T]) Get() (T, error) {
+	if l.state == nil {
+		var zero T
+		return zero, fmt.Errorf("Lazy config value was not decoded")
+	}
+
+	l.state.once.Do(func() {
+		l.state.err = l.state.resolve(reflect.ValueOf(&l.state.val).Elem())
+	})
+	return l.state.val, l.state.err
+}
+
+// Name returns the variable name recorded by Decode.
+func (l Lazy[T]) Name() string {
+	if l.state == nil {
+		return ""
+	}
+	return l.state.name
+}
+
+func (l *Lazy[T]) bindLazy(name string, resolve func(reflect.Value) error) {
+	l.state = &lazyState[T]{name: name, resolve: resolve}
+}
+
+// lazyBinder is implemented by pointers to Lazy values.
+type lazyBinder interface {
+	bindLazy(name string, resolve func(reflect.Value) error)
+}
+
+var lazyBinderType = reflect.TypeOf((*lazyBinder)(nil)).Elem()
+
+// lazyValueType returns the type parameter of the Lazy type t.
+func lazyValueType(t reflect.Type) reflect.Type {
+	state, _ := t.FieldByName("state")
+	val, _ := state.Type.Elem().FieldByName("val")
+	return val.Type
+}
+
+// lazyResolver returns the name and resolve func for a Lazy field. resolve
+// reads the field as Decode would and stores it in a value of the Lazy's type
+// parameter.
+func (d *Decoder) lazyResolver(pf PlannedField) (string, func(reflect.Value) error) {
+	// a Lazy outlives the decode which bound it, and its context
+	d = d.withContext(nil)
+	return pf.Name, func(v reflect.Value) error {
+		field := pf.labelled()
+		field.Type = v.Type()
+
+		input, from, err := d.lookup(pf)
+		if err != nil {
+			return err
+		}
+		if len(from) == 0 && field.Tag.Get("required") == "true" {
+			return fmt.Errorf("Missing config fields: %s", pf.Name)
+		} else if len(from) == 0 {
+			if input, err = d.fieldDefault(field); err != nil {
+				return err
+			}
+		}
+		if len(input) == 0 {
+			return nil
+		}
+
+		return d.assign(field, v, input)
+	}
+}
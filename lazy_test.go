@@ -0,0 +1,93 @@
+package envconf
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingGetter counts lookups of each name.
+type countingGetter struct {
+	mu     sync.Mutex
+	vals   mapgetter
+	counts map[string]int
+}
+
+func (c *countingGetter) get(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[name]++
+	return c.vals[name]
+}
+
+func TestLazy(t *testing.T) {
+	var myConf struct {
+		Port    int
+		Report  Lazy[string] `required:"true"`
+		Limits  Lazy[[]int]
+		Retries Lazy[int]    `default:"3"`
+		Missing Lazy[string] `required:"true"`
+		Broken  Lazy[bool]
+	}
+	c := &countingGetter{
+		vals: mapgetter{
+			"PORT":   "80",
+			"REPORT": "s3://reports",
+			"LIMITS": "1,2",
+			"BROKEN": "sure",
+		},
+		counts: make(map[string]int),
+	}
+
+	if err := ReadConfig(&myConf, c.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if c.counts["REPORT"] != 0 {
+		t.Errorf("Decode(): expected REPORT not to be looked up yet")
+		t.Fail()
+	}
+	if name := myConf.Report.Name(); name != "REPORT" {
+		t.Errorf("Name(): expected 'REPORT', got %q", name)
+		t.Fail()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := myConf.Report.Get(); err != nil || v != "s3://reports" {
+				t.Errorf("Report.Get(): expected 's3://reports', got %q, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if c.counts["REPORT"] != 1 {
+		t.Errorf("Get(): expected one lookup of REPORT, got %d", c.counts["REPORT"])
+		t.Fail()
+	}
+
+	if v, err := myConf.Limits.Get(); err != nil || len(v) != 2 || v[1] != 2 {
+		t.Errorf("Limits.Get(): expected [1 2], got %v, %v", v, err)
+		t.Fail()
+	}
+	if v, err := myConf.Retries.Get(); err != nil || v != 3 {
+		t.Errorf("Retries.Get(): expected default 3, got %v, %v", v, err)
+		t.Fail()
+	}
+	if _, err := myConf.Missing.Get(); err == nil || !strings.Contains(err.Error(), "Missing config fields: MISSING") {
+		t.Errorf("Missing.Get(): expected a missing field error, got %v", err)
+		t.Fail()
+	}
+	if _, err := myConf.Broken.Get(); err == nil || !strings.Contains(err.Error(), "strconv.ParseBool: ") {
+		t.Errorf("Broken.Get(): expected a parse error, got %v", err)
+		t.Fail()
+	}
+
+	var zero Lazy[int]
+	if _, err := zero.Get(); err == nil {
+		t.Errorf("Get(): expected an error from an undecoded Lazy")
+		t.Fail()
+	}
+}
@@ -0,0 +1,135 @@
+package envconf
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// An EventKind says what happened in an Event.
+type EventKind int
+
+const (
+	// EventLookup means a variable was looked up and found.
+	EventLookup EventKind = iota
+	// EventMiss means a variable was looked up and was unset.
+	EventMiss
+	// EventDefault means a field's variables were unset, and its "default"
+	// tag was applied.
+	EventDefault
+	// EventParseError means a field's value couldn't be parsed, or failed
+	// validation.
+	EventParseError
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventLookup:
+		return "lookup"
+	case EventMiss:
+		return "miss"
+	case EventDefault:
+		return "default"
+	case EventParseError:
+		return "parse-error"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// An Event records one step a Decoder took while reading a config, for
+// WithLogger.
+type Event struct {
+	Kind EventKind
+	// Field is the path of Go field names, joined with ".".
+	Field string
+	// Variable is the variable looked up or, for EventDefault and
+	// EventParseError, the field's variable.
+	Variable string
+	// Source is the name of the Source a variable was found in, for
+	// EventLookup, as in a Report.
+	Source string
+	// Value is the value found or the default applied. The values of secret
+	// fields are replaced by Redacted.
+	Value string
+	// Err is the error, for EventParseError.
+	Err error
+}
+
+// String describes the event on one line.
+func (e Event) String() string {
+	switch e.Kind {
+	case EventLookup:
+		return fmt.Sprintf("%s %s: %s=%q from %s", e.Kind, e.Field, e.Variable, e.Value, e.Source)
+	case EventMiss:
+		return fmt.Sprintf("%s %s: %s is unset", e.Kind, e.Field, e.Variable)
+	case EventDefault:
+		return fmt.Sprintf("%s %s: %s=%q", e.Kind, e.Field, e.Variable, e.Value)
+	}
+	return fmt.Sprintf("%s %s: %s: %v", e.Kind, e.Field, e.Variable, e.Err)
+}
+
+// WithLogger sets a func which is passed an Event for each step of each
+// decode: every variable looked up, found or not, every default applied and
+// every value which couldn't be used. It is meant for debugging what the
+// Decoder did when a program starts with the wrong config.
+func WithLogger(logger func(Event)) Option {
+	return func(d *Decoder) { d.logger = logger }
+}
+
+// DebugVariable is the variable conventionally used with WithDebug.
+const DebugVariable = "ENVCONF_DEBUG"
+
+// WithDebug logs the Events of each decode with the standard log package
+// when the variable name is set to a true value, such as ENVCONF_DEBUG=1,
+// so that an operator can see what a misconfigured program read without
+// rebuilding it. name is looked up in the Decoder's Source, without the
+// prefix. A logger set by WithLogger takes precedence.
+func WithDebug(name string) Option {
+	return func(d *Decoder) { d.debug = name }
+}
+
+// withDebug returns a copy of the Decoder which logs Events if its debug
+// variable is set, or the Decoder itself.
+func (d *Decoder) withDebug() (*Decoder, error) {
+	if len(d.debug) == 0 || d.logger != nil {
+		return d, nil
+	}
+	v, _, err := lookupContext(d.ctx, d.source, d.debug)
+	if err != nil {
+		return nil, &LookupError{Name: d.debug, Err: err}
+	} else if len(v) == 0 {
+		return d, nil
+	}
+	on, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %v", d.debug, err)
+	} else if !on {
+		return d, nil
+	}
+
+	dd := *d
+	dd.logger = logEvent
+	return &dd, nil
+}
+
+func logEvent(e Event) {
+	log.Printf("envconf: %v", e)
+}
+
+// logLookup logs the lookup of name for pf.
+func (d *Decoder) logLookup(pf PlannedField, name, value string, set bool) {
+	e := Event{Kind: EventMiss, Field: strings.Join(pf.Path, "."), Variable: name}
+	if set {
+		e.Kind, e.Source, e.Value = EventLookup, d.sourceOf(name), redacted(pf, value)
+	}
+	d.logger(e)
+}
+
+// redacted returns value, or Redacted if pf is a secret.
+func redacted(pf PlannedField, value string) string {
+	if isSecret(pf.Field) {
+		return Redacted
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+package envconf
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+	var conf struct {
+		Port    int    `default:"8080"`
+		Host    string `alias:"HOSTNAME"`
+		Token   string `secret:"true"`
+		Workers int
+	}
+	var events []Event
+	d := NewDecoder(
+		WithSource(MapSource{"HOSTNAME": "db", "TOKEN": "hunter2", "WORKERS": "many"}),
+		WithLogger(func(e Event) { events = append(events, e) }),
+	)
+	err := d.Decode(&conf)
+	if err == nil {
+		t.Errorf("Decode(): expected an error for WORKERS")
+		t.FailNow()
+	}
+
+	expect := []Event{
+		{Kind: EventMiss, Field: "Port", Variable: "PORT"},
+		{Kind: EventDefault, Field: "Port", Variable: "PORT", Value: "8080"},
+		{Kind: EventMiss, Field: "Host", Variable: "HOST"},
+		{Kind: EventLookup, Field: "Host", Variable: "HOSTNAME", Source: "map", Value: "db"},
+		{Kind: EventLookup, Field: "Token", Variable: "TOKEN", Source: "map", Value: Redacted},
+		{Kind: EventLookup, Field: "Workers", Variable: "WORKERS", Source: "map", Value: "many"},
+		{Kind: EventParseError, Field: "Workers", Variable: "WORKERS", Err: err},
+	}
+	if !reflect.DeepEqual(events, expect) {
+		t.Errorf("Expected events %+v, got %+v", expect, events)
+		t.Fail()
+	}
+	if s := events[3].String(); s != `lookup Host: HOSTNAME="db" from map` {
+		t.Errorf("Event.String(): unexpected %q", s)
+		t.Fail()
+	}
+}
+
+func TestWithDebug(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	var conf struct{ Port int }
+	for _, debug := range []string{"", "0", "1"} {
+		buf.Reset()
+		d := NewDecoder(WithSource(MapSource{"APP_PORT": "80", DebugVariable: debug}),
+			WithPrefix("app_"), WithDebug(DebugVariable))
+		if err := d.Decode(&conf); err != nil {
+			t.Errorf("Unexpected error %v", err)
+			t.FailNow()
+		}
+		logged := strings.Contains(buf.String(), `envconf: lookup Port: APP_PORT="80" from map`)
+		if logged != (debug == "1") {
+			t.Errorf("Decode() with %s=%q: unexpected log %q", DebugVariable, debug, buf.String())
+			t.Fail()
+		}
+	}
+
+	err := NewDecoder(WithSource(MapSource{DebugVariable: "verbose"}), WithDebug(DebugVariable)).Decode(&conf)
+	errmatch := "Invalid ENVCONF_DEBUG"
+	if err == nil || !strings.Contains(err.Error(), errmatch) {
+		t.Errorf("expected an error matching '%s', got '%v'", errmatch, err)
+		t.Fail()
+	}
+}
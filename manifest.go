@@ -0,0 +1,41 @@
+package envconf
+
+import "sort"
+
+// A Locator maps a variable name to the remote resource a source reads for
+// it, such as an SSM parameter ARN or a Vault secret path. ok is false for
+// names the source does not serve remotely.
+type Locator interface {
+	Locate(name string) (resource string, ok bool)
+}
+
+// Manifest returns the remote resources that Decode would read for conf
+// through l, sorted and without duplicates. It performs no lookups, so it can
+// be used to generate least-privilege access policies ahead of deployment.
+//
+// Variables named by "alias" and "defaultFrom" tags are included. Fields
+// whose types implement EnvDecoder choose their own variables and are not
+// included.
+func (d *Decoder) Manifest(conf interface{}, l Locator) ([]string, error) {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var resources []string
+	for _, pf := range plan {
+		if pf.readsPrefix() {
+			continue
+		}
+		for _, name := range pf.names() {
+			if r, ok := l.Locate(name); ok && !seen[r] {
+				seen[r] = true
+				resources = append(resources, r)
+			}
+		}
+	}
+
+	sort.Strings(resources)
+	return resources, nil
+}
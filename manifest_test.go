@@ -0,0 +1,43 @@
+package envconf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ssmLocator serves SECRET_ variables from a parameter store path.
+type ssmLocator string
+
+func (l ssmLocator) Locate(name string) (string, bool) {
+	if !strings.HasPrefix(name, "SECRET_") {
+		return "", false
+	}
+	return string(l) + strings.ToLower(name), true
+}
+
+func TestManifest(t *testing.T) {
+	var myConf struct {
+		SecretToken   string
+		SecretReplica string `defaultFrom:"SECRET_DB"`
+		SecretDB      string
+		Port          int
+	}
+	expect := []string{
+		"arn:aws:ssm:::parameter/app/secret_db",
+		"arn:aws:ssm:::parameter/app/secret_replica",
+		"arn:aws:ssm:::parameter/app/secret_token",
+	}
+
+	got, err := NewDecoder(WithNamer(NamerFunc(func(p []string) string {
+		return strings.ToUpper(strings.Replace(p[0], "Secret", "Secret_", 1))
+	}))).Manifest(&myConf, ssmLocator("arn:aws:ssm:::parameter/app/"))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Manifest(): expected %v, got %v", expect, got)
+		t.Fail()
+	}
+}
@@ -0,0 +1,62 @@
+package envconf
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the variables of the schema to w as a Markdown
+// table of their names, types, defaults, whether they are required, and
+// descriptions. The build info is not written, so the table only changes
+// when the config does.
+func (s *Schema) WriteMarkdown(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("| Variable | Type | Default | Required | Description |\n")
+	bw.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, v := range s.Variables {
+		name := "`" + v.Name + "`"
+		if v.Prefix {
+			name = "`" + v.Name + "*`"
+		}
+
+		var def []string
+		if len(v.DefaultFrom) > 0 {
+			def = append(def, "`$"+v.DefaultFrom+"`")
+		}
+		if len(v.Default) > 0 {
+			def = append(def, "`"+v.Default+"`")
+		}
+
+		required := "no"
+		if v.Required {
+			required = "yes"
+		} else if len(v.RequiredIf) > 0 {
+			required = "if `" + v.RequiredIf + "`"
+		}
+
+		desc := v.Description
+		if v.Secret {
+			desc = strings.TrimSpace(desc + " (secret)")
+		}
+
+		cells := []string{name, "`" + v.Type + "`", strings.Join(def, " or "), required, desc}
+		for i, cell := range cells {
+			cells[i] = markdownCellReplacer.Replace(cell)
+		}
+		bw.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return bw.Flush()
+}
+
+var markdownCellReplacer = strings.NewReplacer("|", `\|`, "\n", " ")
+
+// Markdown writes the variables the Decoder reads for conf to w as a
+// Markdown table; see Schema.WriteMarkdown.
+func (d *Decoder) Markdown(w io.Writer, conf interface{}) error {
+	s, err := d.Schema(conf)
+	if err != nil {
+		return err
+	}
+	return s.WriteMarkdown(w)
+}
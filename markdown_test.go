@@ -0,0 +1,37 @@
+package envconf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	var conf struct {
+		Port     int    `required:"true" desc:"port to listen on"`
+		Password string `secret:"true"`
+		Replica  string `defaultFrom:"PRIMARY" default:"localhost"`
+		Mode     string `oneof:"a,b" desc:"a | b"`
+		Cache    cacheConfig
+		TLS      bool
+		CertFile string `required_if:"TLS=true"`
+	}
+	expect := "| Variable | Type | Default | Required | Description |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `APP_PORT` | `int` |  | yes | port to listen on |\n" +
+		"| `APP_PASSWORD` | `string` |  | no | (secret) |\n" +
+		"| `APP_REPLICA` | `string` | `$APP_PRIMARY` or `localhost` | no |  |\n" +
+		"| `APP_MODE` | `string` |  | no | a \\| b |\n" +
+		"| `APP_CACHE_*` | `envconf.cacheConfig` |  | no |  |\n" +
+		"| `APP_TLS` | `bool` |  | no |  |\n" +
+		"| `APP_CERTFILE` | `string` |  | if `TLS=true` |  |\n"
+
+	var buf bytes.Buffer
+	if err := NewDecoder(WithPrefix("APP_")).Markdown(&buf, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if buf.String() != expect {
+		t.Errorf("Markdown(): expected\n%s\ngot\n%s", expect, buf.String())
+		t.Fail()
+	}
+}
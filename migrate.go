@@ -0,0 +1,86 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Migrate decodes conf while a deployment moves from old, the previous
+// generation of the config struct, to conf's struct. renames maps the path of
+// a field of old, such as "DB.Host", to the path of the field of conf it
+// became, such as "Database.Host".
+//
+// For each renamed field of conf whose variables are unset, the value of the
+// old field's variables is used instead, ahead of any default, and a warning
+// names the variable to set in its place. Once every environment sets the new
+// variables, the old struct and the call to Migrate can be removed.
+//
+//	err := d.Migrate(&conf, v1.Config{}, map[string]string{
+//		"DBHost":    "Database.Host",
+//		"DBTimeout": "Database.Timeout",
+//	})
+func (d *Decoder) Migrate(conf, old interface{}, renames map[string]string) error {
+	oldFields, err := d.fieldsByPath(old)
+	if err != nil {
+		return err
+	}
+	newFields, err := d.fieldsByPath(conf)
+	if err != nil {
+		return err
+	}
+
+	oldPaths := make([]string, 0, len(renames))
+	for path := range renames {
+		oldPaths = append(oldPaths, path)
+	}
+	sort.Strings(oldPaths)
+
+	migrated := make(map[string]string)
+	for _, oldPath := range oldPaths {
+		newPath := renames[oldPath]
+		from, ok := oldFields[oldPath]
+		if !ok {
+			return fmt.Errorf("No config field %s in %T", oldPath, old)
+		}
+		to, ok := newFields[newPath]
+		if !ok {
+			return fmt.Errorf("No config field %s in %T", newPath, conf)
+		}
+
+		if _, name, err := d.lookup(to); err != nil {
+			return err
+		} else if len(name) > 0 {
+			continue
+		}
+		input, name, err := d.lookup(from)
+		if err != nil {
+			return err
+		} else if len(name) == 0 {
+			continue
+		}
+		migrated[to.Name] = input
+		d.warn(fmt.Errorf("Config variable %s was renamed to %s", name, to.Name))
+	}
+
+	if len(migrated) == 0 {
+		return d.Decode(conf)
+	}
+	md := *d
+	md.source = LayerSources(d.source, MapSource(migrated))
+	return md.Decode(conf)
+}
+
+// fieldsByPath plans conf, keying each field by its path joined with ".".
+func (d *Decoder) fieldsByPath(conf interface{}) (map[string]PlannedField, error) {
+	plan, err := d.planType(reflect.TypeOf(conf))
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]PlannedField, len(plan))
+	for _, pf := range plan {
+		fields[strings.Join(pf.Path, ".")] = pf
+	}
+	return fields, nil
+}
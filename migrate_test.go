@@ -0,0 +1,89 @@
+package envconf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type configV1 struct {
+	DBHost    string `required:"true"`
+	DBTimeout time.Duration
+	Workers   int
+}
+
+type configV2 struct {
+	Database struct {
+		Host    string        `required:"true"`
+		Timeout time.Duration `default:"5s"`
+	}
+	Workers int
+}
+
+var renamesV2 = map[string]string{
+	"DBHost":    "Database.Host",
+	"DBTimeout": "Database.Timeout",
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		host     string
+		timeout  time.Duration
+		warnings []string
+	}{
+		{
+			map[string]string{"DBHOST": "old", "DBTIMEOUT": "1s", "WORKERS": "2"},
+			"old", time.Second,
+			[]string{"Config variable DBHOST was renamed to DATABASE_HOST", "Config variable DBTIMEOUT was renamed to DATABASE_TIMEOUT"},
+		},
+		{
+			map[string]string{"DBHOST": "old", "DATABASE_HOST": "new"},
+			"new", 5 * time.Second,
+			nil,
+		},
+		{
+			map[string]string{"DATABASE_HOST": "new", "DATABASE_TIMEOUT": "2s"},
+			"new", 2 * time.Second,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		var warnings []string
+		d := NewDecoder(WithSource(MapSource(test.env)), WithWarningFunc(func(err error) {
+			warnings = append(warnings, err.Error())
+		}))
+		var conf configV2
+		if err := d.Migrate(&conf, configV1{}, renamesV2); err != nil {
+			t.Errorf("Unexpected error %v", err)
+			t.Fail()
+			continue
+		}
+		if conf.Database.Host != test.host || conf.Database.Timeout != test.timeout {
+			t.Errorf("Migrate(%v): expected %s and %v, got %+v", test.env, test.host, test.timeout, conf)
+			t.Fail()
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("Migrate(%v): expected warnings %v, got %v", test.env, test.warnings, warnings)
+			t.Fail()
+		}
+	}
+
+	errTests := []struct {
+		renames  map[string]string
+		errmatch string
+	}{
+		{map[string]string{"DBPort": "Database.Port"}, "No config field DBPort in envconf.configV1"},
+		{map[string]string{"DBHost": "Database.Port"}, "No config field Database.Port in *envconf.configV2"},
+		{nil, "Missing config fields: DATABASE_HOST"},
+	}
+	for _, test := range errTests {
+		var conf configV2
+		err := NewDecoder(WithSource(MapSource{"DBHOST": "old"})).Migrate(&conf, configV1{}, test.renames)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
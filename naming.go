@@ -0,0 +1,106 @@
+package envconf
+
+import (
+	"sort"
+	"strings"
+)
+
+// A Namer derives the variable name for a config field. fieldPath holds the
+// Go field names leading from the config struct to the field; for a flat
+// struct it has a single element.
+//
+// A Namer must always give the same name for the same path. Decoders cache
+// the names of each struct type they read unless their Namer's type isn't
+// comparable, as with NamerFunc; a Namer which is used often should be a
+// comparable type, such as a struct.
+type Namer interface {
+	Name(fieldPath []string) string
+}
+
+// NamerFunc adapts an ordinary function to the Namer interface.
+type NamerFunc func(fieldPath []string) string
+
+// Name calls f(fieldPath).
+func (f NamerFunc) Name(fieldPath []string) string { return f(fieldPath) }
+
+// DefaultNamer upper-cases each element of the field path and joins them
+// with underscores, so Server.Port becomes SERVER_PORT.
+var DefaultNamer Namer = defaultNamer{}
+
+type defaultNamer struct{}
+
+func (defaultNamer) Name(fieldPath []string) string {
+	return strings.ToUpper(strings.Join(fieldPath, "_"))
+}
+
+// PreserveCaseNamer joins the elements of the field path with underscores,
+// keeping the case of the Go field names, so Server.Port becomes Server_Port.
+var PreserveCaseNamer Namer = preserveCaseNamer{}
+
+type preserveCaseNamer struct{}
+
+func (preserveCaseNamer) Name(fieldPath []string) string {
+	return strings.Join(fieldPath, "_")
+}
+
+// WithCaseInsensitive matches variable names regardless of case, so that
+// SERVER_PORT is also read from server_port or Server_Port, as sources such
+// as YAML documents and Consul keys are often written. A variable set in the
+// case the Namer gives is preferred.
+//
+// The variables of a MapSource, an EnvSource, and Layers of these are
+// searched for a match in any case; other sources are tried with the name in
+// lower case.
+func WithCaseInsensitive() Option {
+	return func(d *Decoder) { d.foldCase = true }
+}
+
+// lookupFold looks up the variable whose name matches name regardless of
+// case.
+func (d *Decoder) lookupFold(name string) (string, bool, error) {
+	if keys, ok := sourceKeys(d.source); ok {
+		for _, key := range keys {
+			if key != name && strings.EqualFold(key, name) {
+				return lookupContext(d.ctx, d.source, key)
+			}
+		}
+		return "", false, nil
+	}
+	if lower := strings.ToLower(name); lower != name {
+		return lookupContext(d.ctx, d.source, lower)
+	}
+	return "", false, nil
+}
+
+// sourceKeys lists the variables of src, sorted, if it is a source which can
+// list them.
+func sourceKeys(src Source) ([]string, bool) {
+	var keys []string
+	switch src := src.(type) {
+	case MapSource:
+		for key := range src {
+			keys = append(keys, key)
+		}
+	case EnvSource:
+		env := src.Env
+		if env == nil {
+			env = ProcessEnv
+		}
+		for _, kv := range env.Environ() {
+			key, _, _ := strings.Cut(kv, "=")
+			keys = append(keys, key)
+		}
+	case *Layers:
+		for _, layer := range src.sources {
+			layerKeys, ok := sourceKeys(layer)
+			if !ok {
+				return nil, false
+			}
+			keys = append(keys, layerKeys...)
+		}
+	default:
+		return nil, false
+	}
+	sort.Strings(keys)
+	return keys, true
+}
@@ -0,0 +1,177 @@
+package envconf
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDefaultNamer(t *testing.T) {
+	tests := []struct {
+		path   []string
+		expect string
+	}{
+		{[]string{"Port"}, "PORT"},
+		{[]string{"Server", "Port"}, "SERVER_PORT"},
+		{[]string{"Server", "TLS", "CertFile"}, "SERVER_TLS_CERTFILE"},
+	}
+
+	for _, test := range tests {
+		if name := DefaultNamer.Name(test.path); name != test.expect {
+			t.Errorf("DefaultNamer.Name(%v): expected %q, got %q", test.path, test.expect, name)
+			t.Fail()
+		}
+	}
+}
+
+func TestCustomNamer(t *testing.T) {
+	var conf struct {
+		LogLevel string
+		Server   struct {
+			Port int
+		}
+	}
+	namer := NamerFunc(func(fieldPath []string) string {
+		return strings.ToLower(strings.Join(fieldPath, "."))
+	})
+	input := mapgetter{"loglevel": "debug", "server.port": "80"}
+
+	err := NewDecoder(WithGetter(input.get), WithNamer(namer)).Decode(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.LogLevel != "debug" {
+		t.Errorf("Expected LogLevel 'debug', got %q", conf.LogLevel)
+		t.Fail()
+	}
+	if conf.Server.Port != 80 {
+		t.Errorf("Expected Server.Port 80, got %d", conf.Server.Port)
+		t.Fail()
+	}
+}
+
+func TestPrefixNaming(t *testing.T) {
+	lower := NamerFunc(func(fieldPath []string) string {
+		return strings.ToLower(strings.Join(fieldPath, "_"))
+	})
+	tests := []struct {
+		opts   []Option
+		prefix string
+		name   string
+	}{
+		{[]Option{WithPrefix("MYSERVER_")}, "MYSERVER_", "MYSERVER_SERVER_PORT"},
+		{[]Option{WithPrefix("myserver_")}, "MYSERVER_", "MYSERVER_SERVER_PORT"},
+		{[]Option{WithPrefix("MYSERVER")}, "MYSERVER", "MYSERVERSERVER_PORT"},
+		{[]Option{WithPrefix("MYSERVER"), WithPrefixSeparator("_")}, "MYSERVER_", "MYSERVER_SERVER_PORT"},
+		{[]Option{WithPrefix("MYSERVER_"), WithPrefixSeparator("_")}, "MYSERVER_", "MYSERVER_SERVER_PORT"},
+		{[]Option{WithPrefix("MyServer"), WithPrefixSeparator("_"), WithNamer(lower)}, "myserver_", "myserver_server_port"},
+		{[]Option{WithPrefixSeparator("_")}, "", "SERVER_PORT"},
+	}
+
+	var conf struct {
+		Server struct{ Port int }
+	}
+	for _, test := range tests {
+		d := NewDecoder(test.opts...)
+		if p := d.Prefix(); p != test.prefix {
+			t.Errorf("Prefix(): expected %q, got %q", test.prefix, p)
+			t.Fail()
+		}
+		plan, _ := d.Plan(&conf)
+		if plan[0].Name != test.name {
+			t.Errorf("Plan(): expected %q, got %q", test.name, plan[0].Name)
+			t.Fail()
+		}
+	}
+
+	os.Setenv("ENVCONFTEST_PORT", "8080")
+	defer os.Unsetenv("ENVCONFTEST_PORT")
+	var env struct{ Port int }
+	if err := ReadConfigEnvPrefix("envconftest_", &env); err != nil || env.Port != 8080 {
+		t.Errorf("ReadConfigEnvPrefix(): expected a lower-case prefix to work, got %+v, %v", env, err)
+		t.Fail()
+	}
+}
+
+func TestNestedConfig(t *testing.T) {
+	type TLS struct {
+		CertFile string `required:"true"`
+	}
+	type Common struct {
+		Debug bool
+	}
+	var conf struct {
+		Common
+		Server struct {
+			Port int
+			TLS  TLS
+		}
+	}
+	input := mapgetter{
+		"DEBUG":               "true",
+		"SERVER_PORT":         "443",
+		"SERVER_TLS_CERTFILE": "/etc/cert.pem",
+	}
+
+	if err := ReadConfig(&conf, input.get); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !conf.Debug {
+		t.Errorf("Expected embedded Debug to be true")
+		t.Fail()
+	}
+	if conf.Server.Port != 443 {
+		t.Errorf("Expected Server.Port 443, got %d", conf.Server.Port)
+		t.Fail()
+	}
+	if conf.Server.TLS.CertFile != "/etc/cert.pem" {
+		t.Errorf("Expected Server.TLS.CertFile '/etc/cert.pem', got %q", conf.Server.TLS.CertFile)
+		t.Fail()
+	}
+
+	match := "SERVER_TLS_CERTFILE"
+	err := ReadConfig(&conf, mapgetter{}.get)
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestCaseMatching(t *testing.T) {
+	type config struct {
+		LogLevel string
+		Server   struct {
+			Port int
+		}
+	}
+	tests := []struct {
+		opts   []Option
+		src    Source
+		level  string
+		port   int
+		reason string
+	}{
+		{[]Option{WithNamer(PreserveCaseNamer)}, MapSource{"LogLevel": "debug", "Server_Port": "80"}, "debug", 80, "preserved case"},
+		{[]Option{WithCaseInsensitive()}, MapSource{"loglevel": "debug", "server_port": "80"}, "debug", 80, "lower case map"},
+		{[]Option{WithCaseInsensitive()}, MapSource{"loglevel": "info", "LOGLEVEL": "debug"}, "debug", 0, "exact match preferred"},
+		{[]Option{WithCaseInsensitive()}, LayerSources(MapSource{}, MapSource{"Server_Port": "80"}), "", 80, "layers"},
+		{[]Option{WithCaseInsensitive()}, EnvSource{Env: MapEnv{"logLevel": "debug"}}, "debug", 0, "environment"},
+		{[]Option{WithCaseInsensitive()}, Getter(mapgetter{"loglevel": "debug", "Server_Port": "80"}.get), "debug", 0, "lower case getter"},
+		{[]Option{WithCaseInsensitive(), WithPrefix("app_")}, MapSource{"app_loglevel": "debug"}, "debug", 0, "prefixed"},
+		{nil, MapSource{"loglevel": "debug"}, "", 0, "case sensitive by default"},
+	}
+	for _, test := range tests {
+		var conf config
+		if err := NewDecoder(append(test.opts, WithSource(test.src))...).Decode(&conf); err != nil {
+			t.Errorf("%s: unexpected error %v", test.reason, err)
+			t.Fail()
+			continue
+		}
+		if conf.LogLevel != test.level || conf.Server.Port != test.port {
+			t.Errorf("%s: expected %q and %d, got %+v", test.reason, test.level, test.port, conf)
+			t.Fail()
+		}
+	}
+}
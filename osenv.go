@@ -0,0 +1,48 @@
+package envconf
+
+import (
+	"os"
+	"sort"
+)
+
+// OSEnv is the interface to the environment of the operating system. The
+// process environment is ProcessEnv; other implementations serve platforms
+// whose environment is not reached through package os, and tests which must
+// not depend on the real environment.
+type OSEnv interface {
+	Getenv(key string) string
+	LookupEnv(key string) (string, bool)
+	// Environ returns the environment as "key=value" strings.
+	Environ() []string
+}
+
+// ProcessEnv is the process environment, as read by package os.
+var ProcessEnv OSEnv = processEnv{}
+
+type processEnv struct{}
+
+func (processEnv) Getenv(key string) string            { return os.Getenv(key) }
+func (processEnv) LookupEnv(key string) (string, bool) { return os.LookupEnv(key) }
+func (processEnv) Environ() []string                   { return os.Environ() }
+
+// MapEnv is an OSEnv backed by a map, for hermetic tests.
+type MapEnv map[string]string
+
+// Getenv returns the value for key in the map.
+func (m MapEnv) Getenv(key string) string { return m[key] }
+
+// LookupEnv returns the value for key in the map.
+func (m MapEnv) LookupEnv(key string) (string, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// Environ returns the map as "key=value" strings, sorted by key.
+func (m MapEnv) Environ() []string {
+	env := make([]string, 0, len(m))
+	for k, v := range m {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
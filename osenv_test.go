@@ -0,0 +1,38 @@
+package envconf
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWithOSEnv(t *testing.T) {
+	os.Setenv("PORT", "9999")
+	defer os.Unsetenv("PORT")
+
+	env := MapEnv{"PORT": "8080", "NAME": "app"}
+	var conf struct {
+		Port int
+		Name string
+	}
+	if err := NewDecoder(WithOSEnv(env)).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 8080 || conf.Name != "app" {
+		t.Errorf("Expected config from the injected environment, got %+v", conf)
+		t.Fail()
+	}
+
+	// An explicit source takes precedence.
+	err := NewDecoder(WithOSEnv(env), WithSource(MapSource{"PORT": "1"})).Decode(&conf)
+	if err != nil || conf.Port != 1 {
+		t.Errorf("Expected config from the explicit source, got %+v, %v", conf, err)
+		t.Fail()
+	}
+
+	if environ := strings.Join(env.Environ(), " "); environ != "NAME=app PORT=8080" {
+		t.Errorf("MapEnv.Environ(): unexpected %q", environ)
+		t.Fail()
+	}
+}
@@ -0,0 +1,81 @@
+package envconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OverridesVariable is the conventional variable for WithOverrides.
+const OverridesVariable = "ENVCONF_OVERRIDES"
+
+// WithOverrides gives operators an escape hatch for changing many values
+// without editing unit files or manifests. If the variable name is set, its
+// value holds overrides which take precedence over the Decoder's Source: either
+// an inline list of KEY=VALUE pairs separated by semicolons, or the path of a
+// .env file, as parsed by ParseDotenv:
+//
+//	ENVCONF_OVERRIDES='LOG_LEVEL=debug;WORKERS=1'
+//	ENVCONF_OVERRIDES=/etc/myapp/overrides.env
+//
+// A value containing "=" is taken as an inline list. name is looked up in the
+// Decoder's Source, without the prefix, and the overrides are read afresh by
+// each decode. Keys are variable names as the Decoder reads them, including
+// any prefix; an override to the empty string unsets a variable. Each decode
+// with overrides in effect reports which variables they replaced to the
+// warning func, so that a forgotten override doesn't go unnoticed.
+func WithOverrides(name string) Option {
+	return func(d *Decoder) { d.overrides = name }
+}
+
+// withOverrides returns a copy of the Decoder whose Source is layered under
+// the overrides named by its overrides variable, or the Decoder itself if
+// that is unset.
+func (d *Decoder) withOverrides() (*Decoder, error) {
+	if len(d.overrides) == 0 {
+		return d, nil
+	}
+	spec, _, err := lookupContext(d.ctx, d.source, d.overrides)
+	if err != nil {
+		return nil, &LookupError{Name: d.overrides, Err: err}
+	} else if len(strings.TrimSpace(spec)) == 0 {
+		return d, nil
+	}
+
+	values, err := parseOverrides(spec)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %v", d.overrides, err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	d.warn(fmt.Errorf("Overriding %s from %s", strings.Join(names, ", "), d.overrides))
+
+	od := *d
+	od.source = LayerSources(MapSource(values), d.source)
+	od.overrides = ""
+	return &od, nil
+}
+
+// parseOverrides parses an inline list of overrides or, if spec has no "=",
+// the .env file it names.
+func parseOverrides(spec string) (map[string]string, error) {
+	if !strings.Contains(spec, "=") {
+		return DotenvSource(strings.TrimSpace(spec))
+	}
+	values := make(map[string]string)
+	for _, kv := range strings.Split(spec, ";") {
+		if len(strings.TrimSpace(kv)) == 0 {
+			continue
+		}
+		spl := strings.SplitN(kv, "=", 2)
+		key := strings.TrimSpace(spl[0])
+		if len(spl) != 2 || len(key) == 0 {
+			return nil, fmt.Errorf("%q is not a KEY=VALUE pair", kv)
+		}
+		values[key] = strings.TrimSpace(spl[1])
+	}
+	return values, nil
+}
@@ -0,0 +1,69 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithOverrides(t *testing.T) {
+	type overridesConfig struct {
+		LogLevel string `default:"info"`
+		Workers  int
+		Host     string
+	}
+	file := filepath.Join(t.TempDir(), "overrides.env")
+	if err := os.WriteFile(file, []byte("# incident 123\nAPP_WORKERS=1\n"), 0600); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		overrides string
+		expect    overridesConfig
+		warning   string
+		errmatch  string
+	}{
+		{"", overridesConfig{"info", 8, "db"}, "", ""},
+		{"APP_LOGLEVEL=debug; APP_WORKERS = 2;", overridesConfig{"debug", 2, "db"},
+			"Overriding APP_LOGLEVEL, APP_WORKERS from ENVCONF_OVERRIDES", ""},
+		{"APP_HOST=", overridesConfig{"info", 8, ""},
+			"Overriding APP_HOST from ENVCONF_OVERRIDES", ""},
+		{file, overridesConfig{"info", 1, "db"},
+			"Overriding APP_WORKERS from ENVCONF_OVERRIDES", ""},
+		{"APP_WORKERS=1;debug", overridesConfig{}, "",
+			`Invalid ENVCONF_OVERRIDES: "debug" is not a KEY=VALUE pair`},
+		{"/nonexistent.env", overridesConfig{}, "",
+			"open /nonexistent.env: no such file or directory"},
+	}
+	for _, test := range tests {
+		var warnings []string
+		src := MapSource{"APP_WORKERS": "8", "APP_HOST": "db", "ENVCONF_OVERRIDES": test.overrides}
+		d := NewDecoder(WithSource(src), WithPrefix("APP_"), WithOverrides(OverridesVariable),
+			WithWarningFunc(func(err error) { warnings = append(warnings, err.Error()) }))
+
+		var conf overridesConfig
+		err := d.Decode(&conf)
+		if len(test.errmatch) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+				t.Errorf("Decode(%q): expected an error matching '%s', got '%v'", test.overrides, test.errmatch, err)
+				t.Fail()
+			}
+			continue
+		}
+		if err != nil || conf != test.expect {
+			t.Errorf("Decode(%q): expected %+v, got %+v, %v", test.overrides, test.expect, conf, err)
+			t.Fail()
+		}
+		var expectWarnings []string
+		if len(test.warning) > 0 {
+			expectWarnings = []string{test.warning}
+		}
+		if !reflect.DeepEqual(warnings, expectWarnings) {
+			t.Errorf("Decode(%q): expected warnings %q, got %q", test.overrides, expectWarnings, warnings)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package envconf
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Parser parses a variable's value into a value of the type it is
+// registered for.
+type Parser func(input string) (interface{}, error)
+
+// parsers maps types to the Parsers registered for them with RegisterParser.
+var parsers sync.Map
+
+// parsersGen counts the calls to RegisterParser, so that plans made before a
+// parser was registered are not reused after.
+var parsersGen atomic.Uint64
+
+// RegisterParser teaches envconf to read values of type t, such as types from
+// other packages which don't implement encoding.TextUnmarshaler:
+//
+//	envconf.RegisterParser(reflect.TypeOf(decimal.Decimal{}),
+//		func(s string) (interface{}, error) { return decimal.NewFromString(s) })
+//
+// parse is used for fields of type t and for the elements, keys and values of
+// slices and maps of t, in place of envconf's own parsing. It must return a
+// value of type t. Values of t are written back by Write and Dump with their
+// String method.
+//
+// Registering a parser for a type again replaces it. Parsers should be
+// registered before any config of their types is decoded, such as from an
+// init function.
+//
+// Parsers for these types are registered by envconf itself:
+//   - *time.Location, loaded with time.LoadLocation from the name of a time
+//     zone such as Europe/London; programs which may run without a time
+//     zone database installed should import time/tzdata;
+//   - *regexp.Regexp, compiled as the config is decoded, so that an invalid
+//     pattern is reported then rather than when it is first used;
+//   - *big.Int and *big.Rat, for amounts which don't fit an int or can't be
+//     rounded like a float64, such as 1000000000000000000000 or 1/3.
+func RegisterParser(t reflect.Type, parse Parser) {
+	parsers.Store(t, parse)
+	parsersGen.Add(1)
+}
+
+// WithParser registers parse for values of type t read by the Decoder, as
+// RegisterParser does for every Decoder. It takes precedence over a parser
+// registered with RegisterParser.
+func WithParser(t reflect.Type, parse Parser) Option {
+	return func(d *Decoder) {
+		if d.parsers == nil {
+			d.parsers = make(map[reflect.Type]Parser)
+		}
+		d.parsers[t] = parse
+	}
+}
+
+func init() {
+	RegisterParser(reflect.TypeOf((*time.Location)(nil)), parseLocation)
+	RegisterParser(reflect.TypeOf((*regexp.Regexp)(nil)), parseRegexp)
+	RegisterParser(reflect.TypeOf((*big.Int)(nil)), parseBigInt)
+	RegisterParser(reflect.TypeOf((*big.Rat)(nil)), parseBigRat)
+}
+
+// parseLocation loads the time zone named by input.
+func parseLocation(input string) (interface{}, error) {
+	loc, err := time.LoadLocation(input)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"unknown time zone %q (expected a name such as Europe/London or UTC)", input)
+	}
+	return loc, nil
+}
+
+// parseRegexp compiles input as a regular expression.
+func parseRegexp(input string) (interface{}, error) {
+	return regexp.Compile(input)
+}
+
+// parseBigInt parses input as an integer of any size, in base 10 or with a
+// 0x, 0o or 0b prefix.
+func parseBigInt(input string) (interface{}, error) {
+	i, ok := new(big.Int).SetString(input, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", input)
+	}
+	return i, nil
+}
+
+// parseBigRat parses input as an exact fraction, such as 1/3 or 0.25.
+func parseBigRat(input string) (interface{}, error) {
+	r, ok := new(big.Rat).SetString(input)
+	if !ok {
+		return nil, fmt.Errorf("invalid rational number %q", input)
+	}
+	return r, nil
+}
+
+// registeredParser returns the Parser registered for t with RegisterParser.
+func registeredParser(t reflect.Type) (Parser, bool) {
+	parse, ok := parsers.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return parse.(Parser), true
+}
+
+// parser returns the Parser the Decoder uses for t, if any.
+func (d *Decoder) parser(t reflect.Type) (Parser, bool) {
+	if parse, ok := d.parsers[t]; ok {
+		return parse, true
+	}
+	return registeredParser(t)
+}
+
+// parses reports whether the Decoder has a Parser for t, so that it is read
+// whole rather than as a struct or slice.
+func (d *Decoder) parses(t reflect.Type) bool {
+	_, ok := d.parser(t)
+	return ok
+}
+
+// setValue parses a single value into v, with the Parser registered for its
+// type if there is one.
+func (d *Decoder) setValue(v reflect.Value, input string) error {
+	if parse, ok := d.parsers[v.Type()]; ok {
+		return setParsed(v, parse, input)
+	}
+	return setValue(v, input)
+}
+
+// setParsed parses input with parse and stores the result in v.
+func setParsed(v reflect.Value, parse Parser, input string) error {
+	out, err := parse(input)
+	if err != nil {
+		return err
+	}
+	pv := reflect.ValueOf(out)
+	if !pv.IsValid() || !pv.Type().AssignableTo(v.Type()) {
+		return fmt.Errorf("Parser for %v returned %T", v.Type(), out)
+	}
+	v.Set(pv)
+	return nil
+}
@@ -0,0 +1,219 @@
+package envconf
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// point stands in for a type from another package, which envconf can't read
+// without a Parser.
+type point struct{ X, Y int }
+
+func (p point) String() string { return fmt.Sprintf("%d,%d", p.X, p.Y) }
+
+func parsePoint(s string) (interface{}, error) {
+	var p point
+	if _, err := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y); err != nil {
+		return nil, fmt.Errorf("invalid point %q", s)
+	}
+	return p, nil
+}
+
+func init() {
+	RegisterParser(reflect.TypeOf(point{}), parsePoint)
+}
+
+func TestRegisterParser(t *testing.T) {
+	var conf struct {
+		Origin point
+		Path   []point          `separator:";"`
+		Marks  map[string]point `separator:";"`
+	}
+	src := MapSource{"ORIGIN": "1,2", "PATH": "0,0;3,4", "MARKS": "home=5,6"}
+	if err := NewDecoder(WithSource(src)).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Origin != (point{1, 2}) || !reflect.DeepEqual(conf.Path, []point{{0, 0}, {3, 4}}) ||
+		conf.Marks["home"] != (point{5, 6}) {
+		t.Errorf("Decode(): unexpected %+v", conf)
+		t.Fail()
+	}
+
+	env, err := NewDecoder().Write(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(env, map[string]string(src)) {
+		t.Errorf("Write(): expected %v, got %v", src, env)
+		t.Fail()
+	}
+}
+
+// span is only registered with a Parser by TestRegisterParserAfterPlan.
+type span struct{ From, To int }
+
+func TestRegisterParserAfterPlan(t *testing.T) {
+	var conf struct{ Hours span }
+	src := MapSource{"HOURS": "9-17", "HOURS_FROM": "1", "HOURS_TO": "2"}
+	d := NewDecoder(WithSource(src))
+	if err := d.Decode(&conf); err != nil || conf.Hours != (span{1, 2}) {
+		t.Errorf("Decode(): expected span read field by field, got %+v, %v", conf, err)
+		t.FailNow()
+	}
+
+	RegisterParser(reflect.TypeOf(span{}), func(s string) (interface{}, error) {
+		var sp span
+		_, err := fmt.Sscanf(s, "%d-%d", &sp.From, &sp.To)
+		return sp, err
+	})
+	if err := d.Decode(&conf); err != nil || conf.Hours != (span{9, 17}) {
+		t.Errorf("Decode(): expected the registered parser to be used, got %+v, %v", conf, err)
+		t.Fail()
+	}
+}
+
+func TestWithParser(t *testing.T) {
+	var conf struct{ Origin point }
+	flipped := func(s string) (interface{}, error) {
+		p, err := parsePoint(s)
+		if err != nil {
+			return nil, err
+		}
+		return point{p.(point).Y, p.(point).X}, nil
+	}
+	d := NewDecoder(WithSource(MapSource{"ORIGIN": "1,2"}),
+		WithParser(reflect.TypeOf(point{}), flipped))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Origin != (point{2, 1}) {
+		t.Errorf("Decode(): expected the Decoder's parser to be used, got %v", conf.Origin)
+		t.Fail()
+	}
+
+	tests := []struct {
+		opts     []Option
+		input    string
+		errmatch string
+	}{
+		{nil, "1;2", `invalid point "1;2"`},
+		{[]Option{WithParser(reflect.TypeOf(point{}), func(s string) (interface{}, error) {
+			return s, nil
+		})}, "1,2", "Parser for envconf.point returned string"},
+	}
+	for _, test := range tests {
+		var conf struct{ Origin point }
+		err := NewDecoder(append(test.opts, WithSource(MapSource{"ORIGIN": test.input}))...).Decode(&conf)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	var conf struct {
+		TZ      *time.Location
+		Reports *time.Location `default:"UTC"`
+	}
+	if err := NewDecoder(WithSource(MapSource{"TZ": "Europe/London"})).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.TZ == nil || conf.TZ.String() != "Europe/London" || conf.Reports != time.UTC {
+		t.Errorf("Decode(): unexpected %v, %v", conf.TZ, conf.Reports)
+		t.Fail()
+	}
+
+	env, err := NewDecoder().Write(&conf)
+	if err != nil || env["TZ"] != "Europe/London" || env["REPORTS"] != "UTC" {
+		t.Errorf("Write(): unexpected %v, %v", env, err)
+		t.Fail()
+	}
+
+	err = NewDecoder(WithSource(MapSource{"TZ": "Mars/Olympus_Mons"})).Decode(&conf)
+	errmatch := `unknown time zone "Mars/Olympus_Mons"`
+	if err == nil || !strings.Contains(err.Error(), errmatch) {
+		t.Errorf("expected an error matching '%s', got '%v'", errmatch, err)
+		t.Fail()
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	var conf struct {
+		Route   *regexp.Regexp
+		Exclude []*regexp.Regexp `separator:" "`
+	}
+	src := MapSource{"ROUTE": `^/api/v[0-9]+/`, "EXCLUDE": `\.git$ ^tmp/`}
+	if err := NewDecoder(WithSource(src)).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Route == nil || !conf.Route.MatchString("/api/v2/users") || len(conf.Exclude) != 2 ||
+		!conf.Exclude[0].MatchString("src/.git") || !conf.Exclude[1].MatchString("tmp/x") {
+		t.Errorf("Decode(): unexpected %v, %v", conf.Route, conf.Exclude)
+		t.Fail()
+	}
+
+	env, err := NewDecoder().Write(&conf)
+	if err != nil || !reflect.DeepEqual(env, map[string]string(src)) {
+		t.Errorf("Write(): expected %v, got %v, %v", src, env, err)
+		t.Fail()
+	}
+
+	err = NewDecoder(WithSource(MapSource{"ROUTE": "(api"})).Decode(&conf)
+	errmatch := "missing closing )"
+	if err == nil || !strings.Contains(err.Error(), errmatch) {
+		t.Errorf("expected an error matching '%s', got '%v'", errmatch, err)
+		t.Fail()
+	}
+}
+
+func TestBig(t *testing.T) {
+	var conf struct {
+		Supply *big.Int
+		Mask   *big.Int
+		Fee    *big.Rat
+		Split  *big.Rat
+	}
+	src := MapSource{"SUPPLY": "1000000000000000000000", "MASK": "0xff", "FEE": "0.0025", "SPLIT": "1/3"}
+	if err := NewDecoder(WithSource(src)).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	supply, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	if conf.Supply.Cmp(supply) != 0 || conf.Mask.Int64() != 255 ||
+		conf.Fee.Cmp(big.NewRat(1, 400)) != 0 || conf.Split.Cmp(big.NewRat(1, 3)) != 0 {
+		t.Errorf("Decode(): unexpected %v, %v, %v, %v", conf.Supply, conf.Mask, conf.Fee, conf.Split)
+		t.Fail()
+	}
+
+	env, err := NewDecoder().Write(&conf)
+	if err != nil || env["SUPPLY"] != "1000000000000000000000" || env["SPLIT"] != "1/3" {
+		t.Errorf("Write(): unexpected %v, %v", env, err)
+		t.Fail()
+	}
+
+	tests := []struct {
+		src      MapSource
+		errmatch string
+	}{
+		{MapSource{"SUPPLY": "1e21"}, `invalid integer "1e21"`},
+		{MapSource{"FEE": "1/0"}, `invalid rational number "1/0"`},
+	}
+	for _, test := range tests {
+		err := NewDecoder(WithSource(test.src)).Decode(&conf)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package envconf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NormalizePEM returns the PEM data in s as a PEM file would hold it. Values
+// passed through the environment often lose their line breaks, so besides
+// plain PEM, s may have its line breaks written as literal \n escapes, as in
+// a .env file or a JSON string, or be base64-encoded PEM, as Kubernetes and
+// most CI systems store files. An error is returned if s holds anything other
+// than PEM blocks.
+func NormalizePEM(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "-----BEGIN") {
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+		if err != nil || !bytes.Contains(b, []byte("-----BEGIN")) {
+			return nil, errors.New("not PEM or base64-encoded PEM")
+		}
+		s = strings.TrimSpace(string(b))
+	}
+	s = strings.NewReplacer(`\r\n`, "\n", `\n`, "\n", "\r\n", "\n").Replace(s)
+
+	rest := []byte(s)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return nil, errors.New("invalid PEM data")
+		}
+	}
+	return []byte(s + "\n"), nil
+}
+
+// PEM holds one or more PEM blocks, such as a certificate chain, read as by
+// NormalizePEM. It suits APIs which take PEM, such as tls.X509KeyPair.
+type PEM []byte
+
+// UnmarshalText normalizes and checks text with NormalizePEM.
+func (p *PEM) UnmarshalText(text []byte) error {
+	b, err := NormalizePEM(string(text))
+	if err != nil {
+		return err
+	}
+	*p = b
+	return nil
+}
+
+// MarshalText returns the PEM data.
+func (p PEM) MarshalText() ([]byte, error) {
+	return p, nil
+}
+
+// String lists the types of the blocks, such as "<PEM: CERTIFICATE>",
+// rather than their contents, which may be secret.
+func (p PEM) String() string {
+	var types []string
+	for rest := []byte(p); ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		types = append(types, block.Type)
+	}
+	return "<PEM: " + strings.Join(types, ", ") + ">"
+}
+
+// Certificates holds a chain of X.509 certificates, leaf first, read from
+// PEM CERTIFICATE blocks as by NormalizePEM.
+type Certificates []*x509.Certificate
+
+// UnmarshalText parses the certificates in text.
+func (c *Certificates) UnmarshalText(text []byte) error {
+	b, err := NormalizePEM(string(text))
+	if err != nil {
+		return err
+	}
+	var certs Certificates
+	for {
+		var block *pem.Block
+		if block, b = pem.Decode(b); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block %s (expected CERTIFICATE)", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	*c = certs
+	return nil
+}
+
+// MarshalText encodes the certificates as PEM.
+func (c Certificates) MarshalText() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, cert := range c {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// String describes the certificates by subject and expiry.
+func (c Certificates) String() string {
+	desc := make([]string, len(c))
+	for i, cert := range c {
+		desc[i] = fmt.Sprintf("%s (expires %s)", cert.Subject, cert.NotAfter.Format("2006-01-02"))
+	}
+	return strings.Join(desc, ", ")
+}
+
+// Leaf returns the first certificate, or nil if there are none.
+func (c Certificates) Leaf() *x509.Certificate {
+	if len(c) == 0 {
+		return nil
+	}
+	return c[0]
+}
+
+// TLSCertificate pairs the certificates with key for use in a tls.Config.
+func (c Certificates) TLSCertificate(key PrivateKey) (tls.Certificate, error) {
+	if len(c) == 0 || key.key == nil {
+		return tls.Certificate{}, errors.New("certificate and key are required")
+	}
+	cert := tls.Certificate{PrivateKey: key.key, Leaf: c[0]}
+	for _, x := range c {
+		cert.Certificate = append(cert.Certificate, x.Raw)
+	}
+	return cert, nil
+}
+
+// PrivateKey holds an RSA, ECDSA or Ed25519 private key, read from a PEM
+// block as by NormalizePEM. PKCS #1, SEC 1 and PKCS #8 keys are understood;
+// encrypted keys are not.
+type PrivateKey struct {
+	key crypto.Signer
+}
+
+// Signer returns the key, or nil if none was set.
+func (k PrivateKey) Signer() crypto.Signer {
+	return k.key
+}
+
+// UnmarshalText parses the key in text.
+func (k *PrivateKey) UnmarshalText(text []byte) error {
+	b, err := NormalizePEM(string(text))
+	if err != nil {
+		return err
+	}
+	block, rest := pem.Decode(b)
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return errors.New("more than one PEM block")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return fmt.Errorf("unexpected PEM block %s (expected a private key)", block.Type)
+	}
+	if err != nil {
+		return err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("unsupported private key type %T", key)
+	}
+	k.key = signer
+	return nil
+}
+
+// MarshalText encodes the key as a PKCS #8 PEM block.
+func (k PrivateKey) MarshalText() ([]byte, error) {
+	if k.key == nil {
+		return nil, nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// String describes the kind of key without revealing it.
+func (k PrivateKey) String() string {
+	switch key := k.key.(type) {
+	case nil:
+		return ""
+	case *rsa.PrivateKey:
+		return fmt.Sprintf("<RSA-%d private key>", key.N.BitLen())
+	case *ecdsa.PrivateKey:
+		return fmt.Sprintf("<ECDSA %s private key>", key.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return "<Ed25519 private key>"
+	}
+	return "<private key>"
+}
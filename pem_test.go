@@ -0,0 +1,145 @@
+package envconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a self-signed certificate and its key as PEM.
+func testCertificate(t *testing.T) (cert, key string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "envconf.test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestNormalizePEM(t *testing.T) {
+	cert, _ := testCertificate(t)
+
+	tests := []struct {
+		input, errmatch string
+	}{
+		{cert, ""},
+		{"  " + cert + "\n\n", ""},
+		{strings.ReplaceAll(strings.TrimSpace(cert), "\n", `\n`), ""},
+		{strings.ReplaceAll(cert, "\n", "\r\n"), ""},
+		{base64.StdEncoding.EncodeToString([]byte(cert)), ""},
+		{"not a certificate", "not PEM or base64-encoded PEM"},
+		{base64.StdEncoding.EncodeToString([]byte("hello")), "not PEM or base64-encoded PEM"},
+		{cert + "trailing", "invalid PEM data"},
+	}
+	for _, test := range tests {
+		b, err := NormalizePEM(test.input)
+		if len(test.errmatch) > 0 {
+			if err == nil || err.Error() != test.errmatch {
+				t.Errorf("NormalizePEM(%q): expected an error matching '%s', got '%v'", test.input, test.errmatch, err)
+				t.Fail()
+			}
+			continue
+		}
+		if err != nil || string(b) != cert {
+			t.Errorf("NormalizePEM(%q): expected %q, got %q, %v", test.input, cert, b, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestConfigPEM(t *testing.T) {
+	cert, key := testCertificate(t)
+	var conf struct {
+		CA      PEM
+		TLSCert Certificates
+		TLSKey  PrivateKey
+	}
+	err := ReadConfigMap(&conf, map[string]string{
+		"CA":      base64.StdEncoding.EncodeToString([]byte(cert)),
+		"TLSCERT": strings.ReplaceAll(cert, "\n", `\n`),
+		"TLSKEY":  key,
+	})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	if string(conf.CA) != cert || conf.CA.String() != "<PEM: CERTIFICATE>" {
+		t.Errorf("CA: unexpected %q", conf.CA)
+		t.Fail()
+	}
+	if len(conf.TLSCert) != 1 || conf.TLSCert.Leaf().Subject.CommonName != "envconf.test" ||
+		conf.TLSCert.String() != "CN=envconf.test (expires 2099-01-01)" {
+		t.Errorf("TLSCert: unexpected %v", conf.TLSCert)
+		t.Fail()
+	}
+	if conf.TLSKey.Signer() == nil || conf.TLSKey.String() != "<ECDSA P-256 private key>" {
+		t.Errorf("TLSKey: unexpected %v", conf.TLSKey)
+		t.Fail()
+	}
+	if _, err := conf.TLSCert.TLSCertificate(conf.TLSKey); err != nil {
+		t.Errorf("TLSCertificate(): unexpected error %v", err)
+		t.Fail()
+	}
+
+	// Dump describes the values without revealing the key.
+	if dump := Dump(&conf); strings.Contains(dump, "BEGIN") {
+		t.Errorf("Dump(): expected no PEM data, got %q", dump)
+		t.Fail()
+	}
+
+	// Write gives values which decode to the same config.
+	env, err := Write(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	var back struct {
+		CA      PEM
+		TLSCert Certificates
+		TLSKey  PrivateKey
+	}
+	if err := ReadConfigMap(&back, env); err != nil || !back.TLSKey.Signer().Public().(*ecdsa.PublicKey).Equal(conf.TLSKey.Signer().Public()) ||
+		!back.TLSCert[0].Equal(conf.TLSCert[0]) || string(back.CA) != cert {
+		t.Errorf("Write(): expected a round trip, got %v, %v", env, err)
+		t.Fail()
+	}
+
+	errTests := []struct {
+		name, input, errmatch string
+	}{
+		{"TLSCERT", key, "unexpected PEM block EC PRIVATE KEY (expected CERTIFICATE)"},
+		{"TLSKEY", cert, "unexpected PEM block CERTIFICATE (expected a private key)"},
+		{"TLSKEY", key + key, "more than one PEM block"},
+		{"CA", "-----BEGIN", "invalid PEM data"},
+	}
+	for _, test := range errTests {
+		err := ReadConfigMap(&back, map[string]string{test.name: test.input})
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfigMap(%s): expected an error matching '%s', got '%v'", test.name, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A PlannedField describes one value that Decode will look up.
+type PlannedField struct {
+	// Path holds the Go field names leading from the config struct to the
+	// field.
+	Path []string
+	// Name is the variable name, including the Decoder's prefix as returned
+	// by Decoder.Prefix. For SelfDecoding fields it is the prefix passed to
+	// DecodeEnv, and for Indexed fields the prefix of the numbered
+	// variables.
+	Name string
+	// Aliases are the variables named by an "alias" tag, including the
+	// Decoder's prefix, which are read in order if Name is unset.
+	Aliases []string
+	// DefaultFrom is the variable named by a "defaultFrom" tag, including
+	// the Decoder's prefix, or empty.
+	DefaultFrom string
+	// Field is the struct field itself, giving access to its type and tags.
+	Field reflect.StructField
+	// SelfDecoding is true if the field's type implements EnvDecoder.
+	SelfDecoding bool
+	// Composite is true for struct fields whose types implement
+	// encoding.TextUnmarshaler. Such a field is set as a whole if its own
+	// variable is set; otherwise its fields, which follow it in the plan,
+	// are read individually.
+	Composite bool
+	// Indexed is true for slices of structs, whose elements are read from
+	// variables numbered from zero: for a Name of "UPSTREAMS_", the first
+	// element's Host field is read from UPSTREAMS_0_HOST.
+	Indexed bool
+	// Lazy is true if the field is tagged fetch:"lazy", and so is read by
+	// DecodeLazy rather than Decode.
+	Lazy bool
+
+	// index is the field's index sequence from the config struct, for use
+	// with reflect.Value.FieldByIndex.
+	index []int
+}
+
+// names returns the variables read for the field, in the order they are
+// tried: Name, its Aliases and then DefaultFrom.
+func (pf PlannedField) names() []string {
+	names := append([]string{pf.Name}, pf.Aliases...)
+	if len(pf.DefaultFrom) > 0 {
+		names = append(names, pf.DefaultFrom)
+	}
+	return names
+}
+
+// readsPrefix reports whether Name is the prefix of the variables the field
+// reads, rather than a variable itself, as for SelfDecoding and Indexed
+// fields.
+func (pf PlannedField) readsPrefix() bool {
+	return pf.SelfDecoding || pf.Indexed
+}
+
+// labelled returns pf's struct field with its Name replaced by the field's
+// full path and variable, such as Server.TLS.CertFile (SERVER_TLS_CERTFILE),
+// so that errors about the field say which of several like-named fields is
+// at fault, and what to set. A field which reads variables with a prefix is
+// labelled with the prefix, as in Cache (CACHE_*).
+func (pf PlannedField) labelled() reflect.StructField {
+	name := pf.Name
+	if pf.readsPrefix() {
+		name += "*"
+	}
+	field := pf.Field
+	field.Name = fmt.Sprintf("%s (%s)", strings.Join(pf.Path, "."), name)
+	return field
+}
+
+// elementPlan plans the i'th element of an Indexed field. The element's
+// fields are named after the field's prefix and i, and their paths and
+// indexes are relative to the element.
+func (d *Decoder) elementPlan(pf PlannedField, i int) []PlannedField {
+	ed := *d
+	ed.prefix = ""
+	path := append(pf.Path[:len(pf.Path):len(pf.Path)], strconv.Itoa(i))
+	scope := nameScope{pf.Name + strconv.Itoa(i) + "_", len(path)}
+	return ed.planStruct(nil, pf.Field.Type.Elem(), path, nil, scope, false)
+}
+
+var envDecoderType = reflect.TypeOf((*EnvDecoder)(nil)).Elem()
+
+// Plan returns the fields that Decode would read for conf, in struct order,
+// without performing any lookups. conf must be a struct or a pointer to a
+// struct; only its type is inspected. A malformed "pattern" tag is reported
+// as an error.
+func (d *Decoder) Plan(conf interface{}) ([]PlannedField, error) {
+	plan, err := d.planType(reflect.TypeOf(conf))
+	return append([]PlannedField(nil), plan...), err
+}
+
+// planKey identifies a plan in planCache. Plans depend on the struct type,
+// on the Decoder's prefix, Namer and command, and on the parsers registered
+// with RegisterParser.
+type planKey struct {
+	t          reflect.Type
+	prefix     string
+	namer      Namer
+	command    string
+	parsersGen uint64
+}
+
+// planCache maps planKeys to plans, so that repeated decodes of a type,
+// such as those of a Watcher or a test suite, don't reflect over it each
+// time.
+var planCache sync.Map
+
+// planType returns the plan for a struct type or a pointer to one, or an
+// error if one of its "pattern" tags does not compile. The plan is shared
+// and must not be modified. Plans are cached unless the Decoder's
+// Namer can't be compared, as with a NamerFunc, or it has parsers of its own.
+func (d *Decoder) planType(t reflect.Type) ([]PlannedField, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf(
+			"Invalid kind for config: %v", t.Kind())
+	}
+
+	if !reflect.TypeOf(d.namer).Comparable() || len(d.parsers) > 0 {
+		plan := d.planStruct(nil, t, nil, nil, nameScope{}, false)
+		return plan, d.checkPatterns(plan)
+	}
+	key := planKey{t, d.Prefix(), d.namer, d.command, parsersGen.Load()}
+	if plan, ok := planCache.Load(key); ok {
+		return plan.([]PlannedField), nil
+	}
+	plan := d.planStruct(nil, t, nil, nil, nameScope{}, false)
+	if err := d.checkPatterns(plan); err != nil {
+		return plan, err
+	}
+	planCache.Store(key, plan)
+	return plan, nil
+}
+
+// NameFor returns the variable name the Decoder reads for the field of the
+// struct type t at fieldPath, such as "Server", "Port". t may also be a
+// pointer to a struct type. It lets other parts of a program, such as error
+// messages and logs, refer to a field's variable without repeating the
+// naming rules. As in the variable names themselves, embedded structs do not
+// appear in fieldPath.
+func (d *Decoder) NameFor(t reflect.Type, fieldPath ...string) (string, error) {
+	plan, err := d.planType(t)
+	if err != nil {
+		return "", err
+	}
+	for _, pf := range plan {
+		if reflect.DeepEqual(pf.Path, fieldPath) {
+			return pf.Name, nil
+		}
+	}
+	return "", fmt.Errorf(
+		"No config field %s in %v", strings.Join(fieldPath, "."), t)
+}
+
+// NameFor returns the variable name read for a field by a Decoder with the
+// default options. A shortcut for:
+//
+//	envconf.NewDecoder().NameFor(t, fieldPath...)
+func NameFor(t reflect.Type, fieldPath ...string) (string, error) {
+	return NewDecoder().NameFor(t, fieldPath...)
+}
+
+// A nameScope says how variable names are derived within a nested struct:
+// base, followed by the Namer's name for the part of the field path after its
+// first from elements. A "prefix" tag starts a new scope.
+type nameScope struct {
+	base string
+	from int
+}
+
+// name returns the name for the field at fieldPath, without the Decoder's
+// prefix.
+func (s nameScope) name(namer Namer, fieldPath []string) string {
+	return s.base + namer.Name(fieldPath[s.from:])
+}
+
+// nested returns the scope for the fields of the struct at fieldPath, which
+// has the "prefix" tag fieldPrefix if hasPrefix is true.
+func (s nameScope) nested(fieldPrefix string, hasPrefix bool, fieldPath []string) nameScope {
+	if !hasPrefix {
+		return s
+	}
+	return nameScope{fieldPrefix, len(fieldPath)}
+}
+
+// planStruct appends the plan for the fields of the struct type t to plan.
+// path and index lead to t from the config struct, scope names its fields,
+// and lazy is true within a struct tagged fetch:"lazy".
+func (d *Decoder) planStruct(plan []PlannedField, t reflect.Type, path []string, index []int, scope nameScope, lazy bool) []PlannedField {
+	prefix := d.Prefix()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if len(field.PkgPath) > 0 && !(field.Anonymous && isPlainStruct(field.Type)) {
+			// ignore unexported, except for the exported fields of embedded
+			// structs, as encoding/json does
+			continue
+		}
+		if !d.forCommand(field) {
+			continue
+		}
+
+		fieldPath := append(path[:len(path):len(path)], field.Name)
+		fieldIndex := append(index[:len(index):len(index)], i)
+
+		lazy := lazy || field.Tag.Get("fetch") == "lazy"
+		fieldPrefix, hasPrefix := field.Tag.Lookup("prefix")
+		// a field read from a JSON value or by a Parser is read whole,
+		// whatever its type
+		whole := isJSONField(field) || d.parses(field.Type) ||
+			(field.Type.Kind() == reflect.Slice && d.parses(field.Type.Elem()))
+
+		if !whole && reflect.PtrTo(field.Type).Implements(envDecoderType) {
+			name := scope.name(d.namer, fieldPath) + "_"
+			if hasPrefix {
+				name = fieldPrefix
+			}
+			plan = append(plan, PlannedField{
+				Path:         fieldPath,
+				Name:         prefix + name,
+				Field:        field,
+				SelfDecoding: true,
+				Lazy:         lazy,
+				index:        fieldIndex,
+			})
+			continue
+		}
+
+		if !whole && isIndexable(field.Type) {
+			name := scope.name(d.namer, fieldPath) + "_"
+			if hasPrefix {
+				name = fieldPrefix
+			}
+			plan = append(plan, PlannedField{
+				Path:    fieldPath,
+				Name:    prefix + name,
+				Field:   field,
+				Indexed: true,
+				Lazy:    lazy,
+				index:   fieldIndex,
+			})
+			continue
+		}
+
+		if !whole && isStructPtr(field.Type) {
+			if len(field.PkgPath) > 0 {
+				// can't be allocated
+				continue
+			}
+			if field.Anonymous {
+				fieldPath = path
+			}
+			plan = d.planStruct(plan, field.Type.Elem(), fieldPath, fieldIndex,
+				scope.nested(fieldPrefix, hasPrefix, fieldPath), lazy)
+			continue
+		}
+
+		if !whole && field.Type.Kind() == reflect.Struct && !reflect.PtrTo(field.Type).Implements(lazyBinderType) {
+			if isTextUnmarshaler(field.Type) {
+				name := scope.name(d.namer, fieldPath)
+				if hasPrefix {
+					name = strings.TrimRight(fieldPrefix, "_")
+				}
+				plan = append(plan, PlannedField{
+					Path:      fieldPath,
+					Name:      prefix + name,
+					Field:     field,
+					Composite: true,
+					Lazy:      lazy,
+					index:     fieldIndex,
+				})
+			} else if field.Anonymous {
+				fieldPath = path
+			}
+			plan = d.planStruct(plan, field.Type, fieldPath, fieldIndex,
+				scope.nested(fieldPrefix, hasPrefix, fieldPath), lazy)
+			continue
+		}
+
+		pf := PlannedField{
+			Path:  fieldPath,
+			Name:  prefix + scope.name(d.namer, fieldPath),
+			Field: field,
+			Lazy:  lazy,
+			index: fieldIndex,
+		}
+		if env := field.Tag.Get("env"); len(env) > 0 {
+			pf.Name = prefix + env
+		}
+		if aliases := field.Tag.Get("alias"); len(aliases) > 0 {
+			for _, alias := range strings.Split(aliases, ",") {
+				pf.Aliases = append(pf.Aliases, prefix+strings.TrimSpace(alias))
+			}
+		}
+		if from := field.Tag.Get("defaultFrom"); len(from) > 0 {
+			pf.DefaultFrom = prefix + from
+		}
+		plan = append(plan, pf)
+	}
+
+	return plan
+}
+
+// forCommand reports whether field is read for the Decoder's command, as
+// set by WithCommand.
+func (d *Decoder) forCommand(field reflect.StructField) bool {
+	cmds, ok := field.Tag.Lookup("cmd")
+	if !ok || len(d.command) == 0 {
+		return true
+	}
+	for _, cmd := range strings.Split(cmds, ",") {
+		if strings.TrimSpace(cmd) == d.command {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlainStruct reports whether t is a struct type which is only read field
+// by field, as opposed to one which can decode or parse itself.
+func isPlainStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if _, ok := registeredParser(t); ok {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	return !pt.Implements(envDecoderType) && !pt.Implements(lazyBinderType) &&
+		!pt.Implements(textUnmarshalerType)
+}
+
+// isIndexable reports whether t is a slice of plain structs, read from
+// numbered variables. The structs must have fields to be read.
+func isIndexable(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && isPlainStruct(t.Elem()) && t.Elem().NumField() > 0
+}
+
+// isStructPtr reports whether t is a pointer to a plain struct type, whose
+// fields are read as if it were a nested struct.
+func isStructPtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && isPlainStruct(t.Elem())
+}
+
+// fieldByIndex returns the field of the struct v at index, which may pass
+// through pointers to structs. If alloc is true, nil pointers are set to new
+// structs; otherwise ok is false if the field is behind a nil pointer.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (_ reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,352 @@
+package envconf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	var myConf struct {
+		Port   int
+		Server struct {
+			Host string
+		}
+		Replica string `defaultFrom:"PRIMARY"`
+		Cache   cacheConfig
+		ignored string
+	}
+	expect := []PlannedField{
+		{Path: []string{"Port"}, Name: "APP_PORT"},
+		{Path: []string{"Server", "Host"}, Name: "APP_SERVER_HOST"},
+		{Path: []string{"Replica"}, Name: "APP_REPLICA", DefaultFrom: "APP_PRIMARY"},
+		{Path: []string{"Cache"}, Name: "APP_CACHE_", SelfDecoding: true},
+	}
+
+	plan, err := NewDecoder(WithPrefix("APP_")).Plan(&myConf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if len(plan) != len(expect) {
+		t.Errorf("Wrong length for plan: wanted %d, got %d", len(expect), len(plan))
+		t.FailNow()
+	}
+	for i, e := range expect {
+		pf := plan[i]
+		if !reflect.DeepEqual(pf.Path, e.Path) || pf.Name != e.Name || pf.DefaultFrom != e.DefaultFrom || pf.SelfDecoding != e.SelfDecoding {
+			t.Errorf("plan[%d]: expected %+v, got %+v", i, e, pf)
+			t.Fail()
+		}
+	}
+
+	if _, err := NewDecoder().Plan(1); err == nil {
+		t.Errorf("Plan(): expected an error for a non-struct")
+		t.Fail()
+	}
+}
+
+func TestNameFor(t *testing.T) {
+	type Common struct {
+		Debug bool
+	}
+	type AppConfig struct {
+		Common
+		Server struct {
+			Port int
+		}
+		Cache cacheConfig
+	}
+	typ := reflect.TypeOf(AppConfig{})
+
+	tests := []struct {
+		d      *Decoder
+		path   []string
+		expect string
+	}{
+		{NewDecoder(), []string{"Server", "Port"}, "SERVER_PORT"},
+		{NewDecoder(), []string{"Debug"}, "DEBUG"},
+		{NewDecoder(WithPrefix("app"), WithPrefixSeparator("_")), []string{"Server", "Port"}, "APP_SERVER_PORT"},
+		{NewDecoder(WithPrefix("APP_")), []string{"Cache"}, "APP_CACHE_"},
+	}
+	for _, test := range tests {
+		name, err := test.d.NameFor(typ, test.path...)
+		if err != nil || name != test.expect {
+			t.Errorf("NameFor(%v): expected %q, got %q, %v", test.path, test.expect, name, err)
+			t.Fail()
+		}
+	}
+
+	if name, err := NameFor(reflect.PtrTo(typ), "Server", "Port"); err != nil || name != "SERVER_PORT" {
+		t.Errorf("NameFor(): expected SERVER_PORT for a pointer type, got %q, %v", name, err)
+		t.Fail()
+	}
+
+	errTests := []struct {
+		typ      reflect.Type
+		path     []string
+		errmatch string
+	}{
+		{typ, []string{"Common", "Debug"}, "No config field Common.Debug in envconf.AppConfig"},
+		{typ, []string{"Server"}, "No config field Server in envconf.AppConfig"},
+		{reflect.TypeOf(0), []string{"Port"}, "Invalid kind for config: int"},
+	}
+	for _, test := range errTests {
+		if _, err := NameFor(test.typ, test.path...); err == nil || err.Error() != test.errmatch {
+			t.Errorf("NameFor(%v): expected an error matching '%s', got '%v'", test.path, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestWithCommand(t *testing.T) {
+	type commandConfig struct {
+		LogLevel string `default:"info"`
+		Server   struct {
+			Port int `required:"true"`
+		} `cmd:"serve"`
+		DB struct {
+			URL string `required:"true"`
+		} `cmd:"serve, migrate"`
+		Steps int `cmd:"migrate"`
+	}
+
+	tests := []struct {
+		command string
+		expect  []string
+	}{
+		{"", []string{"LOGLEVEL", "SERVER_PORT", "DB_URL", "STEPS"}},
+		{"serve", []string{"LOGLEVEL", "SERVER_PORT", "DB_URL"}},
+		{"migrate", []string{"LOGLEVEL", "DB_URL", "STEPS"}},
+		{"version", []string{"LOGLEVEL"}},
+	}
+	for _, test := range tests {
+		plan, err := NewDecoder(WithCommand(test.command)).Plan(&commandConfig{})
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+			t.FailNow()
+		}
+		var names []string
+		for _, pf := range plan {
+			names = append(names, pf.Name)
+		}
+		if !reflect.DeepEqual(names, test.expect) {
+			t.Errorf("Plan() for %q: expected %v, got %v", test.command, test.expect, names)
+			t.Fail()
+		}
+	}
+
+	var conf commandConfig
+	err := NewDecoder(WithCommand("migrate"), WithSource(MapSource{"DB_URL": "postgres://db"})).Decode(&conf)
+	if err != nil || conf.DB.URL != "postgres://db" {
+		t.Errorf("Decode(): expected migrate not to need SERVER_PORT, got %+v, %v", conf, err)
+		t.Fail()
+	}
+}
+
+type poolConfig[T any] struct {
+	Size    int `default:"4"`
+	Options T
+}
+
+type retryOptions struct {
+	Attempts int
+}
+
+type embeddedDefaults struct {
+	Debug bool
+}
+
+type aliasedConfig = retryOptions
+
+func TestPlanStructKinds(t *testing.T) {
+	type DBConfig struct {
+		Host string `required:"true"`
+	}
+	type Common struct {
+		Region string
+	}
+	type conf struct {
+		embeddedDefaults
+		*Common
+		DB      *DBConfig
+		Anon    *struct{ Level int }
+		Pool    poolConfig[retryOptions]
+		Cache   poolConfig[string]
+		Retries aliasedConfig
+		Unset   *DBConfig `cmd:"other"`
+		hidden  *DBConfig
+	}
+	expect := []string{
+		"DEBUG", "REGION", "DB_HOST", "ANON_LEVEL", "POOL_SIZE", "POOL_OPTIONS_ATTEMPTS",
+		"CACHE_SIZE", "CACHE_OPTIONS", "RETRIES_ATTEMPTS",
+	}
+
+	d := NewDecoder(WithCommand("serve"), WithSource(MapSource{
+		"DEBUG":                 "true",
+		"REGION":                "eu",
+		"DB_HOST":               "db",
+		"ANON_LEVEL":            "2",
+		"POOL_OPTIONS_ATTEMPTS": "3",
+		"CACHE_OPTIONS":         "lru",
+		"RETRIES_ATTEMPTS":      "5",
+	}))
+	plan, err := d.Plan(&conf{})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	var names []string
+	for _, pf := range plan {
+		names = append(names, pf.Name)
+	}
+	if !reflect.DeepEqual(names, expect) {
+		t.Errorf("Plan(): expected %v, got %v", expect, names)
+		t.Fail()
+	}
+
+	var c conf
+	if err := d.Decode(&c); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !c.Debug || c.Common == nil || c.Region != "eu" || c.DB == nil || c.DB.Host != "db" ||
+		c.Anon == nil || c.Anon.Level != 2 || c.Pool.Size != 4 || c.Pool.Options.Attempts != 3 ||
+		c.Cache.Options != "lru" || c.Retries.Attempts != 5 || c.Unset != nil || c.hidden != nil {
+		t.Errorf("Decode(): unexpected %+v", c)
+		t.Fail()
+	}
+
+	// Fields behind nil pointers are left out rather than panicking.
+	c.DB = nil
+	env, err := Write(&c)
+	if _, ok := env["DB_HOST"]; err != nil || ok || env["REGION"] != "eu" {
+		t.Errorf("Write(): unexpected %v, %v", env, err)
+		t.Fail()
+	}
+
+	err = ReadConfigMap(&c, map[string]string{})
+	if err == nil || err.Error() != "Missing config fields: DB_HOST, UNSET_HOST" {
+		t.Errorf("expected an error matching '%s', got '%v'", "Missing config fields: DB_HOST, UNSET_HOST", err)
+		t.Fail()
+	}
+
+	if name, err := NameFor(reflect.TypeOf(conf{}), "Pool", "Options", "Attempts"); err != nil || name != "POOL_OPTIONS_ATTEMPTS" {
+		t.Errorf("NameFor(): expected POOL_OPTIONS_ATTEMPTS, got %q, %v", name, err)
+		t.Fail()
+	}
+}
+
+func TestPrefixTag(t *testing.T) {
+	type DB struct {
+		Host string `required:"true"`
+		Port int    `default:"5432"`
+		TLS  struct {
+			CertFile string
+		}
+	}
+	type conf struct {
+		Primary DB  `prefix:"PRIMARY_DB_"`
+		Replica *DB `prefix:"REPLICA_DB_"`
+		Queues  struct {
+			Jobs   struct{ URL string } `prefix:"JOBS_QUEUE_"`
+			Events struct{ URL string }
+		}
+		Cache cacheConfig `prefix:"REDIS_"`
+	}
+	expect := []string{
+		"APP_PRIMARY_DB_HOST", "APP_PRIMARY_DB_PORT", "APP_PRIMARY_DB_TLS_CERTFILE",
+		"APP_REPLICA_DB_HOST", "APP_REPLICA_DB_PORT", "APP_REPLICA_DB_TLS_CERTFILE",
+		"APP_JOBS_QUEUE_URL", "APP_QUEUES_EVENTS_URL", "APP_REDIS_",
+	}
+
+	d := NewDecoder(WithPrefix("APP_"), WithSource(MapSource{
+		"APP_PRIMARY_DB_HOST": "primary",
+		"APP_REPLICA_DB_HOST": "replica",
+		"APP_REPLICA_DB_PORT": "6432",
+		"APP_JOBS_QUEUE_URL":  "amqp://jobs",
+	}))
+	plan, err := d.Plan(&conf{})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	var names []string
+	for _, pf := range plan {
+		names = append(names, pf.Name)
+	}
+	if !reflect.DeepEqual(names, expect) {
+		t.Errorf("Plan(): expected %v, got %v", expect, names)
+		t.Fail()
+	}
+
+	var c conf
+	if err := d.Decode(&c); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if c.Primary.Host != "primary" || c.Primary.Port != 5432 || c.Replica.Host != "replica" ||
+		c.Replica.Port != 6432 || c.Queues.Jobs.URL != "amqp://jobs" {
+		t.Errorf("Decode(): unexpected %+v", c)
+		t.Fail()
+	}
+
+	if name, err := d.NameFor(reflect.TypeOf(c), "Replica", "TLS", "CertFile"); err != nil || name != "APP_REPLICA_DB_TLS_CERTFILE" {
+		t.Errorf("NameFor(): expected APP_REPLICA_DB_TLS_CERTFILE, got %q, %v", name, err)
+		t.Fail()
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	type cachedConfig struct {
+		Port int
+		DB   struct{ Host string }
+	}
+	typ := reflect.TypeOf(cachedConfig{})
+
+	a, _ := NewDecoder(WithPrefix("APP_")).planType(typ)
+	b, _ := NewDecoder(WithPrefix("APP_")).planType(typ)
+	if &a[0] != &b[0] {
+		t.Errorf("planType(): expected Decoders with the same options to share a plan")
+		t.Fail()
+	}
+
+	tests := []struct {
+		d      *Decoder
+		expect string
+	}{
+		{NewDecoder(WithPrefix("OTHER_")), "OTHER_DB_HOST"},
+		{NewDecoder(WithPrefix("APP_"), WithNamer(NamerFunc(func(p []string) string { return "X" }))), "XX"},
+	}
+	for _, test := range tests {
+		if name, err := test.d.NameFor(typ, "DB", "Host"); err != nil || name != test.expect {
+			t.Errorf("NameFor(): expected %q, got %q, %v", test.expect, name, err)
+			t.Fail()
+		}
+	}
+
+	plan, _ := NewDecoder(WithPrefix("APP_")).Plan(cachedConfig{})
+	plan[0].Name = "CHANGED"
+	if name, _ := NewDecoder(WithPrefix("APP_")).NameFor(typ, "Port"); name != "APP_PORT" {
+		t.Errorf("Plan(): expected a copy of the cached plan, but it was changed to %q", name)
+		t.Fail()
+	}
+}
+
+func BenchmarkReadConfigMap(b *testing.B) {
+	var conf struct {
+		Port    int `required:"true"`
+		Host    string
+		Timeout time.Duration `default:"5s"`
+		DB      struct {
+			URL  string
+			Pool int
+		}
+	}
+	env := map[string]string{"PORT": "8080", "HOST": "localhost", "DB_URL": "postgres://db"}
+	for i := 0; i < b.N; i++ {
+		if err := ReadConfigMap(&conf, env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
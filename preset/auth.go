@@ -0,0 +1,87 @@
+package preset
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Auth configures verification of JWT and OIDC tokens.
+//
+// Validate checks the URLs for syntax only. Set CheckReachable to also fetch
+// the key set while loading, or call Reachable later, for example once the
+// service's network is up.
+type Auth struct {
+	IssuerURL string   `required:"true"`
+	Audiences []string `required:"true"`
+	// JWKSURL is where signing keys are published. If it is empty, keys are
+	// found through OIDC discovery from the issuer.
+	JWKSURL        string
+	ClockSkew      time.Duration `default:"1m"`
+	Algorithms     []string      `default:"RS256" oneof:"RS256,RS384,RS512,ES256,ES384,ES512,PS256,PS384,PS512,EdDSA"`
+	CheckReachable bool
+}
+
+// Validate checks the URLs and clock skew, and if CheckReachable is set,
+// that the key set can be fetched.
+func (a Auth) Validate() error {
+	if err := checkHTTPURL("issuer", a.IssuerURL); err != nil {
+		return err
+	}
+	if len(a.JWKSURL) > 0 {
+		if err := checkHTTPURL("jwks", a.JWKSURL); err != nil {
+			return err
+		}
+	}
+	if a.ClockSkew < 0 {
+		return fmt.Errorf("auth clock skew must not be negative, got %v", a.ClockSkew)
+	}
+
+	if a.CheckReachable {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return a.Reachable(ctx, http.DefaultClient)
+	}
+	return nil
+}
+
+// checkHTTPURL checks that s is an absolute http or https URL.
+func checkHTTPURL(what, s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s url %q: %v", what, s, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return fmt.Errorf("invalid %s url %q: must be an absolute http or https url", what, s)
+	}
+	return nil
+}
+
+// KeysURL returns JWKSURL, or the issuer's OIDC discovery document if it is
+// empty.
+func (a Auth) KeysURL() string {
+	if len(a.JWKSURL) > 0 {
+		return a.JWKSURL
+	}
+	return strings.TrimSuffix(a.IssuerURL, "/") + "/.well-known/openid-configuration"
+}
+
+// Reachable checks that KeysURL can be fetched with client.
+func (a Auth) Reachable(ctx context.Context, client *http.Client) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.KeysURL(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("auth keys unreachable: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("auth keys unreachable: %s returned %s", a.KeysURL(), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package preset
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+func TestAuth(t *testing.T) {
+	var conf struct{ Auth Auth }
+	err := envconf.ReadConfigMap(&conf, map[string]string{
+		"AUTH_ISSUERURL": "https://id.example.com/",
+		"AUTH_AUDIENCES": "api,admin",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	a := conf.Auth
+	if a.ClockSkew != time.Minute || len(a.Algorithms) != 1 || a.Algorithms[0] != "RS256" {
+		t.Errorf("Auth: expected defaults, got %+v", a)
+		t.Fail()
+	}
+	if u := a.KeysURL(); u != "https://id.example.com/.well-known/openid-configuration" {
+		t.Errorf("KeysURL(): got %q", u)
+		t.Fail()
+	}
+}
+
+func TestAuthValidate(t *testing.T) {
+	base := map[string]string{"AUTH_ISSUERURL": "https://id.example.com", "AUTH_AUDIENCES": "api"}
+	tests := []struct {
+		extra    map[string]string
+		errmatch string
+	}{
+		{map[string]string{"AUTH_ISSUERURL": "id.example.com"}, `invalid issuer url "id.example.com"`},
+		{map[string]string{"AUTH_JWKSURL": "ftp://keys"}, `invalid jwks url "ftp://keys"`},
+		{map[string]string{"AUTH_ALGORITHMS": "RS256,none"}, `"none" (must be one of`},
+		{map[string]string{"AUTH_AUDIENCES": ""}, "Missing config fields: AUTH_AUDIENCES"},
+		{map[string]string{"AUTH_CLOCKSKEW": "-1s"}, "clock skew must not be negative"},
+	}
+
+	for _, test := range tests {
+		vals := make(map[string]string)
+		for k, v := range base {
+			vals[k] = v
+		}
+		for k, v := range test.extra {
+			vals[k] = v
+		}
+		var conf struct{ Auth Auth }
+		err := envconf.ReadConfigMap(&conf, vals)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfigMap(%v): expected an error matching '%s', got '%v'", test.extra, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestAuthReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/keys" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	a := Auth{IssuerURL: srv.URL, Audiences: []string{"api"}, JWKSURL: srv.URL + "/keys"}
+	if err := a.Reachable(context.Background(), srv.Client()); err != nil {
+		t.Errorf("Reachable(): unexpected error %v", err)
+		t.Fail()
+	}
+
+	// without a JWKS URL, the discovery document is fetched, and is missing
+	var conf struct{ Auth Auth }
+	err := envconf.ReadConfigMap(&conf, map[string]string{
+		"AUTH_ISSUERURL":      srv.URL,
+		"AUTH_AUDIENCES":      "api",
+		"AUTH_CHECKREACHABLE": "true",
+	})
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("ReadConfigMap(): expected an unreachable error, got '%v'", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,116 @@
+package preset
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backoff configures exponential backoff with jitter, for retries and
+// reconnects.
+//
+// A Backoff can be set as a whole from one compact variable:
+//
+//	RETRY=100ms..30s*2±20%
+//
+// meaning delays start at 100ms and are multiplied by 2 after each attempt,
+// up to 30s, with each delay varied randomly by up to 20%. The multiplier and
+// jitter may be omitted, and "+-" may be written in place of "±".
+//
+// Otherwise it is read field by field, from RETRY_INITIAL, RETRY_MAX,
+// RETRY_MULTIPLIER and RETRY_JITTER.
+type Backoff struct {
+	Initial    time.Duration `default:"100ms"`
+	Max        time.Duration `default:"30s"`
+	Multiplier float64       `default:"2"`
+	// Jitter is the fraction, between 0 and 1, by which each delay is
+	// randomly varied.
+	Jitter float64
+}
+
+// UnmarshalText parses the compact form of a Backoff.
+func (b *Backoff) UnmarshalText(text []byte) error {
+	s := string(text)
+	nb := Backoff{Multiplier: 2}
+
+	for _, sep := range []string{"±", "+-"} {
+		if i := strings.Index(s, sep); i >= 0 {
+			if j, err := parsePercent(s[i+len(sep):]); err != nil {
+				return err
+			} else {
+				s, nb.Jitter = s[:i], j
+			}
+			break
+		}
+	}
+
+	if i := strings.Index(s, "*"); i >= 0 {
+		if m, err := strconv.ParseFloat(s[i+1:], 64); err != nil {
+			return err
+		} else {
+			s, nb.Multiplier = s[:i], m
+		}
+	}
+
+	spl := strings.SplitN(s, "..", 2)
+	if len(spl) != 2 {
+		return fmt.Errorf("invalid backoff %q: expected initial..max", string(text))
+	}
+	var err error
+	if nb.Initial, err = time.ParseDuration(spl[0]); err != nil {
+		return err
+	}
+	if nb.Max, err = time.ParseDuration(spl[1]); err != nil {
+		return err
+	}
+
+	*b = nb
+	return nil
+}
+
+// parsePercent parses a percentage such as "20%" into a fraction.
+func parsePercent(s string) (float64, error) {
+	p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	return p / 100, nil
+}
+
+// String returns the compact form of b.
+func (b Backoff) String() string {
+	s := fmt.Sprintf("%v..%v*%s", b.Initial, b.Max,
+		strconv.FormatFloat(b.Multiplier, 'f', -1, 64))
+	if b.Jitter > 0 {
+		s += "±" + strconv.FormatFloat(b.Jitter*100, 'f', -1, 64) + "%"
+	}
+	return s
+}
+
+// Validate checks that the delays and factors of b are consistent.
+func (b Backoff) Validate() error {
+	switch {
+	case b.Initial <= 0:
+		return fmt.Errorf("backoff initial delay must be positive, got %v", b.Initial)
+	case b.Max < b.Initial:
+		return fmt.Errorf("backoff max delay %v is less than initial delay %v", b.Max, b.Initial)
+	case b.Multiplier < 1:
+		return fmt.Errorf("backoff multiplier must be at least 1, got %v", b.Multiplier)
+	case b.Jitter < 0 || b.Jitter > 1:
+		return fmt.Errorf("backoff jitter must be between 0 and 1, got %v", b.Jitter)
+	}
+	return nil
+}
+
+// Delay returns the delay before retry number attempt, counting from 0. It
+// is never more than Max, even with jitter.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := math.Min(float64(b.Initial)*math.Pow(b.Multiplier, float64(attempt)), float64(b.Max))
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(math.Min(d, float64(b.Max)))
+}
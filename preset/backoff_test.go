@@ -0,0 +1,112 @@
+package preset
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+func TestBackoffCompact(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect Backoff
+	}{
+		{"100ms..30s*2±20%", Backoff{100 * time.Millisecond, 30 * time.Second, 2, 0.2}},
+		{"1s..1m*1.5+-10%", Backoff{time.Second, time.Minute, 1.5, 0.1}},
+		{"50ms..5s", Backoff{50 * time.Millisecond, 5 * time.Second, 2, 0}},
+	}
+
+	for _, test := range tests {
+		var b Backoff
+		if err := b.UnmarshalText([]byte(test.input)); err != nil {
+			t.Errorf("UnmarshalText(%q): unexpected error %v", test.input, err)
+			t.Fail()
+		} else if b != test.expect {
+			t.Errorf("UnmarshalText(%q): expected %+v, got %+v", test.input, test.expect, b)
+			t.Fail()
+		}
+	}
+
+	for _, bad := range []string{"100ms", "100ms..x", "1s..2s*two", "1s..2s±lots"} {
+		var b Backoff
+		if err := b.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("UnmarshalText(%q): expected an error", bad)
+			t.Fail()
+		}
+	}
+
+	if s := (Backoff{100 * time.Millisecond, 30 * time.Second, 2, 0.2}).String(); s != "100ms..30s*2±20%" {
+		t.Errorf("String(): expected '100ms..30s*2±20%%', got %q", s)
+		t.Fail()
+	}
+}
+
+func TestBackoffConfig(t *testing.T) {
+	var conf struct {
+		Retry     Backoff
+		Reconnect Backoff
+		Poll      Backoff
+	}
+	input := map[string]string{
+		"RETRY":                "200ms..10s*3",
+		"RETRY_INITIAL":        "1h",
+		"RECONNECT_INITIAL":    "1s",
+		"RECONNECT_MAX":        "2m",
+		"RECONNECT_MULTIPLIER": "1.5",
+	}
+
+	if err := envconf.ReadConfigMap(&conf, input); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if expect := (Backoff{200 * time.Millisecond, 10 * time.Second, 3, 0}); conf.Retry != expect {
+		t.Errorf("Retry: expected %+v, got %+v", expect, conf.Retry)
+		t.Fail()
+	}
+	if expect := (Backoff{time.Second, 2 * time.Minute, 1.5, 0}); conf.Reconnect != expect {
+		t.Errorf("Reconnect: expected %+v, got %+v", expect, conf.Reconnect)
+		t.Fail()
+	}
+	if expect := (Backoff{100 * time.Millisecond, 30 * time.Second, 2, 0}); conf.Poll != expect {
+		t.Errorf("Poll: expected defaults %+v, got %+v", expect, conf.Poll)
+		t.Fail()
+	}
+
+	match := "max delay 1s is less than initial delay 2s"
+	err := envconf.ReadConfigMap(&conf, map[string]string{"RETRY": "2s..1s"})
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfigMap(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{100 * time.Millisecond, time.Second, 2, 0}
+	expect := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, e := range expect {
+		if d := b.Delay(i); d != e*time.Millisecond {
+			t.Errorf("Delay(%d): expected %v, got %v", i, e*time.Millisecond, d)
+			t.Fail()
+		}
+	}
+
+	b.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if d := b.Delay(3); d < 640*time.Millisecond || d > 960*time.Millisecond {
+			t.Errorf("Delay(3) with jitter: %v out of range", d)
+			t.Fail()
+		}
+		if d := b.Delay(10); d < 800*time.Millisecond || d > time.Second {
+			t.Errorf("Delay(10) with jitter: %v out of range", d)
+			t.Fail()
+		}
+	}
+
+	b = Backoff{time.Second, time.Minute, 1, 0}
+	if d := b.Delay(1 << 40); d != time.Second {
+		t.Errorf("Delay(1<<40) with multiplier 1: expected 1s, got %v", d)
+		t.Fail()
+	}
+}
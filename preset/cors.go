@@ -0,0 +1,112 @@
+package preset
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures cross-origin resource sharing for an HTTP service.
+//
+// Each allowed origin is "*", or a scheme and host with an optional port,
+// such as "https://app.example.com". A leading "*." in the host allows any
+// subdomain: "https://*.example.com".
+type CORS struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string `default:"GET,HEAD,POST"`
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration `default:"10m"`
+}
+
+// Validate checks the origin syntax, and that credentials are not combined
+// with a wildcard origin, which browsers reject.
+func (c CORS) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("cors origin * cannot be used with credentials")
+			}
+			continue
+		}
+		if err := checkOrigin(origin); err != nil {
+			return err
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("cors max age must not be negative, got %v", c.MaxAge)
+	}
+	return nil
+}
+
+// checkOrigin checks that origin is a scheme and host with no path.
+func checkOrigin(origin string) error {
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil {
+		return fmt.Errorf("invalid cors origin %q: %v", origin, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid cors origin %q: scheme must be http or https", origin)
+	}
+	if len(u.Host) == 0 || len(u.Path) > 0 || len(u.RawQuery) > 0 || len(u.Fragment) > 0 || u.User != nil {
+		return fmt.Errorf("invalid cors origin %q: must be scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// AllowsOrigin reports whether requests from origin are allowed.
+func (c CORS) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if i := strings.Index(allowed, "://*."); i >= 0 {
+			scheme, suffix := allowed[:i+3], allowed[i+4:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(scheme)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Header returns the Access-Control headers to add to a response to a
+// request from origin, or nil if the origin is not allowed. Preflight
+// responses also carry the allowed methods and headers, and the max age.
+func (c CORS) Header(origin string, preflight bool) http.Header {
+	if len(origin) == 0 || !c.AllowsOrigin(origin) {
+		return nil
+	}
+
+	h := make(http.Header)
+	if len(c.AllowedOrigins) == 1 && c.AllowedOrigins[0] == "*" {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Vary", "Origin")
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if preflight {
+		if len(c.AllowedMethods) > 0 {
+			h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+		}
+		if len(c.AllowedHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+		}
+		if c.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+	} else if len(c.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+
+	return h
+}
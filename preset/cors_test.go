@@ -0,0 +1,91 @@
+package preset
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+func TestCORSValidate(t *testing.T) {
+	tests := []struct {
+		vals     map[string]string
+		valid    bool
+		errmatch string
+	}{
+		{map[string]string{}, true, ""},
+		{map[string]string{"CORS_ALLOWEDORIGINS": "*"}, true, ""},
+		{map[string]string{"CORS_ALLOWEDORIGINS": "https://app.example.com,http://localhost:3000,https://*.example.org"}, true, ""},
+		{map[string]string{"CORS_ALLOWEDORIGINS": "https://app.example.com/"}, false, `invalid cors origin "https://app.example.com/"`},
+		{map[string]string{"CORS_ALLOWEDORIGINS": "app.example.com"}, false, "scheme must be http or https"},
+		{map[string]string{"CORS_ALLOWEDORIGINS": "*", "CORS_ALLOWCREDENTIALS": "true"}, false, "cannot be used with credentials"},
+	}
+
+	for _, test := range tests {
+		var conf struct{ CORS CORS }
+		err := envconf.ReadConfigMap(&conf, test.vals)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+	}
+}
+
+func TestCORSHeader(t *testing.T) {
+	var conf struct{ CORS CORS }
+	err := envconf.ReadConfigMap(&conf, map[string]string{
+		"CORS_ALLOWEDORIGINS":   "https://app.example.com,https://*.example.org",
+		"CORS_ALLOWEDHEADERS":   "Authorization,Content-Type",
+		"CORS_EXPOSEDHEADERS":   "X-Request-Id",
+		"CORS_ALLOWCREDENTIALS": "true",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	c := conf.CORS
+
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com": true,
+		"https://a.example.org":   true,
+		"https://example.org":     false,
+		"http://a.example.org":    false,
+		"https://evil.com":        false,
+	} {
+		if c.AllowsOrigin(origin) != allowed {
+			t.Errorf("AllowsOrigin(%q): expected %t", origin, allowed)
+			t.Fail()
+		}
+	}
+
+	h := c.Header("https://app.example.com", true)
+	expect := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST",
+		"Access-Control-Allow-Headers":     "Authorization, Content-Type",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin",
+	}
+	for k, v := range expect {
+		if got := h.Get(k); got != v {
+			t.Errorf("Header(): %s: expected %q, got %q", k, v, got)
+			t.Fail()
+		}
+	}
+
+	if h := c.Header("https://app.example.com", false); h.Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Errorf("Header(): expected exposed headers on a simple request, got %v", h)
+		t.Fail()
+	}
+	if h := c.Header("https://evil.com", true); h != nil {
+		t.Errorf("Header(): expected nil for a disallowed origin, got %v", h)
+		t.Fail()
+	}
+}
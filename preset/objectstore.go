@@ -0,0 +1,85 @@
+package preset
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ObjectStore configures access to S3 or an S3-compatible object store, such
+// as MinIO or Ceph.
+//
+// Leave Endpoint empty to use AWS. Self-hosted stores usually need an
+// Endpoint and PathStyle. Leave both keys empty to use ambient credentials,
+// such as an instance role.
+type ObjectStore struct {
+	Endpoint        string
+	Region          string `default:"us-east-1"`
+	Bucket          string `required:"true" pattern:"[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]"`
+	AccessKeyID     string
+	SecretAccessKey string `secret:"true"`
+	PathStyle       bool
+	// InsecureSkipVerify disables TLS certificate verification. It is for
+	// development only, and loading a config with it set logs a warning.
+	InsecureSkipVerify bool
+}
+
+// Validate checks the endpoint and that the keys are set together.
+func (o ObjectStore) Validate() error {
+	if len(o.Endpoint) > 0 {
+		u, err := url.Parse(o.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid object store endpoint %q: %v", o.Endpoint, err)
+		}
+		if (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+			return fmt.Errorf("invalid object store endpoint %q: must be an absolute http or https url", o.Endpoint)
+		}
+	}
+	if (len(o.AccessKeyID) == 0) != (len(o.SecretAccessKey) == 0) {
+		return fmt.Errorf("object store access key id and secret access key must be set together")
+	}
+	if strings.Contains(o.Bucket, "..") {
+		return fmt.Errorf("invalid object store bucket %q", o.Bucket)
+	}
+	return nil
+}
+
+// Warnings warns that certificate verification is disabled.
+func (o ObjectStore) Warnings() []error {
+	if o.InsecureSkipVerify {
+		return []error{fmt.Errorf(
+			"TLS CERTIFICATE VERIFICATION IS DISABLED for object store %s; do not use this in production",
+			o.EndpointURL())}
+	}
+	return nil
+}
+
+// EndpointURL returns the endpoint, or the AWS endpoint for the region if it
+// is empty.
+func (o ObjectStore) EndpointURL() string {
+	if len(o.Endpoint) > 0 {
+		return strings.TrimSuffix(o.Endpoint, "/")
+	}
+	return "https://s3." + o.Region + ".amazonaws.com"
+}
+
+// ObjectURL returns the URL of the object with this key, in path style or
+// virtual-hosted style according to PathStyle.
+func (o ObjectStore) ObjectURL(key string) string {
+	key = strings.TrimPrefix(key, "/")
+	if o.PathStyle {
+		return o.EndpointURL() + "/" + o.Bucket + "/" + key
+	}
+	u, err := url.Parse(o.EndpointURL())
+	if err != nil {
+		return ""
+	}
+	u.Host = o.Bucket + "." + u.Host
+	return u.String() + "/" + key
+}
+
+// TLSConfig returns the TLS settings for connections to the store.
+func (o ObjectStore) TLSConfig() *tls.Config {
+	return &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+}
@@ -0,0 +1,74 @@
+package preset
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+func TestObjectStore(t *testing.T) {
+	var conf struct{ Store ObjectStore }
+	var warnings []error
+	d := envconf.NewDecoder(
+		envconf.WithSource(envconf.MapSource{
+			"STORE_ENDPOINT":           "https://minio.internal:9000/",
+			"STORE_BUCKET":             "backups",
+			"STORE_PATHSTYLE":          "true",
+			"STORE_INSECURESKIPVERIFY": "true",
+		}),
+		envconf.WithWarningFunc(func(err error) { warnings = append(warnings, err) }),
+	)
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	s := conf.Store
+	if u := s.ObjectURL("/daily/1.tar"); u != "https://minio.internal:9000/backups/daily/1.tar" {
+		t.Errorf("ObjectURL(): got %q", u)
+		t.Fail()
+	}
+	if !s.TLSConfig().InsecureSkipVerify {
+		t.Errorf("TLSConfig(): expected verification to be skipped")
+		t.Fail()
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "TLS CERTIFICATE VERIFICATION IS DISABLED") {
+		t.Errorf("Expected a loud warning, got %v", warnings)
+		t.Fail()
+	}
+
+	aws := ObjectStore{Region: "eu-west-1", Bucket: "logs"}
+	if u := aws.ObjectURL("a.txt"); u != "https://logs.s3.eu-west-1.amazonaws.com/a.txt" {
+		t.Errorf("ObjectURL(): got %q", u)
+		t.Fail()
+	}
+
+	conf.Store.AccessKeyID, conf.Store.SecretAccessKey = "AKIA", "wJalrXUtnFEMI"
+	if dump := envconf.Dump(&conf); strings.Contains(dump, "wJalrXUtnFEMI") || !strings.Contains(dump, envconf.Redacted) {
+		t.Errorf("Dump(): expected the secret access key to be redacted, got %q", dump)
+		t.Fail()
+	}
+}
+
+func TestObjectStoreValidate(t *testing.T) {
+	tests := []struct {
+		vals     map[string]string
+		errmatch string
+	}{
+		{map[string]string{}, "Missing config fields: STORE_BUCKET"},
+		{map[string]string{"STORE_BUCKET": "Bad_Bucket"}, `config field Store.Bucket (STORE_BUCKET): "Bad_Bucket"`},
+		{map[string]string{"STORE_BUCKET": "a..b"}, `invalid object store bucket "a..b"`},
+		{map[string]string{"STORE_BUCKET": "bkt", "STORE_ENDPOINT": "minio:9000"}, "invalid object store endpoint"},
+		{map[string]string{"STORE_BUCKET": "bkt", "STORE_ACCESSKEYID": "AKIA"}, "must be set together"},
+	}
+
+	for _, test := range tests {
+		var conf struct{ Store ObjectStore }
+		err := envconf.ReadConfigMap(&conf, test.vals)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfigMap(%v): expected an error matching '%s', got '%v'", test.vals, test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
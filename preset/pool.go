@@ -0,0 +1,51 @@
+package preset
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Pool configures a connection pool. It suits database/sql, HTTP transports
+// and client libraries such as Redis with options of the same shape.
+//
+// A zero MaxOpen means no limit; zero durations mean connections are never
+// closed for age or idleness.
+type Pool struct {
+	MaxOpen     int `default:"10"`
+	MaxIdle     int `default:"2"`
+	MaxLifetime time.Duration
+	IdleTimeout time.Duration `default:"5m"`
+}
+
+// Validate checks the pool sizes.
+func (p Pool) Validate() error {
+	switch {
+	case p.MaxOpen < 0:
+		return fmt.Errorf("pool max open must not be negative, got %d", p.MaxOpen)
+	case p.MaxIdle < 0:
+		return fmt.Errorf("pool max idle must not be negative, got %d", p.MaxIdle)
+	case p.MaxOpen > 0 && p.MaxIdle > p.MaxOpen:
+		return fmt.Errorf("pool max idle %d exceeds max open %d", p.MaxIdle, p.MaxOpen)
+	case p.MaxLifetime < 0 || p.IdleTimeout < 0:
+		return fmt.Errorf("pool durations must not be negative")
+	}
+	return nil
+}
+
+// ApplyDB sets the pool limits of db.
+func (p Pool) ApplyDB(db *sql.DB) {
+	db.SetMaxOpenConns(p.MaxOpen)
+	db.SetMaxIdleConns(p.MaxIdle)
+	db.SetConnMaxLifetime(p.MaxLifetime)
+	db.SetConnMaxIdleTime(p.IdleTimeout)
+}
+
+// ApplyTransport sets the connection limits of t. MaxOpen limits the
+// connections to each host.
+func (p Pool) ApplyTransport(t *http.Transport) {
+	t.MaxConnsPerHost = p.MaxOpen
+	t.MaxIdleConnsPerHost = p.MaxIdle
+	t.IdleConnTimeout = p.IdleTimeout
+}
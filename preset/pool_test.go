@@ -0,0 +1,68 @@
+package preset
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+func TestPool(t *testing.T) {
+	var conf struct {
+		DB    Pool
+		Cache Pool
+	}
+	input := map[string]string{
+		"DB_MAXOPEN":     "20",
+		"DB_MAXIDLE":     "5",
+		"DB_MAXLIFETIME": "1h",
+	}
+
+	if err := envconf.ReadConfigMap(&conf, input); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if expect := (Pool{20, 5, time.Hour, 5 * time.Minute}); conf.DB != expect {
+		t.Errorf("DB: expected %+v, got %+v", expect, conf.DB)
+		t.Fail()
+	}
+	if expect := (Pool{10, 2, 0, 5 * time.Minute}); conf.Cache != expect {
+		t.Errorf("Cache: expected defaults %+v, got %+v", expect, conf.Cache)
+		t.Fail()
+	}
+
+	var tr http.Transport
+	conf.DB.ApplyTransport(&tr)
+	if tr.MaxConnsPerHost != 20 || tr.MaxIdleConnsPerHost != 5 || tr.IdleConnTimeout != 5*time.Minute {
+		t.Errorf("ApplyTransport(): got %d, %d, %v", tr.MaxConnsPerHost, tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+		t.Fail()
+	}
+}
+
+func TestPoolValidate(t *testing.T) {
+	tests := []struct {
+		vals     map[string]string
+		errmatch string
+	}{
+		{map[string]string{"DB_MAXOPEN": "4", "DB_MAXIDLE": "8"}, "Invalid config field DB: pool max idle 8 exceeds max open 4"},
+		{map[string]string{"DB_MAXOPEN": "-1"}, "pool max open must not be negative"},
+		{map[string]string{"DB_IDLETIMEOUT": "-1s"}, "pool durations must not be negative"},
+	}
+
+	for _, test := range tests {
+		var conf struct{ DB Pool }
+		err := envconf.ReadConfigMap(&conf, test.vals)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfigMap(%v): expected an error matching '%s', got '%v'", test.vals, test.errmatch, err)
+			t.Fail()
+		}
+	}
+
+	var unlimited struct{ DB Pool }
+	if err := envconf.ReadConfigMap(&unlimited, map[string]string{"DB_MAXOPEN": "0", "DB_MAXIDLE": "8"}); err != nil {
+		t.Errorf("Unexpected error for an unlimited pool: %v", err)
+		t.Fail()
+	}
+}
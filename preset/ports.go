@@ -0,0 +1,94 @@
+package preset
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Port is a TCP or UDP port number. Zero means unset, or any free port when
+// listening.
+type Port int
+
+// Validate checks that p is a valid port number.
+func (p Port) Validate() error {
+	if p < 0 || p > 65535 {
+		return fmt.Errorf("port %d out of range", int(p))
+	}
+	return nil
+}
+
+var portType = reflect.TypeOf(Port(0))
+
+// CheckPorts finds the Port fields of conf, a struct or a pointer to one,
+// searching nested structs, and checks that they are distinct and fall
+// within [min, max]. Zero ports are ignored. It catches copy-and-paste
+// collisions in large environments at startup, and is typically called from
+// a config struct's Validate method:
+//
+//	type Listeners struct {
+//		HTTPPort    preset.Port `default:"8080"`
+//		AdminPort   preset.Port `default:"9090"`
+//		MetricsPort preset.Port `default:"9091"`
+//	}
+//
+//	func (l Listeners) Validate() error {
+//		return preset.CheckPorts(l, 1024, 65535)
+//	}
+func CheckPorts(conf interface{}, min, max Port) error {
+	v := reflect.Indirect(reflect.ValueOf(conf))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("CheckPorts: expected a struct, got %v", v.Kind())
+	}
+
+	byPort := make(map[Port][]string)
+	collectPorts(v, "", byPort)
+
+	var problems []string
+	for p, names := range byPort {
+		if p < min || p > max {
+			for _, name := range names {
+				problems = append(problems,
+					fmt.Sprintf("%s port %d outside %d-%d", name, p, min, max))
+			}
+		}
+		if len(names) > 1 {
+			problems = append(problems,
+				fmt.Sprintf("port %d used by %s", p, strings.Join(names, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// collectPorts records the non-zero Port fields of v by value.
+func collectPorts(v reflect.Value, path string, byPort map[Port][]string) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		name := field.Name
+		if len(path) > 0 {
+			name = path + "." + name
+		}
+
+		switch {
+		case field.Type == portType:
+			if p := Port(v.Field(i).Int()); p != 0 {
+				byPort[p] = append(byPort[p], name)
+			}
+		case field.Type.Kind() == reflect.Struct:
+			if field.Anonymous {
+				name = path
+			}
+			collectPorts(v.Field(i), name, byPort)
+		}
+	}
+}
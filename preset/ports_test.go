@@ -0,0 +1,56 @@
+package preset
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+type listeners struct {
+	HTTPPort    Port `default:"8080"`
+	AdminPort   Port `default:"9090"`
+	MetricsPort Port `default:"9091"`
+	Debug       struct {
+		PprofPort Port
+	}
+}
+
+func (l listeners) Validate() error {
+	return CheckPorts(l, 1024, 65535)
+}
+
+func TestPorts(t *testing.T) {
+	tests := []struct {
+		vals     map[string]string
+		valid    bool
+		errmatch string
+	}{
+		{map[string]string{}, true, ""},
+		{map[string]string{"DEBUG_PPROFPORT": "6060"}, true, ""},
+		{map[string]string{"ADMINPORT": "8080"}, false, "port 8080 used by HTTPPort, AdminPort"},
+		{map[string]string{"DEBUG_PPROFPORT": "9091"}, false, "port 9091 used by MetricsPort, Debug.PprofPort"},
+		{map[string]string{"HTTPPORT": "80"}, false, "HTTPPort port 80 outside 1024-65535"},
+		{map[string]string{"HTTPPORT": "70000"}, false, "Invalid value for config field HTTPPort (HTTPPORT): port 70000 out of range"},
+	}
+
+	for _, test := range tests {
+		var l listeners
+		err := envconf.ReadConfigMap(&l, test.vals)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+	}
+
+	if err := CheckPorts(3, 1, 2); err == nil {
+		t.Errorf("CheckPorts(): expected an error for a non-struct")
+		t.Fail()
+	}
+}
@@ -0,0 +1,14 @@
+/*
+Package preset provides ready-made config blocks for needs that recur across
+services. Each preset is an ordinary struct to be nested in an application's
+config struct and read by envconf:
+
+	var conf struct {
+		Retry preset.Backoff
+	}
+	err := envconf.ReadConfigEnv(&conf)
+
+Presets carry sensible defaults and check their own invariants with a
+Validate method.
+*/
+package preset
@@ -0,0 +1,90 @@
+package envconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A DecodeResult holds everything a decode produced, for callers which want
+// more than an error: the config, how each field got its value and the
+// warnings raised along the way.
+type DecodeResult struct {
+	// Config is the struct pointer passed to Decoder.Resolve.
+	Config interface{}
+	// Report records how each variable was read.
+	Report Report
+	// Warnings holds the warnings of the decode, which Resolve collects
+	// here instead of passing them to the Decoder's warning func.
+	Warnings []error
+	// Err is the error Decode would have returned.
+	Err error
+}
+
+// A Report describes how the fields of a config struct got their values.
+type Report struct {
+	// Fields holds a record for each variable read, in struct order. As with
+	// WithAnalytics, fields whose types implement EnvDecoder and Lazy fields
+	// are not included.
+	Fields []VariableUse
+}
+
+// Field returns the record for the field at path, a path of Go field names
+// joined with ".", such as "DB.URL".
+func (r Report) Field(path string) (VariableUse, bool) {
+	for _, u := range r.Fields {
+		if u.Field == path {
+			return u, true
+		}
+	}
+	return VariableUse{}, false
+}
+
+// String describes each field on a line of its own, such as
+//
+//	DB.URL: set from DATABASE_URL in layers(env, dotenv)
+//	DB.Pool: default (blank)
+//
+// where "(blank)" marks a field whose variable was blank and treated as
+// unset.
+func (r Report) String() string {
+	var b strings.Builder
+	for _, u := range r.Fields {
+		switch u.Status {
+		case UseSet, UseDefaultFrom:
+			fmt.Fprintf(&b, "%s: %s from %s in %s", u.Field, u.Status, u.From, u.Source)
+		default:
+			fmt.Fprintf(&b, "%s: %s", u.Field, u.Status)
+		}
+		if u.Blank {
+			b.WriteString(" (blank)")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Resolve decodes conf like Decode and returns the outcome as a DecodeResult,
+// so that a program can log or inspect what the decode did:
+//
+//	res := d.Resolve(&conf)
+//	for _, w := range res.Warnings {
+//		logger.Warn("config", "warning", w)
+//	}
+//	if res.Err != nil {
+//		return res.Err
+//	}
+//
+// A sink set by WithAnalytics is still called.
+func (d *Decoder) Resolve(conf interface{}) DecodeResult {
+	res := DecodeResult{Config: conf}
+	rd := *d
+	rd.warn = func(err error) { res.Warnings = append(res.Warnings, err) }
+	rd.analytics = func(uses []VariableUse) {
+		res.Report.Fields = uses
+		if d.analytics != nil {
+			d.analytics(uses)
+		}
+	}
+	res.Err = rd.Decode(conf)
+	return res
+}
@@ -0,0 +1,131 @@
+package envconf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	var conf struct {
+		Port    int    `required:"true"`
+		Host    string `default:"localhost"`
+		Replica string `defaultFrom:"PRIMARY"`
+		Workers int    `deprecated:"use CONCURRENCY"`
+		Debug   bool
+	}
+	var analytics []VariableUse
+	var logged []error
+	d := NewDecoder(
+		WithSource(MapSource{"PORT": "8080", "PRIMARY": "db", "WORKERS": "4"}),
+		WithAnalytics(func(uses []VariableUse) { analytics = uses }),
+		WithWarningFunc(func(err error) { logged = append(logged, err) }),
+	)
+
+	res := d.Resolve(&conf)
+	if res.Err != nil || res.Config != &conf || conf.Port != 8080 {
+		t.Errorf("Resolve(): unexpected %+v, %+v", res, conf)
+		t.FailNow()
+	}
+
+	expect := []VariableUse{
+		{Name: "PORT", Field: "Port", Status: UseSet, From: "PORT", Source: "map"},
+		{Name: "HOST", Field: "Host", Status: UseDefault},
+		{Name: "REPLICA", Field: "Replica", Status: UseDefaultFrom, From: "PRIMARY", Source: "map"},
+		{Name: "WORKERS", Field: "Workers", Status: UseSet, From: "WORKERS", Source: "map", Deprecated: true},
+		{Name: "DEBUG", Field: "Debug", Status: UseUnset},
+	}
+	if !reflect.DeepEqual(res.Report.Fields, expect) || !reflect.DeepEqual(analytics, expect) {
+		t.Errorf("Report: expected %+v, got %+v", expect, res.Report.Fields)
+		t.Fail()
+	}
+	if u, ok := res.Report.Field("Replica"); !ok || u.From != "PRIMARY" {
+		t.Errorf("Report.Field(): unexpected %+v, %v", u, ok)
+		t.Fail()
+	}
+	if _, ok := res.Report.Field("Missing"); ok {
+		t.Errorf("Report.Field(): expected no record for Missing")
+		t.Fail()
+	}
+
+	errmatch := "Config variable WORKERS is deprecated: use CONCURRENCY"
+	if len(res.Warnings) != 1 || res.Warnings[0].Error() != errmatch || len(logged) != 0 {
+		t.Errorf("Warnings: expected [%s] and nothing logged, got %v and %v", errmatch, res.Warnings, logged)
+		t.Fail()
+	}
+
+	res = NewDecoder(WithSource(MapSource{})).Resolve(&conf)
+	if res.Err == nil || res.Err.Error() != "Missing config fields: PORT" {
+		t.Errorf("expected an error matching '%s', got '%v'", "Missing config fields: PORT", res.Err)
+		t.Fail()
+	}
+}
+
+func TestResolveBlank(t *testing.T) {
+	var conf struct {
+		Host string `default:"localhost"`
+		Port int
+		User string
+	}
+	d := NewDecoder(
+		WithSource(MapSource{"HOST": " ", "PORT": "\t", "USER": "app"}),
+		WithBlankAsUnset(),
+	)
+
+	res := d.Resolve(&conf)
+	if res.Err != nil || conf.Host != "localhost" || conf.Port != 0 {
+		t.Errorf("Resolve(): unexpected %+v, %+v", res, conf)
+		t.FailNow()
+	}
+
+	expect := []VariableUse{
+		{Name: "HOST", Field: "Host", Status: UseDefault, Blank: true},
+		{Name: "PORT", Field: "Port", Status: UseUnset, Blank: true},
+		{Name: "USER", Field: "User", Status: UseSet, From: "USER", Source: "map"},
+	}
+	if !reflect.DeepEqual(res.Report.Fields, expect) {
+		t.Errorf("Report: expected %+v, got %+v", expect, res.Report.Fields)
+		t.Fail()
+	}
+
+	s := "Host: default (blank)\nPort: unset (blank)\nUser: set from USER in map\n"
+	if res.Report.String() != s {
+		t.Errorf("Report.String(): expected %q, got %q", s, res.Report.String())
+		t.Fail()
+	}
+}
+
+func TestReadConfigReport(t *testing.T) {
+	var conf struct {
+		Port  int `default:"8080"`
+		Host  string
+		Token string
+		Debug bool
+	}
+	src := LayerSources(
+		EnvSource{Env: MapEnv{"HOST": "db"}},
+		LayerSources(MapSource{"TOKEN": "secret"}),
+	)
+	report, err := ReadConfigReport(&conf, src)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if u, _ := report.Field("Host"); u.Source != "env" {
+		t.Errorf("Report.Field(): expected HOST from env, got %+v", u)
+		t.Fail()
+	}
+	if u, _ := report.Field("Token"); u.Source != "map" {
+		t.Errorf("Report.Field(): expected TOKEN from the nested map, got %+v", u)
+		t.Fail()
+	}
+
+	expect := `Port: default
+Host: set from HOST in env
+Token: set from TOKEN in map
+Debug: unset
+`
+	if report.String() != expect {
+		t.Errorf("Report.String(): expected %q, got %q", expect, report.String())
+		t.Fail()
+	}
+}
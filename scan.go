@@ -0,0 +1,105 @@
+package envconf
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// A SecretScanner inspects a value and, if it looks like a credential,
+// returns a description of what it looks like, such as "an AWS access key".
+type SecretScanner func(value string) (kind string, found bool)
+
+var (
+	awsAccessKey   = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+	privateKeyPEM  = regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----`)
+	urlCredentials = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:[^/\s@]+@`)
+)
+
+// AWSAccessKeyScanner finds AWS access key IDs.
+func AWSAccessKeyScanner(value string) (string, bool) {
+	return "an AWS access key", awsAccessKey.MatchString(value)
+}
+
+// PrivateKeyScanner finds PEM-encoded private keys. Certificates and public
+// keys are not reported.
+func PrivateKeyScanner(value string) (string, bool) {
+	return "a private key", privateKeyPEM.MatchString(value)
+}
+
+// URLCredentialsScanner finds URLs with a password, such as
+// postgres://app:hunter2@db/app.
+func URLCredentialsScanner(value string) (string, bool) {
+	return "a URL with a password", urlCredentials.MatchString(value)
+}
+
+// DefaultSecretScanners are the scanners used by WithSecretScanners when it
+// is given none.
+var DefaultSecretScanners = []SecretScanner{
+	AWSAccessKeyScanner,
+	PrivateKeyScanner,
+	URLCredentialsScanner,
+}
+
+// WithSecretScanners runs scanners over the string, []string and []byte
+// fields which are not tagged secret:"true" once a decode succeeds, and
+// reports each value that looks like a credential to the warning func. This
+// catches fields which should be tagged as secrets before their values end
+// up in a Dump or a snapshot. The warnings name the field and variable, but
+// never include the value. With no scanners, DefaultSecretScanners are used.
+func WithSecretScanners(scanners ...SecretScanner) Option {
+	if len(scanners) == 0 {
+		scanners = DefaultSecretScanners
+	}
+	return func(d *Decoder) { d.scanners = scanners }
+}
+
+// scanSecrets runs the Decoder's secret scanners over the fields of the
+// config struct v described by fields.
+func (d *Decoder) scanSecrets(v reflect.Value, fields []PlannedField) {
+	if len(d.scanners) == 0 {
+		return
+	}
+	for _, pf := range fields {
+		if pf.readsPrefix() || pf.Composite || isSecret(pf.Field) {
+			continue
+		}
+		fieldVal, ok := fieldByIndex(v, pf.index, false)
+		if !ok {
+			continue
+		}
+
+		var values []string
+		switch {
+		case fieldVal.Kind() == reflect.String:
+			values = []string{fieldVal.String()}
+		case fieldVal.Type() == bytesType:
+			values = []string{string(fieldVal.Bytes())}
+		case fieldVal.Kind() == reflect.Slice && fieldVal.Type().Elem().Kind() == reflect.String:
+			for i := 0; i < fieldVal.Len(); i++ {
+				values = append(values, fieldVal.Index(i).String())
+			}
+		}
+		if kind, found := d.scan(values); found {
+			d.warn(fmt.Errorf(
+				"Config field %s (%s) looks like it holds %s; should it be tagged secret:\"true\"?",
+				strings.Join(pf.Path, "."), pf.Name, kind))
+		}
+	}
+}
+
+// scan returns the first finding of the Decoder's scanners in values.
+func (d *Decoder) scan(values []string) (string, bool) {
+	for _, value := range values {
+		if len(value) == 0 {
+			continue
+		}
+		for _, scanner := range d.scanners {
+			if kind, found := scanner(value); found {
+				return kind, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,156 @@
+package envconf
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// A Schema describes the variables read for a config struct, for tools
+// which document or inventory config. It marshals to JSON.
+//
+// The variables, and so everything written from a Schema, are in the order
+// set by WithExportOrder, and a Schema is written the same way on every run,
+// so that generated files only change in a diff when the config does.
+type Schema struct {
+	// Module, Version, Revision, RevisionTime and Modified describe the
+	// binary that produced the schema, from its build info. They are set
+	// by ExportSchema.
+	Module       string `json:"module,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revision_time,omitempty"`
+	Modified     bool   `json:"modified,omitempty"`
+
+	// Fingerprint changes whenever the variables, their types or their
+	// tags do, so that an inventory can tell which binaries share a config
+	// contract.
+	Fingerprint string           `json:"fingerprint"`
+	Variables   []SchemaVariable `json:"variables"`
+}
+
+// A SchemaVariable describes one variable of a Schema.
+type SchemaVariable struct {
+	Name string `json:"name"`
+	// Prefix is true if Name is the prefix of variables read by a field
+	// whose type implements EnvDecoder, or by a slice of structs.
+	Prefix bool `json:"prefix,omitempty"`
+	// Field is the path of Go field names, joined with ".".
+	Field   string   `json:"field"`
+	Type    string   `json:"type"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Default is the field's "default" tag or, for secrets, Redacted; see
+	// the "showdefault" tag.
+	Default     string `json:"default,omitempty"`
+	DefaultFrom string `json:"default_from,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	RequiredIf  string `json:"required_if,omitempty"`
+	Description string `json:"description,omitempty"`
+	Secret      bool   `json:"secret,omitempty"`
+}
+
+// An ExportOrder decides the order of the variables in a Schema, and so in
+// the documentation and templates written from one.
+type ExportOrder int
+
+const (
+	// StructOrder lists variables in the order their fields are declared,
+	// depth first, so that related settings stay together.
+	StructOrder ExportOrder = iota
+	// NameOrder lists variables sorted by name.
+	NameOrder
+)
+
+// WithExportOrder sets the order of the variables in Schema, and so in
+// ExportSchema, Markdown, Usage, WriteTemplate and WriteCompose. The default
+// is StructOrder.
+func WithExportOrder(order ExportOrder) Option {
+	return func(d *Decoder) { d.exportOrder = order }
+}
+
+// SortByName sorts the variables of the schema by name, as NameOrder does.
+func (s *Schema) SortByName() {
+	sort.SliceStable(s.Variables, func(i, j int) bool {
+		return s.Variables[i].Name < s.Variables[j].Name
+	})
+}
+
+// Schema describes the variables the Decoder reads for conf.
+func (d *Decoder) Schema(conf interface{}) (*Schema, error) {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schema{Fingerprint: fingerprint(plan), Variables: []SchemaVariable{}}
+	for _, pf := range plan {
+		t := pf.Field.Type
+		if reflect.PtrTo(t).Implements(lazyBinderType) {
+			t = lazyValueType(t)
+		}
+		s.Variables = append(s.Variables, SchemaVariable{
+			Name:        pf.Name,
+			Prefix:      pf.readsPrefix(),
+			Field:       strings.Join(pf.Path, "."),
+			Type:        t.String(),
+			Aliases:     pf.Aliases,
+			Default:     schemaDefault(pf.Field),
+			DefaultFrom: pf.DefaultFrom,
+			Required:    pf.Field.Tag.Get("required") == "true",
+			RequiredIf:  pf.Field.Tag.Get("required_if"),
+			Description: pf.Field.Tag.Get("desc"),
+			Secret:      isSecret(pf.Field),
+		})
+	}
+	if d.exportOrder == NameOrder {
+		s.SortByName()
+	}
+	return s, nil
+}
+
+// schemaDefault returns the default of field as documentation may show it.
+// The defaults of secrets are replaced by Redacted, as they may be real
+// credentials, unless the field is tagged showdefault:"true".
+func schemaDefault(field reflect.StructField) string {
+	def := field.Tag.Get("default")
+	if len(def) > 0 && isSecret(field) && field.Tag.Get("showdefault") != "true" {
+		return Redacted
+	}
+	return def
+}
+
+// ExportSchema writes the schema of conf as JSON to w, stamped with the
+// module version and VCS revision of the running binary, so that a fleet
+// inventory can track which config contract each deployed version supports.
+// A release process can run it from a hidden flag or subcommand:
+//
+//	if *exportSchema {
+//		err := envconf.NewDecoder().ExportSchema(os.Stdout, &conf)
+//		...
+//	}
+func (d *Decoder) ExportSchema(w io.Writer, conf interface{}) error {
+	s, err := d.Schema(conf)
+	if err != nil {
+		return err
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		s.Module, s.Version = bi.Main.Path, bi.Main.Version
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				s.Revision = setting.Value
+			case "vcs.time":
+				s.RevisionTime = setting.Value
+			case "vcs.modified":
+				s.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(s)
+}
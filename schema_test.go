@@ -0,0 +1,118 @@
+package envconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	var conf struct {
+		Port     int    `required:"true" desc:"port to listen on"`
+		Password string `secret:"true"`
+		Replica  string `defaultFrom:"PRIMARY" default:"localhost"`
+		Cache    cacheConfig
+		Bucket   Lazy[string] `required_if:"Port=80"`
+	}
+	s, err := NewDecoder(WithPrefix("APP_")).Schema(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	expect := []SchemaVariable{
+		{Name: "APP_PORT", Field: "Port", Type: "int", Required: true, Description: "port to listen on"},
+		{Name: "APP_PASSWORD", Field: "Password", Type: "string", Secret: true},
+		{Name: "APP_REPLICA", Field: "Replica", Type: "string", Default: "localhost", DefaultFrom: "APP_PRIMARY"},
+		{Name: "APP_CACHE_", Prefix: true, Field: "Cache", Type: "envconf.cacheConfig"},
+		{Name: "APP_BUCKET", Field: "Bucket", Type: "string", RequiredIf: "Port=80"},
+	}
+	if !reflect.DeepEqual(s.Variables, expect) {
+		t.Errorf("Schema(): expected %+v, got %+v", expect, s.Variables)
+		t.Fail()
+	}
+
+	var buf bytes.Buffer
+	if err := NewDecoder(WithPrefix("APP_")).ExportSchema(&buf, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	var exported Schema
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if exported.Fingerprint != s.Fingerprint || len(exported.Fingerprint) == 0 ||
+		!reflect.DeepEqual(exported.Variables, expect) {
+		t.Errorf("ExportSchema(): unexpected %s", buf.String())
+		t.Fail()
+	}
+}
+
+func TestExportOrder(t *testing.T) {
+	type config struct {
+		Server struct {
+			Port int    `default:"80"`
+			Host string `desc:"host to bind"`
+		}
+		Zone    string `required:"true"`
+		Aliased string `alias:"B_NAME,A_NAME"`
+		Debug   bool
+	}
+	exporters := map[string]func(*Decoder, *bytes.Buffer) error{
+		"ExportSchema":  func(d *Decoder, buf *bytes.Buffer) error { return d.ExportSchema(buf, &config{}) },
+		"Markdown":      func(d *Decoder, buf *bytes.Buffer) error { return d.Markdown(buf, &config{}) },
+		"WriteTemplate": func(d *Decoder, buf *bytes.Buffer) error { return d.WriteTemplate(buf, &config{}) },
+		"WriteCompose":  func(d *Decoder, buf *bytes.Buffer) error { return d.WriteCompose(buf, &config{}) },
+		"Usage": func(d *Decoder, buf *bytes.Buffer) error {
+			u, err := d.Usage(&config{})
+			buf.WriteString(u)
+			return err
+		},
+	}
+	orders := []struct {
+		order ExportOrder
+		names []string
+	}{
+		{StructOrder, []string{"SERVER_PORT", "SERVER_HOST", "ZONE", "ALIASED", "DEBUG"}},
+		{NameOrder, []string{"ALIASED", "DEBUG", "SERVER_HOST", "SERVER_PORT", "ZONE"}},
+	}
+
+	for _, o := range orders {
+		for name, export := range exporters {
+			var first, second bytes.Buffer
+			if err := export(NewDecoder(WithExportOrder(o.order)), &first); err != nil {
+				t.Errorf("Unexpected error %v", err)
+				t.FailNow()
+			}
+			if err := export(NewDecoder(WithExportOrder(o.order)), &second); err != nil {
+				t.Errorf("Unexpected error %v", err)
+				t.FailNow()
+			}
+			if first.String() != second.String() {
+				t.Errorf("%s(): output differs between runs:\n%s\n%s", name, first.String(), second.String())
+				t.Fail()
+			}
+
+			// the names appear in the order expected
+			out, last := first.String(), -1
+			for _, v := range o.names {
+				i := strings.Index(out, v)
+				if i < last {
+					t.Errorf("%s(): expected variables in the order %v, got\n%s", name, o.names, out)
+					t.Fail()
+					break
+				}
+				last = i
+			}
+		}
+	}
+
+	s, _ := NewDecoder(WithExportOrder(NameOrder)).Schema(&config{})
+	if aliases := s.Variables[0].Aliases; !reflect.DeepEqual(aliases, []string{"B_NAME", "A_NAME"}) {
+		t.Errorf("Schema(): expected aliases in tag order, got %v", aliases)
+		t.Fail()
+	}
+}
@@ -0,0 +1,44 @@
+package envconf
+
+import (
+	"reflect"
+	"strings"
+)
+
+// DockerSecretsDir is where Docker Swarm and Compose mount secrets.
+const DockerSecretsDir = "/run/secrets"
+
+// DockerSecretsGetter returns a Getter serving the secret files in dir, such
+// as DockerSecretsDir. The files are named and read as by DirSource, but all
+// of them are read when DockerSecretsGetter is called, and never again. A
+// missing dir is not an error and yields a Getter with no values, so the same
+// code works outside of a container.
+func DockerSecretsGetter(dir, prefix string) (Getter, error) {
+	values, err := readDir(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return mapgetter(values).get, nil
+}
+
+var secretNameReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// secretName maps a secret file name to a variable name.
+func secretName(filename string) string {
+	return strings.ToUpper(secretNameReplacer.Replace(filename))
+}
+
+// trimNewline removes a single trailing newline, as left by most editors and
+// by echo.
+func trimNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
+
+// isSecret reports whether field holds a secret: whether it has a "secret"
+// tag, other than secret:"false". The tag's value is "true" or, for sources
+// such as secret managers, the name of the secret to read.
+func isSecret(field reflect.StructField) bool {
+	v, ok := field.Tag.Lookup("secret")
+	return ok && v != "false"
+}
@@ -0,0 +1,68 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDockerSecretsGetter(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"db-password": "hunter2\n",
+		"api.token":   "abc",
+		".hidden":     "nope",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	getter, err := DockerSecretsGetter(dir, "APP_")
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	tests := []struct{ name, expect string }{
+		{"APP_DB_PASSWORD", "hunter2"},
+		{"APP_API_TOKEN", "abc"},
+		{"APP_.HIDDEN", ""},
+		{"APP_HIDDEN", ""},
+		{"APP_SUBDIR", ""},
+	}
+	for _, test := range tests {
+		if v := getter(test.name); v != test.expect {
+			t.Errorf("getter(%q): expected %q, got %q", test.name, test.expect, v)
+			t.Fail()
+		}
+	}
+
+	var conf struct {
+		DBPassword string `defaultFrom:"DB_PASSWORD"`
+	}
+	if err := NewDecoder(WithGetter(getter), WithPrefix("APP_")).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.DBPassword != "hunter2" {
+		t.Errorf("DBPassword: expected 'hunter2', got %q", conf.DBPassword)
+		t.Fail()
+	}
+}
+
+func TestDockerSecretsGetterMissingDir(t *testing.T) {
+	getter, err := DockerSecretsGetter(filepath.Join(t.TempDir(), "missing"), "")
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if v := getter("ANYTHING"); v != "" {
+		t.Errorf("getter(): expected no values, got %q", v)
+		t.Fail()
+	}
+}
@@ -0,0 +1,111 @@
+package envconf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// SelfCheckCommand is the subcommand which makes RunSelfCheck check the
+// config and exit.
+const SelfCheckCommand = "config-check"
+
+// exit is os.Exit, replaced in tests.
+var exit = os.Exit
+
+// RunSelfCheck gives a program a built-in preflight check of its
+// environment. If the first argument in args is SelfCheckCommand, conf is
+// decoded from the process environment, a report is printed to standard
+// output, and the program exits with status 0 if the config is valid or 1
+// if it is not. Otherwise RunSelfCheck does nothing. Call it at the start
+// of main:
+//
+//	envconf.RunSelfCheck(os.Args, &conf)
+func RunSelfCheck(args []string, conf interface{}) {
+	NewDecoder().RunSelfCheck(args, conf)
+}
+
+// RunSelfCheck is like the RunSelfCheck function, but decodes with the
+// Decoder.
+func (d *Decoder) RunSelfCheck(args []string, conf interface{}) {
+	if len(args) < 2 || args[1] != SelfCheckCommand {
+		return
+	}
+	if err := d.SelfCheck(os.Stdout, conf); err != nil {
+		exit(1)
+	}
+	exit(0)
+}
+
+// SelfCheck decodes conf, collecting every problem rather than stopping at
+// the first, and writes a report to w of where each variable's value comes
+// from, followed by the problems found. Values are not printed, so the
+// report is safe to show for secrets. The error from decoding is returned.
+func (d *Decoder) SelfCheck(w io.Writer, conf interface{}) error {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	cd := *d
+	cd.errorMode = CollectAll
+	decodeErr := cd.Decode(conf)
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIABLE\tSTATUS")
+	for _, pf := range plan {
+		if pf.readsPrefix() {
+			fmt.Fprintf(tw, "%s*\tread by %v\n", pf.Name, pf.Field.Type)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", pf.Name, d.selfCheckStatus(pf))
+	}
+	tw.Flush()
+
+	if decodeErr == nil {
+		buf.WriteString("\nConfig OK\n")
+	} else {
+		buf.WriteString("\nConfig invalid:\n")
+		errs, ok := decodeErr.(Errors)
+		if !ok {
+			errs = Errors{decodeErr}
+		}
+		for _, err := range errs {
+			fmt.Fprintf(&buf, "  %v\n", err)
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// selfCheckStatus describes where the value of pf comes from.
+func (d *Decoder) selfCheckStatus(pf PlannedField) string {
+	if v, err := d.get(pf.Name); err != nil {
+		return "lookup failed"
+	} else if len(v) > 0 {
+		return "set"
+	}
+	for _, name := range pf.names()[1:] {
+		if v, err := d.get(name); err != nil {
+			return "lookup of " + name + " failed"
+		} else if len(v) > 0 {
+			return "from " + name
+		}
+	}
+
+	switch {
+	case pf.Field.Tag.Get("required") == "true":
+		return "MISSING"
+	case len(pf.Field.Tag.Get("default")) > 0:
+		return "default"
+	case len(pf.Field.Tag.Get("required_if")) > 0:
+		return "unset (required if " + pf.Field.Tag.Get("required_if") + ")"
+	}
+	return "unset"
+}
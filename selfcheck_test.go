@@ -0,0 +1,81 @@
+package envconf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type selfCheckConfig struct {
+	Port     int    `required:"true"`
+	Name     string `required:"true"`
+	Timeout  int    `default:"5"`
+	Replica  string `defaultFrom:"PRIMARY"`
+	Password string `secret:"true"`
+	TLS      bool
+	CertFile string `required_if:"TLS=true"`
+}
+
+func TestSelfCheck(t *testing.T) {
+	input := MapSource{"PORT": "http", "PRIMARY": "db", "PASSWORD": "hunter2"}
+
+	var buf bytes.Buffer
+	var conf selfCheckConfig
+	err := NewDecoder(WithSource(input)).SelfCheck(&buf, &conf)
+	if err == nil {
+		t.Errorf("SelfCheck(): expected an error")
+		t.Fail()
+	}
+	expect := `VARIABLE  STATUS
+PORT      set
+NAME      MISSING
+TIMEOUT   default
+REPLICA   from PRIMARY
+PASSWORD  set
+TLS       unset
+CERTFILE  unset (required if TLS=true)
+
+Config invalid:
+  Invalid value for config field Port (PORT): strconv.ParseInt: parsing "http": invalid syntax
+  Missing config fields: NAME
+`
+	if buf.String() != expect {
+		t.Errorf("SelfCheck(): expected\n%s\ngot\n%s", expect, buf.String())
+		t.Fail()
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("SelfCheck(): expected no values in the report")
+		t.Fail()
+	}
+
+	input["PORT"], input["NAME"] = "80", "app"
+	buf.Reset()
+	if err := NewDecoder(WithSource(input)).SelfCheck(&buf, &conf); err != nil ||
+		!strings.HasSuffix(buf.String(), "\nConfig OK\n") {
+		t.Errorf("SelfCheck(): expected a passing report, got %v\n%s", err, buf.String())
+		t.Fail()
+	}
+}
+
+func TestRunSelfCheck(t *testing.T) {
+	saved := exit
+	defer func() { exit = saved }()
+	var status []int
+	exit = func(code int) { status = append(status, code) }
+
+	d := NewDecoder(WithSource(MapSource{}))
+	var conf struct {
+		Port int `default:"80"`
+	}
+	d.RunSelfCheck([]string{"myservice", "serve"}, &conf)
+	d.RunSelfCheck([]string{"myservice"}, &conf)
+	if len(status) > 0 {
+		t.Errorf("RunSelfCheck(): expected no exit without the subcommand, got %v", status)
+		t.Fail()
+	}
+	d.RunSelfCheck([]string{"myservice", SelfCheckCommand}, &conf)
+	if len(status) != 1 || status[0] != 0 {
+		t.Errorf("RunSelfCheck(): expected exit status 0, got %v", status)
+		t.Fail()
+	}
+}
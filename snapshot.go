@@ -0,0 +1,188 @@
+package envconf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// SecretPolicy decides whether SaveSnapshot writes the values of fields
+// with a "secret" tag.
+type SecretPolicy int
+
+const (
+	// OmitSecrets leaves secrets out of snapshots, so they are looked up
+	// in the Decoder's source again by LoadSnapshot.
+	OmitSecrets SecretPolicy = iota
+	// IncludeSecrets writes secrets to snapshots. The file is readable
+	// only by its owner, but should still be kept off shared storage.
+	IncludeSecrets
+)
+
+// ErrSnapshotMismatch is returned by LoadSnapshot when the snapshot was
+// saved for a different config schema.
+var ErrSnapshotMismatch = errors.New("Config snapshot does not match the config schema")
+
+// snapshot is the format of a snapshot file.
+type snapshot struct {
+	Fingerprint string            `json:"fingerprint"`
+	Saved       time.Time         `json:"saved"`
+	Values      map[string]string `json:"values"`
+}
+
+// SaveSnapshot writes the raw values of conf's fields, as returned by
+// Values, to the file at path, so that a process which restarts often can
+// load them with LoadSnapshot rather than resolving them from slow remote
+// sources again. It should be called once conf has been decoded successfully
+// by d.
+func (d *Decoder) SaveSnapshot(path string, conf interface{}, policy SecretPolicy) error {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string, len(plan))
+	if err := d.addValues(values, plan, policy == IncludeSecrets); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(snapshot{fingerprint(plan), time.Now(), values}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Values returns the raw values of the variables read for conf's fields,
+// including their aliases and defaultFrom variables, with the empty string
+// for those which are unset. Decoding from a MapSource of the values gives
+// the same config without consulting any other source.
+//
+// The values are looked up again through the Decoder's source; the remote
+// sources in envconf's subpackages cache what they served, so this doesn't
+// reach the remote store again. For Indexed fields, the variables of each
+// element are included, and those of the first unset element, which ends
+// the slice. For fields whose types implement EnvDecoder, DecodeEnv is
+// called on a zero value of the type to learn which variables it reads.
+func (d *Decoder) Values(conf interface{}) (map[string]string, error) {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(plan))
+	if err := d.addValues(values, plan, true); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// addValues adds the raw values of the variables read for the fields of
+// plan to values. Secret fields are left out unless secrets is true.
+func (d *Decoder) addValues(values map[string]string, plan []PlannedField, secrets bool) error {
+	for _, pf := range plan {
+		if !secrets && isSecret(pf.Field) {
+			continue
+		}
+
+		switch {
+		case pf.Indexed:
+			if err := d.addElementValues(values, pf, secrets); err != nil {
+				return err
+			}
+		case pf.SelfDecoding:
+			var err error
+			ed := reflect.New(pf.Field.Type).Interface().(EnvDecoder)
+			ed.DecodeEnv(pf.Name, func(name string) string {
+				v, gerr := d.get(name)
+				if err == nil {
+					err = gerr
+				}
+				values[name] = v
+				return v
+			})
+			if err != nil {
+				return err
+			}
+		default:
+			for _, name := range pf.names() {
+				v, err := d.get(name)
+				if err != nil {
+					return err
+				}
+				values[name] = v
+			}
+		}
+	}
+	return nil
+}
+
+// addElementValues adds the values of the elements of the Indexed field pf
+// to values, up to and including the first element which is unset.
+func (d *Decoder) addElementValues(values map[string]string, pf PlannedField, secrets bool) error {
+	for i := 0; ; i++ {
+		plan := d.elementPlan(pf, i)
+		set, err := d.elementSet(plan, pf.Name+strconv.Itoa(i)+"_")
+		if err != nil {
+			return err
+		}
+		if err := d.addValues(values, plan, secrets); err != nil || !set {
+			return err
+		}
+	}
+}
+
+// LoadSnapshot decodes conf from a snapshot written by SaveSnapshot. Values
+// the snapshot doesn't hold, such as secrets left out by OmitSecrets, are
+// looked up in the Decoder's source as usual, and every value is parsed and
+// validated as by Decode.
+//
+// If the snapshot was saved for a config struct with different fields,
+// variable names, types or tags, ErrSnapshotMismatch is returned and conf is
+// untouched; the caller should fall back to Decode.
+//
+//	if err := d.LoadSnapshot(path, &conf); err != nil {
+//		if err := d.Decode(&conf); err != nil {
+//			return err
+//		}
+//		err = d.SaveSnapshot(path, &conf, envconf.OmitSecrets)
+//	}
+func (d *Decoder) LoadSnapshot(path string, conf interface{}) error {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("Invalid config snapshot %s: %v", path, err)
+	}
+	if snap.Fingerprint != fingerprint(plan) {
+		return ErrSnapshotMismatch
+	}
+
+	sd := *d
+	sd.source = LayerSources(MapSource(snap.Values), d.source)
+	sd.prefetcher = nil
+	return sd.Decode(conf)
+}
+
+// fingerprint hashes the variable names, types and tags of a plan, so that
+// a snapshot is only loaded into the schema it was saved from.
+func fingerprint(plan []PlannedField) string {
+	h := sha256.New()
+	for _, pf := range plan {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%v\n",
+			pf.Name, pf.DefaultFrom, pf.Field.Type, pf.Field.Tag, pf.Path)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,150 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type snapshotConfig struct {
+	Host     string `required:"true"`
+	Port     int    `default:"5432"`
+	Password string `secret:"true"`
+}
+
+// countingSource records the variables looked up in it.
+type countingSource struct {
+	src     Source
+	lookups []string
+}
+
+func (c *countingSource) Lookup(key string) (string, bool, error) {
+	c.lookups = append(c.lookups, key)
+	return c.src.Lookup(key)
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	remote := MapSource{"HOST": "db.internal", "PASSWORD": "hunter2"}
+
+	var conf snapshotConfig
+	d := NewDecoder(WithSource(remote))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if err := d.SaveSnapshot(path, &conf, OmitSecrets); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "db.internal") || strings.Contains(string(data), "hunter2") {
+		t.Errorf("Expected the snapshot to hold everything but the secret, got %s", data)
+		t.Fail()
+	}
+
+	// Only the secret is looked up when loading.
+	counting := &countingSource{src: remote}
+	d = NewDecoder(WithSource(counting))
+	conf = snapshotConfig{}
+	if err := d.LoadSnapshot(path, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf != (snapshotConfig{"db.internal", 5432, "hunter2"}) {
+		t.Errorf("Unexpected config %+v", conf)
+		t.Fail()
+	}
+	if strings.Join(counting.lookups, ",") != "PASSWORD" {
+		t.Errorf("Expected only the secret to be looked up, got %v", counting.lookups)
+		t.Fail()
+	}
+
+	// With IncludeSecrets nothing is looked up.
+	if err := d.SaveSnapshot(path, &conf, IncludeSecrets); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	counting.lookups = nil
+	conf = snapshotConfig{}
+	if err := d.LoadSnapshot(path, &conf); err != nil || conf.Password != "hunter2" || len(counting.lookups) > 0 {
+		t.Errorf("Expected the whole config from the snapshot, got %+v, %v, %v", conf, counting.lookups, err)
+		t.Fail()
+	}
+
+	// A changed schema doesn't load.
+	var other struct {
+		Host string `required:"true"`
+		Port string
+	}
+	if err := d.LoadSnapshot(path, &other); err != ErrSnapshotMismatch {
+		t.Errorf("Expected ErrSnapshotMismatch, got %v", err)
+		t.Fail()
+	}
+	if err := NewDecoder(WithPrefix("APP_")).LoadSnapshot(path, &conf); err != ErrSnapshotMismatch {
+		t.Errorf("Expected ErrSnapshotMismatch for a new prefix, got %v", err)
+		t.Fail()
+	}
+
+	if err := d.LoadSnapshot(filepath.Join(t.TempDir(), "missing.json"), &conf); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSnapshotPrefixed(t *testing.T) {
+	type upstream struct {
+		Host  string
+		Token string `secret:"true"`
+	}
+	var conf struct {
+		Cache     cacheConfig
+		Upstreams []upstream
+		Port      int
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	remote := MapSource{
+		"CACHE_REDIS_ADDRS": "a:6379,b:6379",
+		"CACHE_REDIS_DB":    "2",
+		"UPSTREAMS_0_HOST":  "a.internal",
+		"UPSTREAMS_0_TOKEN": "t0",
+		"UPSTREAMS_1_HOST":  "b.internal",
+		"UPSTREAMS_1_TOKEN": "t1",
+		"PORT":              "80",
+	}
+	d := NewDecoder(WithSource(remote))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	want := conf
+
+	values, err := d.Values(&conf)
+	if err != nil || values["CACHE_REDIS_DB"] != "2" || values["UPSTREAMS_1_HOST"] != "b.internal" {
+		t.Errorf("Values(): expected the prefixed variables, got %v, %v", values, err)
+		t.Fail()
+	}
+
+	if err := d.SaveSnapshot(path, &conf, OmitSecrets); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	counting := &countingSource{src: remote}
+	d = NewDecoder(WithSource(counting))
+	conf.Cache, conf.Upstreams, conf.Port = cacheConfig{}, nil, 0
+	if err := d.LoadSnapshot(path, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("Expected %+v, got %+v", want, conf)
+		t.Fail()
+	}
+	// the third element's secret is looked up to find the end of the slice
+	if strings.Join(counting.lookups, ",") != "UPSTREAMS_0_TOKEN,UPSTREAMS_1_TOKEN,UPSTREAMS_2_TOKEN" {
+		t.Errorf("Expected only the secrets to be looked up, got %v", counting.lookups)
+		t.Fail()
+	}
+}
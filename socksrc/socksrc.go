@@ -0,0 +1,223 @@
+/*
+Package socksrc shares decoded config between processes on one host over a
+unix socket. A supervisor process decodes its config once, including any
+secrets from remote stores, and publishes the values; the worker processes it
+starts read them from the socket rather than each reaching the remote stores:
+
+	// in the supervisor
+	if err := d.Decode(&conf); err != nil {
+		return err
+	}
+	pub, err := socksrc.Publish("/run/myapp/config.sock", d, &conf)
+	if err != nil {
+		return err
+	}
+	defer pub.Close()
+
+	// in each worker
+	err := envconf.ReadConfigSource(&conf, socksrc.New("/run/myapp/config.sock"))
+
+The socket is created readable and writable only by its owner, and no other
+user can reach it while it is set up, so workers must run as the same user as
+the supervisor. The values are sent as they were read, so workers parse and
+validate them as usual.
+*/
+package socksrc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+// Publisher serves config values on a unix socket.
+type Publisher struct {
+	l    net.Listener
+	path string
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// Publish serves the values of conf's fields, as returned by d.Values, on a
+// unix socket at path until the Publisher is closed. A stale socket left at
+// path by an earlier process is replaced.
+func Publish(path string, d *envconf.Decoder, conf interface{}) (*Publisher, error) {
+	values, err := d.Values(conf)
+	if err != nil {
+		return nil, err
+	}
+	return PublishValues(path, values)
+}
+
+// PublishValues serves values on a unix socket at path until the Publisher is
+// closed.
+func PublishValues(path string, values map[string]string) (*Publisher, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := listen(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{l: l, path: path, values: values}
+	go p.serve()
+	return p, nil
+}
+
+// listen creates a unix socket at path which only its owner can use. The
+// socket is created in a directory only the owner can enter, so that no
+// other user can connect before its mode is set, and then linked into place.
+// As with net.Listen, it fails if path already exists. Closing the listener
+// only unlinks the temporary name, so Publisher.Close removes path itself.
+func listen(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".socksrc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := os.Link(tmp, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// serve writes the values to each connection until the listener is closed.
+func (p *Publisher) serve() {
+	for {
+		conn, err := p.l.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		values := p.values
+		p.mu.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(DefaultTimeout))
+		json.NewEncoder(conn).Encode(values)
+		conn.Close()
+	}
+}
+
+// Update replaces the values served, for example after the supervisor
+// decodes its config again. Workers see them once they Refresh.
+func (p *Publisher) Update(values map[string]string) {
+	p.mu.Lock()
+	p.values = values
+	p.mu.Unlock()
+}
+
+// Close stops serving and removes the socket.
+func (p *Publisher) Close() error {
+	if err := p.l.Close(); err != nil {
+		return err
+	}
+	return os.Remove(p.path)
+}
+
+// DefaultTimeout is the default time limit on reading the values from a
+// socket.
+const DefaultTimeout = 5 * time.Second
+
+// Source serves the values published on a unix socket.
+type Source struct {
+	path    string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// An Option configures a Source.
+type Option func(*Source)
+
+// WithTimeout sets the time limit on reading the values from the socket. The
+// default is DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Source) {
+		s.timeout = d
+	}
+}
+
+// New returns a Source reading the values published at path.
+func New(path string, opts ...Option) *Source {
+	s := &Source{path: path, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Lookup returns the published value for name. All of the values are read
+// from the socket on the first lookup, and cached until Refresh is called.
+func (s *Source) Lookup(name string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		values, err := s.read()
+		if err != nil {
+			return "", false, fmt.Errorf("Reading config from %s failed: %v", s.path, err)
+		}
+		s.values = values
+	}
+	v, ok := s.values[name]
+	return v, ok, nil
+}
+
+// read reads the values from the socket.
+func (s *Source) read() (map[string]string, error) {
+	conn, err := net.DialTimeout("unix", s.path, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(s.timeout))
+
+	values := make(map[string]string)
+	if err := json.NewDecoder(conn).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Refresh discards the values read, so that they are read from the socket
+// again on the next lookup.
+func (s *Source) Refresh() {
+	s.mu.Lock()
+	s.values = nil
+	s.mu.Unlock()
+}
+
+// Ping checks that the socket accepts connections.
+func (s *Source) Ping(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", s.path)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Name identifies the source in errors and reports.
+func (s *Source) Name() string {
+	return "socket(" + s.path + ")"
+}
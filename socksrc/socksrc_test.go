@@ -0,0 +1,107 @@
+package socksrc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+type appConfig struct {
+	Port     int    `default:"80"`
+	Host     string `required:"true"`
+	Password string `secret:"true"`
+}
+
+func TestPublish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.sock")
+
+	var conf appConfig
+	d := envconf.NewDecoder(envconf.WithSource(envconf.MapSource{
+		"HOST": "db.internal", "PASSWORD": "hunter2", "OTHER": "x"}))
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	pub, err := Publish(path, d, &conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	defer pub.Close()
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a socket only its owner can use, got %v, %v", info, err)
+		t.Fail()
+	}
+
+	src := New(path)
+	if err := src.Ping(context.Background()); err != nil {
+		t.Errorf("Unexpected error from Ping: %v", err)
+		t.Fail()
+	}
+	var worker appConfig
+	if err := envconf.ReadConfigSource(&worker, src); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if worker != conf {
+		t.Errorf("Expected the worker to decode %+v, got %+v", conf, worker)
+		t.Fail()
+	}
+	if _, ok, _ := src.Lookup("OTHER"); ok {
+		t.Errorf("Expected only the config's variables to be published")
+		t.Fail()
+	}
+
+	// Updates are seen after Refresh.
+	pub.Update(map[string]string{"HOST": "db2.internal"})
+	if v, _, _ := src.Lookup("HOST"); v != "db.internal" {
+		t.Errorf("Expected the cached value before Refresh, got %q", v)
+		t.Fail()
+	}
+	src.Refresh()
+	if v, _, _ := src.Lookup("HOST"); v != "db2.internal" {
+		t.Errorf("Expected the new value after Refresh, got %q", v)
+		t.Fail()
+	}
+
+	// A restarted supervisor replaces the stale socket.
+	pub.Close()
+	pub, err = PublishValues(path, map[string]string{"HOST": "db3.internal"})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	src.Refresh()
+	if v, _, _ := src.Lookup("HOST"); v != "db3.internal" {
+		t.Errorf("Expected the new supervisor's value, got %q", v)
+		t.Fail()
+	}
+	pub.Close()
+
+	// Anything else at path is left alone.
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if _, err := PublishValues(path, nil); err == nil {
+		t.Errorf("Expected an error publishing over a file")
+		t.Fail()
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected only %s to be left, got %v", path, entries)
+		t.Fail()
+	}
+
+	src = New(filepath.Join(t.TempDir(), "missing.sock"))
+	err = envconf.ReadConfigSource(&worker, src)
+	match := "Reading config from"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,113 @@
+package envconf
+
+import (
+	"context"
+	"fmt"
+)
+
+// A Source looks up the raw values of config variables. ok reports whether
+// the variable is set; err reports a failure to look it up, such as a network
+// error from a remote store, as distinct from the variable being unset.
+//
+// A Source may also implement Name() string, naming it in reports and
+// errors, Prefetcher, to fetch variables in batches, Pinger, to check it is
+// reachable, and ContextSource, to honour cancellation and deadlines.
+type Source interface {
+	Lookup(key string) (value string, ok bool, err error)
+}
+
+// A ContextSource is a Source whose lookups can be cancelled or bounded by a
+// deadline, such as one backed by a network service. ReadConfigContext and
+// Decoder.DecodeContext call LookupContext in place of Lookup.
+type ContextSource interface {
+	Source
+	LookupContext(ctx context.Context, key string) (value string, ok bool, err error)
+}
+
+// lookupContext looks up key in src, passing ctx to it if it is a
+// ContextSource. Once ctx is done, other sources are not consulted and the
+// context's error is returned. A nil ctx is a plain Lookup. The lookup is
+// traced if ctx carries the Tracer of a decode.
+func lookupContext(ctx context.Context, src Source, key string) (v string, ok bool, err error) {
+	if ctx == nil {
+		return src.Lookup(key)
+	}
+	ctx, end := startSpan(ctx, "envconf.lookup", map[string]string{
+		TraceSource:   SourceName(src),
+		TraceVariable: key,
+	})
+	defer func() { end(err) }()
+
+	if cs, ok := src.(ContextSource); ok {
+		return cs.LookupContext(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	return src.Lookup(key)
+}
+
+// A Getter returns the raw value for a config variable, or the empty string
+// if it is not set. os.Getenv is a Getter.
+type Getter func(string) string
+
+// Lookup calls g(key). A Getter can't distinguish an empty value from an
+// unset one, so ok is false for the empty string.
+func (g Getter) Lookup(key string) (string, bool, error) {
+	v := g(key)
+	return v, len(v) > 0, nil
+}
+
+// A LookupFunc returns the raw value for a config variable, or the empty
+// string if it is not set. Unlike a Getter, it can report that the lookup
+// failed, so that a remote store which can't be reached isn't taken for one
+// in which the variable is unset.
+type LookupFunc func(key string) (string, error)
+
+// Lookup calls f(key). As with a Getter, ok is false for the empty string.
+func (f LookupFunc) Lookup(key string) (string, bool, error) {
+	v, err := f(key)
+	return v, len(v) > 0 && err == nil, err
+}
+
+// EnvSource is a Source backed by the environment of the operating system.
+// Under GOOS=wasip1 it serves the environment the host provides; under
+// GOOS=js, JSSource serves the properties of a JavaScript object instead.
+type EnvSource struct {
+	// Env is the environment to read. If nil, ProcessEnv is used.
+	Env OSEnv
+}
+
+// Lookup calls LookupEnv on the environment.
+func (s EnvSource) Lookup(key string) (string, bool, error) {
+	env := s.Env
+	if env == nil {
+		env = ProcessEnv
+	}
+	v, ok := env.LookupEnv(key)
+	return v, ok, nil
+}
+
+// Name returns "env".
+func (EnvSource) Name() string { return "env" }
+
+// MapSource is a Source backed by a map.
+type MapSource map[string]string
+
+// Lookup returns the value for key in the map.
+func (m MapSource) Lookup(key string) (string, bool, error) {
+	v, ok := m[key]
+	return v, ok, nil
+}
+
+// Name returns "map".
+func (MapSource) Name() string { return "map" }
+
+// SourceName returns the name of src, from its Name method if it has one or
+// otherwise from its type.
+func SourceName(src Source) string {
+	if n, ok := src.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", src)
+}
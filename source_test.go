@@ -0,0 +1,194 @@
+package envconf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// failingSource fails every lookup.
+type failingSource struct{}
+
+func (failingSource) Lookup(key string) (string, bool, error) {
+	return "", false, errors.New("connection refused")
+}
+
+func TestSources(t *testing.T) {
+	os.Setenv("ENVCONFTEST_SOURCE", "env")
+	defer os.Unsetenv("ENVCONFTEST_SOURCE")
+
+	tests := []struct {
+		src          Source
+		key          string
+		expect, name string
+		ok           bool
+	}{
+		{EnvSource{}, "ENVCONFTEST_SOURCE", "env", "env", true},
+		{EnvSource{}, "ENVCONFTEST_UNSET", "", "env", false},
+		{EnvSource{MapEnv{"A": ""}}, "A", "", "env", true},
+		{EnvSource{MapEnv{"A": ""}}, "ENVCONFTEST_SOURCE", "", "env", false},
+		{MapSource{"A": ""}, "A", "", "map", true},
+		{MapSource{"A": ""}, "B", "", "map", false},
+		{Getter(mapgetter{"A": "x"}.get), "A", "x", "envconf.Getter", true},
+		{Getter(mapgetter{"A": ""}.get), "A", "", "envconf.Getter", false},
+	}
+
+	for _, test := range tests {
+		v, ok, err := test.src.Lookup(test.key)
+		if err != nil || v != test.expect || ok != test.ok {
+			t.Errorf("%T.Lookup(%q): expected %q, %t; got %q, %t, %v", test.src, test.key, test.expect, test.ok, v, ok, err)
+			t.Fail()
+		}
+		if name := SourceName(test.src); name != test.name {
+			t.Errorf("SourceName(%T): expected %q, got %q", test.src, test.name, name)
+			t.Fail()
+		}
+	}
+}
+
+// ctxKey keys the value ctxSource expects on its context.
+type ctxKey struct{}
+
+// ctxSource serves the value stored in the context of each lookup, and
+// records the names of each batch.
+type ctxSource struct {
+	batches [][]string
+}
+
+func (s *ctxSource) Lookup(key string) (string, bool, error) {
+	return s.LookupContext(context.Background(), key)
+}
+
+func (s *ctxSource) LookupContext(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	v, ok := ctx.Value(ctxKey{}).(string)
+	return v, ok, nil
+}
+
+func (s *ctxSource) Prefetch(names []string) error {
+	return s.PrefetchContext(context.Background(), names)
+}
+
+func (s *ctxSource) PrefetchContext(ctx context.Context, names []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.batches = append(s.batches, names)
+	return nil
+}
+
+func TestReadConfigContext(t *testing.T) {
+	var conf struct {
+		Host string
+		Port int `default:"80"`
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "example.com")
+	src := &ctxSource{}
+	if err := ReadConfigContext(ctx, &conf, LayerSources(MapSource{"PORT": "8080"}, src)); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Host != "example.com" || conf.Port != 8080 {
+		t.Errorf("ReadConfigContext(): expected the context's value, got %+v", conf)
+		t.Fail()
+	}
+
+	var names struct{ Host, Domain string }
+	if err := ReadConfigContext(ctx, &names, src); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if len(src.batches) != 1 || strings.Join(src.batches[0], ",") != "HOST,DOMAIN" {
+		t.Errorf("ReadConfigContext(): expected one batch of HOST,DOMAIN, got %v", src.batches)
+		t.Fail()
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		src      Source
+		errmatch string
+	}{
+		{src, "Prefetch failed: context canceled"},
+		{MapSource{"HOST": "a"}, "Lookup of HOST failed: context canceled"},
+		{LayerSources(MapSource{}, src), "Lookup of HOST failed: context canceled"},
+	}
+	for _, test := range tests {
+		err := ReadConfigContext(cancelled, &conf, test.src)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestLookupFunc(t *testing.T) {
+	var conf struct {
+		Host  string
+		Port  int
+		Debug bool
+	}
+	fn := func(key string) (string, error) {
+		switch key {
+		case "HOST":
+			return "example.com", nil
+		case "PORT":
+			return "", errors.New("permission denied")
+		}
+		return "maybe", nil
+	}
+
+	var warnings []error
+	d := NewDecoder(WithLookupFunc(fn), WithErrorMode(CollectAllWithWarnings),
+		WithWarningFunc(func(err error) { warnings = append(warnings, err) }))
+	err := d.Decode(&conf)
+	var lerr *LookupError
+	if !errors.As(err, &lerr) || lerr.Name != "PORT" || lerr.Err.Error() != "permission denied" {
+		t.Errorf("Decode(): expected a LookupError for PORT, got '%v'", err)
+		t.Fail()
+	}
+	if match := "Lookup of PORT failed: permission denied"; err == nil || err.Error() != match {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+	// a parse failure of a field which isn't required is only a warning
+	if len(warnings) != 1 || errors.As(warnings[0], &lerr) || conf.Host != "example.com" {
+		t.Errorf("Decode(): expected one parse warning and Host set, got %v and %+v", warnings, conf)
+		t.Fail()
+	}
+}
+
+func TestReadConfigSource(t *testing.T) {
+	var conf struct {
+		Port int
+	}
+	if err := ReadConfigSource(&conf, MapSource{"PORT": "80"}); err != nil || conf.Port != 80 {
+		t.Errorf("ReadConfigSource(): expected Port 80, got %d, %v", conf.Port, err)
+		t.Fail()
+	}
+
+	match := "Lookup of PORT failed: connection refused"
+	if err := ReadConfigSource(&conf, failingSource{}); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfigSource(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	layers := LayerSources(MapSource{"HOST": "a"}, failingSource{})
+	var c2 struct{ Host, Port string }
+	if err := ReadConfigSource(&c2, layers); err == nil || !strings.Contains(err.Error(), "Lookup of PORT failed") {
+		t.Errorf("ReadConfigSource(): expected a lookup error through Layers, got '%v'", err)
+		t.Fail()
+	}
+	if name := layers.OriginName("HOST"); name != "map" {
+		t.Errorf("OriginName(): expected 'map', got %q", name)
+		t.Fail()
+	}
+	if name := layers.Name(); name != "layers(map, envconf.failingSource)" {
+		t.Errorf("Name(): got %q", name)
+		t.Fail()
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Package ssmsrc provides an envconf Source backed by AWS Systems Manager
+Parameter Store.
+
+Every parameter under a path is read in as few requests as the API allows,
+with SecureString parameters decrypted, and variables are mapped to
+parameters by lower-casing them and turning underscores into slashes, so that
+with the path /myapp/prod the variable DB_PASSWORD is read from the parameter
+/myapp/prod/db/password.
+
+The package does not depend on the AWS SDK. Instead it takes a Client, which
+an application adapts from the SDK it already uses; with aws-sdk-go-v2:
+
+	type ssmClient struct{ *ssm.Client }
+
+	func (c ssmClient) GetParametersByPath(ctx context.Context, path string, nextToken string) (map[string]string, string, error) {
+		in := &ssm.GetParametersByPathInput{
+			Path:           aws.String(path),
+			Recursive:      aws.Bool(true),
+			WithDecryption: aws.Bool(true),
+		}
+		if nextToken != "" {
+			in.NextToken = aws.String(nextToken)
+		}
+		out, err := c.Client.GetParametersByPath(ctx, in)
+		if err != nil {
+			return nil, "", err
+		}
+		params := make(map[string]string, len(out.Parameters))
+		for _, p := range out.Parameters {
+			params[aws.ToString(p.Name)] = aws.ToString(p.Value)
+		}
+		return params, aws.ToString(out.NextToken), nil
+	}
+
+and then:
+
+	src := ssmsrc.New(ssmClient{ssm.NewFromConfig(cfg)}, "/myapp/prod")
+	err := envconf.NewDecoder(
+		envconf.WithSource(envconf.LayerSources(envconf.EnvSource{}, src)),
+	).Decode(&conf)
+*/
+package ssmsrc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// A Client reads a page of the parameters under path, recursively and with
+// SecureString values decrypted. params maps each parameter's full name to
+// its value, and next is the token for the following page, or empty after
+// the last.
+type Client interface {
+	GetParametersByPath(ctx context.Context, path string, nextToken string) (params map[string]string, next string, err error)
+}
+
+// Source looks up variables in Parameter Store.
+type Source struct {
+	client Client
+	path   string
+	arn    string
+	nameOf func(name string) string
+
+	mu     sync.Mutex
+	params map[string]string
+}
+
+// An Option configures a Source.
+type Option func(*Source)
+
+// WithNameMapper sets how variable names are mapped to parameter names,
+// relative to the path.
+func WithNameMapper(fn func(name string) string) Option {
+	return func(s *Source) {
+		s.nameOf = fn
+	}
+}
+
+// WithARNPrefix makes Locate return parameter ARNs rather than names, such
+// as "arn:aws:ssm:eu-west-1:123456789012:parameter", for building IAM
+// policies with Decoder.Manifest.
+func WithARNPrefix(prefix string) Option {
+	return func(s *Source) {
+		s.arn = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// DefaultNameMapper lower-cases name and turns underscores into slashes, so
+// DB_PASSWORD becomes db/password.
+func DefaultNameMapper(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "/")
+}
+
+// New returns a Source reading the parameters under path.
+func New(client Client, path string, opts ...Option) *Source {
+	s := &Source{
+		client: client,
+		path:   "/" + strings.Trim(path, "/"),
+		nameOf: DefaultNameMapper,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Parameter returns the name of the parameter name is read from.
+func (s *Source) Parameter(name string) string {
+	return strings.TrimSuffix(s.path, "/") + "/" + s.nameOf(name)
+}
+
+// Lookup returns the value of the parameter for name. The parameters under
+// the path are all read on the first lookup, so that decoding a struct costs
+// one pass over the path rather than a request per field.
+func (s *Source) Lookup(name string) (string, bool, error) {
+	return s.LookupContext(context.Background(), name)
+}
+
+// LookupContext is like Lookup, reading the parameters with ctx.
+func (s *Source) LookupContext(ctx context.Context, name string) (string, bool, error) {
+	params, err := s.load(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := params[s.Parameter(name)]
+	return v, ok, nil
+}
+
+// Prefetch reads the parameters under the path, if they have not been read.
+func (s *Source) Prefetch(names []string) error {
+	return s.PrefetchContext(context.Background(), names)
+}
+
+// PrefetchContext is like Prefetch, reading the parameters with ctx.
+func (s *Source) PrefetchContext(ctx context.Context, names []string) error {
+	_, err := s.load(ctx)
+	return err
+}
+
+// Refresh discards the parameters read, so that they are read again on the
+// next lookup.
+func (s *Source) Refresh() {
+	s.mu.Lock()
+	s.params = nil
+	s.mu.Unlock()
+}
+
+// Locate returns the parameter, or its ARN, which name is read from.
+func (s *Source) Locate(name string) (string, bool) {
+	if len(s.arn) > 0 {
+		return s.arn + s.Parameter(name), true
+	}
+	return s.Parameter(name), true
+}
+
+// Ping checks that the path can be read.
+func (s *Source) Ping(ctx context.Context) error {
+	_, _, err := s.client.GetParametersByPath(ctx, s.path, "")
+	return err
+}
+
+// Name identifies the source in errors and reports.
+func (s *Source) Name() string {
+	return "ssm(" + s.path + ")"
+}
+
+// load returns the parameters under the path, reading every page of them if
+// they have not been read.
+func (s *Source) load(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.params != nil {
+		return s.params, nil
+	}
+
+	params := make(map[string]string)
+	next := ""
+	for {
+		page, token, err := s.client.GetParametersByPath(ctx, s.path, next)
+		if err != nil {
+			return nil, fmt.Errorf("Reading SSM parameters under %s failed: %v", s.path, err)
+		}
+		for k, v := range page {
+			params[k] = v
+		}
+		if len(token) == 0 {
+			break
+		}
+		next = token
+	}
+
+	s.params = params
+	return params, nil
+}
@@ -0,0 +1,126 @@
+package ssmsrc
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+// fakeSSM serves parameters in pages of two, as by GetParametersByPath.
+type fakeSSM struct {
+	params map[string]string
+	calls  int
+	err    error
+}
+
+func (f *fakeSSM) GetParametersByPath(ctx context.Context, path, nextToken string) (map[string]string, string, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, "", f.err
+	}
+
+	var names []string
+	for k := range f.params {
+		if strings.HasPrefix(k, path+"/") {
+			names = append(names, k)
+		}
+	}
+	start, _ := strconv.Atoi(nextToken)
+	end := start + 2
+	next := strconv.Itoa(end)
+	if end >= len(names) {
+		end, next = len(names), ""
+	}
+
+	page := make(map[string]string)
+	sort.Strings(names)
+	for _, k := range names[start:end] {
+		page[k] = f.params[k]
+	}
+	return page, next, nil
+}
+
+type appConfig struct {
+	Port int
+	DB   struct {
+		Host     string
+		Password string `required:"true"`
+	}
+	Region string
+}
+
+func TestSource(t *testing.T) {
+	fake := &fakeSSM{params: map[string]string{
+		"/myapp/prod/port":        "8080",
+		"/myapp/prod/db/host":     "db.internal",
+		"/myapp/prod/db/password": "hunter2",
+		"/myapp/prod/region":      "eu-west-1",
+		"/myapp/dev/port":         "1",
+	}}
+	src := New(fake, "/myapp/prod/")
+
+	var conf appConfig
+	if err := envconf.ReadConfigSource(&conf, envconf.LayerSources(src)); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 8080 || conf.DB.Host != "db.internal" || conf.DB.Password != "hunter2" || conf.Region != "eu-west-1" {
+		t.Errorf("Unexpected config %+v", conf)
+		t.Fail()
+	}
+	if fake.calls != 2 {
+		t.Errorf("Expected one pass over two pages, got %d calls", fake.calls)
+		t.Fail()
+	}
+
+	src.Refresh()
+	fake.params["/myapp/prod/port"] = "9090"
+	if err := envconf.ReadConfigSource(&conf, src); err != nil || conf.Port != 9090 {
+		t.Errorf("Expected new values after Refresh, got %+v, %v", conf, err)
+		t.Fail()
+	}
+
+	arns := New(fake, "/myapp/prod", WithARNPrefix("arn:aws:ssm:eu-west-1:123456789012:parameter"))
+	resources, _ := envconf.NewDecoder().Manifest(&conf, arns)
+	if resources[0] != "arn:aws:ssm:eu-west-1:123456789012:parameter/myapp/prod/db/host" || len(resources) != 4 {
+		t.Errorf("Manifest(): unexpected resources %v", resources)
+		t.Fail()
+	}
+
+	custom := New(fake, "/myapp/prod", WithNameMapper(func(name string) string {
+		return strings.ToLower(name)
+	}))
+	if p := custom.Parameter("DB_HOST"); p != "/myapp/prod/db_host" {
+		t.Errorf("Parameter(): got %q", p)
+		t.Fail()
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	fake := &fakeSSM{err: errors.New("AccessDeniedException")}
+	var conf appConfig
+	err := envconf.ReadConfigSource(&conf, New(fake, "/myapp/prod"))
+	match := "Prefetch failed: Reading SSM parameters under /myapp/prod failed: AccessDeniedException"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+	if err := New(fake, "/myapp/prod").Ping(context.Background()); err == nil {
+		t.Errorf("Ping(): expected an error")
+		t.Fail()
+	}
+
+	fake.err = nil
+	fake.params = map[string]string{}
+	err = envconf.ReadConfigSource(&conf, New(fake, "/myapp/prod"))
+	match = "Missing config fields: DB_PASSWORD"
+	if err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
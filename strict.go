@@ -0,0 +1,142 @@
+package envconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An UnknownMode decides how a Decoder handles variables in the environment
+// which have its prefix but which no field reads.
+type UnknownMode int
+
+const (
+	// AllowUnknown ignores unknown variables.
+	AllowUnknown UnknownMode = iota
+	// WarnUnknown passes each unknown variable to the warning func.
+	WarnUnknown
+	// RejectUnknown fails Decode if there are unknown variables.
+	RejectUnknown
+)
+
+// WithUnknown sets how Decode handles variables in the environment which
+// start with the Decoder's prefix but which no field of the config struct
+// reads, so that a typo such as MYAPP_PROT=8080 is caught rather than
+// silently ignored. The default is AllowUnknown.
+//
+// The environment is the one set by WithOSEnv, whatever the Decoder's Source.
+// Without a prefix there is no way to tell the program's variables from the
+// rest of the environment, so nothing is checked. Fields left out by
+// WithCommand still count as known, so that the subcommands of a program can
+// share an environment.
+func WithUnknown(mode UnknownMode) Option {
+	return func(d *Decoder) { d.unknown = mode }
+}
+
+// checkUnknown reports the variables in the environment with the Decoder's
+// prefix which no field of conf reads.
+func (d *Decoder) checkUnknown(conf interface{}) error {
+	prefix := d.Prefix()
+	if d.unknown == AllowUnknown || len(prefix) == 0 {
+		return nil
+	}
+
+	all := *d
+	all.command = ""
+	plan, err := all.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	var prefixes, names []string
+	for _, pf := range plan {
+		if pf.readsPrefix() {
+			prefixes = append(prefixes, pf.Name)
+			continue
+		}
+		for _, name := range pf.names() {
+			known[name] = true
+			names = append(names, name)
+			if len(d.fileSuffix) > 0 {
+				known[name+d.fileSuffix] = true
+			}
+		}
+	}
+
+	var unknown []string
+	for _, kv := range d.osenv.Environ() {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		if !strings.HasPrefix(name, prefix) || known[name] || hasAnyPrefix(name, prefixes) {
+			continue
+		}
+		if match := closest(name, names); len(match) > 0 {
+			name += fmt.Sprintf(" (did you mean %s?)", match)
+		}
+		unknown = append(unknown, name)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+
+	if d.unknown == WarnUnknown {
+		for _, name := range unknown {
+			d.warn(fmt.Errorf("Unknown config variable %s", name))
+		}
+		return nil
+	}
+	return fmt.Errorf(
+		"Unknown config variables: %s", strings.Join(unknown, ", "))
+}
+
+// hasAnyPrefix reports whether s starts with any of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// closest returns the name in names nearest to s, if one is within two
+// edits of it, as a likely intended spelling.
+func closest(s string, names []string) string {
+	best, bestDist := "", 3
+	for _, name := range names {
+		if d := editDistance(s, name); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b, counting a
+// transposition of adjacent characters as a single edit.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
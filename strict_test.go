@@ -0,0 +1,85 @@
+package envconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWithUnknown(t *testing.T) {
+	type strictConfig struct {
+		Port    int    `required:"true"`
+		Replica string `defaultFrom:"PRIMARY"`
+		Cache   cacheConfig
+		Steps   int `cmd:"migrate"`
+	}
+	replica := filepath.Join(t.TempDir(), "replica")
+	if err := os.WriteFile(replica, []byte("db2\n"), 0600); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	env := MapEnv{
+		"MYAPP_PORT":         "8080",
+		"MYAPP_PROT":         "8081",
+		"MYAPP_PRIMARY":      "db",
+		"MYAPP_REPLICA_FILE": replica,
+		"MYAPP_CACHE_SIZE":   "10",
+		"MYAPP_STEPS":        "3",
+		"MYAPP_LOGLEVEL":     "debug",
+		"HOME":               "/root",
+	}
+	opts := []Option{WithOSEnv(env), WithPrefix("MYAPP_"), WithFileSuffix("_FILE"), WithCommand("serve")}
+
+	var conf strictConfig
+	if err := NewDecoder(opts...).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+
+	errmatch := "Unknown config variables: MYAPP_LOGLEVEL, MYAPP_PROT (did you mean MYAPP_PORT?)"
+	err := NewDecoder(append(opts, WithUnknown(RejectUnknown))...).Decode(&conf)
+	if err == nil || err.Error() != errmatch {
+		t.Errorf("expected an error matching '%s', got '%v'", errmatch, err)
+		t.Fail()
+	}
+
+	var warnings []string
+	err = NewDecoder(append(opts, WithUnknown(WarnUnknown), WithWarningFunc(func(err error) {
+		warnings = append(warnings, err.Error())
+	}))...).Decode(&conf)
+	expect := []string{
+		"Unknown config variable MYAPP_LOGLEVEL",
+		"Unknown config variable MYAPP_PROT (did you mean MYAPP_PORT?)",
+	}
+	if err != nil || !reflect.DeepEqual(warnings, expect) {
+		t.Errorf("WarnUnknown: expected %v, got %v, %v", expect, warnings, err)
+		t.Fail()
+	}
+
+	// Without a prefix, the rest of the environment can't be told apart.
+	err = NewDecoder(WithOSEnv(MapEnv{"PORT": "1", "HOME": "/root"}), WithUnknown(RejectUnknown)).Decode(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect int
+	}{
+		{"PORT", "PORT", 0},
+		{"PROT", "PORT", 1},
+		{"PORT", "PORTS", 1},
+		{"HOST", "PORT", 2},
+		{"", "ABC", 3},
+	}
+	for _, test := range tests {
+		if d := editDistance(test.a, test.b); d != test.expect {
+			t.Errorf("editDistance(%q, %q): expected %d, got %d", test.a, test.b, test.expect, d)
+			t.Fail()
+		}
+	}
+}
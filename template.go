@@ -0,0 +1,59 @@
+package envconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteTemplate writes a commented .env file for conf to w, as a skeleton
+// for developers to fill in: each variable is preceded by its description
+// and type, defaults are filled in, except for those of secrets, and required
+// variables are marked REQUIRED and left empty. The prefix is applied as by
+// WithPrefix.
+func WriteTemplate(w io.Writer, conf interface{}, prefix string) error {
+	return NewDecoder(WithPrefix(prefix)).WriteTemplate(w, conf)
+}
+
+// WriteTemplate writes a .env file for the variables the Decoder reads for
+// conf; see the WriteTemplate function.
+func (d *Decoder) WriteTemplate(w io.Writer, conf interface{}) error {
+	s, err := d.Schema(conf)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, v := range s.Variables {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+
+		note := fmt.Sprintf("(%s)", v.Type)
+		if len(v.Description) > 0 {
+			note = v.Description + " " + note
+		}
+		switch {
+		case v.Required:
+			note = "REQUIRED: " + note
+		case len(v.RequiredIf) > 0:
+			note = fmt.Sprintf("REQUIRED if %s: %s", v.RequiredIf, note)
+		}
+		if len(v.DefaultFrom) > 0 {
+			note += ", defaults to $" + v.DefaultFrom
+		}
+		def := v.Default
+		if v.Secret && def == Redacted {
+			note += ", has a secret default"
+			def = ""
+		}
+		fmt.Fprintf(bw, "# %s\n", note)
+
+		if v.Prefix {
+			fmt.Fprintf(bw, "# %s*: variables read by %s\n", v.Name, v.Type)
+			continue
+		}
+		fmt.Fprintf(bw, "%s=%s\n", v.Name, quoteDotenv(def))
+	}
+	return bw.Flush()
+}
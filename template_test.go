@@ -0,0 +1,69 @@
+package envconf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	var conf struct {
+		Port     int           `required:"true" desc:"port to listen on"`
+		Timeout  time.Duration `default:"5s"`
+		Greeting string        `default:"it's a \"hello\""`
+		Replica  string        `defaultFrom:"PRIMARY"`
+		Cache    cacheConfig
+		TLS      bool
+		CertFile string `required_if:"TLS=true" default:"/etc/tls/cert #1.pem"`
+		Password string `secret:"true" default:"dev-password"`
+		Token    string `secret:"true" default:"demo" showdefault:"true"`
+	}
+	expect := `# REQUIRED: port to listen on (int)
+APP_PORT=
+
+# (time.Duration)
+APP_TIMEOUT=5s
+
+# (string)
+APP_GREETING="it's a \"hello\""
+
+# (string), defaults to $APP_PRIMARY
+APP_REPLICA=
+
+# (envconf.cacheConfig)
+# APP_CACHE_*: variables read by envconf.cacheConfig
+
+# (bool)
+APP_TLS=
+
+# REQUIRED if TLS=true: (string)
+APP_CERTFILE='/etc/tls/cert #1.pem'
+
+# (string), has a secret default
+APP_PASSWORD=
+
+# (string)
+APP_TOKEN=demo
+`
+
+	var buf bytes.Buffer
+	if err := WriteTemplate(&buf, &conf, "APP_"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if buf.String() != expect {
+		t.Errorf("WriteTemplate(): expected\n%s\ngot\n%s", expect, buf.String())
+		t.Fail()
+	}
+
+	// The template parses back to the defaults.
+	m, err := ParseDotenv(&buf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if m["APP_GREETING"] != `it's a "hello"` || m["APP_CERTFILE"] != "/etc/tls/cert #1.pem" || m["APP_TIMEOUT"] != "5s" {
+		t.Errorf("ParseDotenv(): unexpected %v", m)
+		t.Fail()
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Package tomlsrc provides an envconf Source backed by a TOML document.
+
+Tables and keys are flattened as by envconf.Flatten, so
+
+	port = 8080
+
+	[server]
+	hosts = ["a", "b"]
+
+serves PORT=8080 and SERVER_HOSTS=a,b, and each entry of an array of tables
+is numbered, as in UPSTREAMS_0_HOST. A TOML file can then sit beneath the
+environment in one layered lookup:
+
+	base, err := tomlsrc.File("config.toml")
+	if err != nil {
+		return err
+	}
+	err = envconf.ReadConfigSource(&conf,
+		envconf.LayerSources(envconf.EnvSource{}, base))
+
+The package lives apart from envconf so that only programs which read TOML
+depend on github.com/BurntSushi/toml.
+*/
+package tomlsrc
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ceralena/envconf"
+)
+
+// Parse returns a Source serving the values in the TOML document data.
+func Parse(data []byte) (envconf.MapSource, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return envconf.MapSource(envconf.Flatten(normalize(doc), envconf.DefaultNamer)), nil
+}
+
+// File is like Parse, but reads the document from the file at path.
+func File(path string) (envconf.MapSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return src, nil
+}
+
+// normalize converts the arrays of tables in a decoded document, which the
+// toml package returns as []map[string]interface{}, into the []interface{}
+// which Flatten walks.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, ev := range v {
+			v[k] = normalize(ev)
+		}
+		return v
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, ev := range v {
+			l[i] = normalize(ev)
+		}
+		return l
+	case []interface{}:
+		for i, ev := range v {
+			v[i] = normalize(ev)
+		}
+		return v
+	default:
+		return v
+	}
+}
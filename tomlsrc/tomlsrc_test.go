@@ -0,0 +1,100 @@
+package tomlsrc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ceralena/envconf"
+)
+
+const testTOML = `
+port = 8080
+ratio = 0.5
+debug = true
+timeout = "30s"
+started = 2024-05-01T10:00:00Z
+tags = ["a", "b"]
+
+[server]
+host = "localhost"
+
+[server.tls]
+certFile = "/etc/cert.pem"
+
+[[upstreams]]
+host = "a"
+port = 1
+
+[[upstreams]]
+host = "b"
+`
+
+func TestParse(t *testing.T) {
+	src, err := Parse([]byte(testTOML))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	expect := envconf.MapSource{
+		"PORT":                "8080",
+		"RATIO":               "0.5",
+		"DEBUG":               "true",
+		"TIMEOUT":             "30s",
+		"STARTED":             "2024-05-01T10:00:00Z",
+		"TAGS":                "a,b",
+		"SERVER_HOST":         "localhost",
+		"SERVER_TLS_CERTFILE": "/etc/cert.pem",
+		"UPSTREAMS_0_HOST":    "a",
+		"UPSTREAMS_0_PORT":    "1",
+		"UPSTREAMS_1_HOST":    "b",
+	}
+	if !reflect.DeepEqual(src, expect) {
+		t.Errorf("Parse(): expected %v, got %v", expect, src)
+		t.Fail()
+	}
+
+	if _, err := Parse([]byte("port = ")); err == nil {
+		t.Errorf("Parse(): expected an error for invalid TOML")
+		t.Fail()
+	}
+}
+
+func TestFileWithEnvOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(testTOML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := File(path)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	var conf struct {
+		Port    int
+		Timeout time.Duration
+		Server  struct{ Host string }
+	}
+	src := envconf.LayerSources(envconf.MapSource{"SERVER_HOST": "example.com"}, base)
+	if err := envconf.ReadConfigSource(&conf, src); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 8080 || conf.Timeout != 30*time.Second || conf.Server.Host != "example.com" {
+		t.Errorf("Expected the override and the TOML base, got %+v", conf)
+		t.Fail()
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.toml")
+	os.WriteFile(bad, []byte("port = "), 0600)
+	if _, err := File(bad); err == nil || !strings.HasPrefix(err.Error(), bad) {
+		t.Errorf("File(): expected an error naming the file, got %v", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,102 @@
+package envconf
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+)
+
+// A Tracer starts a span named name, as a child of any span in ctx, with
+// these attributes. It returns the context for the span's children and a
+// func which ends the span, recording err if it is not nil.
+//
+// A Tracer is a thin seam over a tracing library; with OpenTelemetry it
+// looks like:
+//
+//	tracer := otel.Tracer("envconf")
+//	envconf.WithTracer(func(ctx context.Context, name string, attrs map[string]string) (context.Context, func(error)) {
+//		var kvs []attribute.KeyValue
+//		for k, v := range attrs {
+//			kvs = append(kvs, attribute.String(k, v))
+//		}
+//		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(kvs...))
+//		return ctx, func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			span.End()
+//		}
+//	})
+type Tracer func(ctx context.Context, name string, attrs map[string]string) (context.Context, func(err error))
+
+// Attribute keys set on spans.
+const (
+	TraceType      = "envconf.type"
+	TraceSource    = "envconf.source"
+	TraceVariable  = "envconf.variable"
+	TraceVariables = "envconf.variables"
+)
+
+// WithTracer sets a Tracer which is given spans for profiling a slow start:
+//
+//   - envconf.decode, or envconf.decode_lazy for DecodeLazy, around each
+//     decode, with the config type and the name of the Decoder's source;
+//   - envconf.prefetch around each batch passed to a Prefetcher, with the
+//     source and the number of variables in the batch;
+//   - envconf.lookup around each lookup of a variable, with the variable and
+//     the source. Layers start a child span for each layer they consult, so
+//     that the time spent can be attributed to each source.
+//
+// With DecodeContext, the decode span is a child of the span in its context.
+func WithTracer(tracer Tracer) Option {
+	return func(d *Decoder) { d.tracer = tracer }
+}
+
+// tracerKey is the context key of the Tracer passed to sources by a decode.
+type tracerKey struct{}
+
+// traced starts the span around a decode of conf, returning a copy of the
+// Decoder whose context carries the span and the Tracer, and the func which
+// ends the span.
+func (d *Decoder) traced(conf interface{}, lazy bool) (*Decoder, func(error)) {
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	name := "envconf.decode"
+	if lazy {
+		name = "envconf.decode_lazy"
+	}
+	ctx, end := d.tracer(ctx, name, map[string]string{
+		TraceType:   reflect.TypeOf(conf).String(),
+		TraceSource: SourceName(d.source),
+	})
+	return d.withContext(context.WithValue(ctx, tracerKey{}, d.tracer)), end
+}
+
+// startSpan starts a span with the Tracer in ctx, if there is one. The
+// returned func ends the span, and is a no-op if there is none.
+func startSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, func(error)) {
+	if ctx == nil {
+		return ctx, func(error) {}
+	}
+	tracer, ok := ctx.Value(tracerKey{}).(Tracer)
+	if !ok {
+		return ctx, func(error) {}
+	}
+	return tracer(ctx, name, attrs)
+}
+
+// prefetchSpan starts the span around a batch of n variables passed to the
+// Decoder's Prefetcher.
+func (d *Decoder) prefetchSpan(n int) (context.Context, func(error)) {
+	name := reflect.TypeOf(d.prefetcher).String()
+	if src, ok := d.prefetcher.(Source); ok {
+		name = SourceName(src)
+	}
+	return startSpan(d.ctx, "envconf.prefetch", map[string]string{
+		TraceSource:    name,
+		TraceVariables: strconv.Itoa(n),
+	})
+}
@@ -0,0 +1,76 @@
+package envconf
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// spanKey keys the name of the current span in a recordingTracer's contexts.
+type spanKey struct{}
+
+// recordingTracer records each span as "parent>name attrs", with the error
+// it ended with.
+type recordingTracer struct {
+	spans []string
+}
+
+func (r *recordingTracer) start(ctx context.Context, name string, attrs map[string]string) (context.Context, func(error)) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	span := parent + ">" + name
+	for _, k := range []string{TraceType, TraceSource, TraceVariable, TraceVariables} {
+		if v, ok := attrs[k]; ok {
+			span += " " + v
+		}
+	}
+	return context.WithValue(ctx, spanKey{}, name), func(err error) {
+		if err != nil {
+			span += ": " + err.Error()
+		}
+		r.spans = append(r.spans, span)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var conf struct {
+		Host string
+		Port int
+	}
+	r := &recordingTracer{}
+	src := LayerSources(MapSource{"PORT": "80"}, &ctxSource{})
+	d := NewDecoder(WithSource(src), WithTracer(r.start))
+	ctx := context.WithValue(context.Background(), ctxKey{}, "example.com")
+	if err := d.withContext(ctx).Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	// each layer consulted has a span within that of the Layers
+	expect := []string{
+		"envconf.lookup>envconf.lookup map HOST",
+		"envconf.lookup>envconf.lookup *envconf.ctxSource HOST",
+		"envconf.decode>envconf.lookup layers(map, *envconf.ctxSource) HOST",
+		"envconf.lookup>envconf.lookup map PORT",
+		"envconf.decode>envconf.lookup layers(map, *envconf.ctxSource) PORT",
+		">envconf.decode *struct { Host string; Port int } layers(map, *envconf.ctxSource)",
+	}
+	if !reflect.DeepEqual(r.spans, expect) {
+		t.Errorf("Expected spans\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(r.spans, "\n"))
+		t.Fail()
+	}
+
+	r.spans = nil
+	d = NewDecoder(WithSource(&ctxSource{}), WithTracer(r.start))
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.withContext(cancelled).Decode(&conf)
+	expect = []string{
+		"envconf.decode>envconf.prefetch *envconf.ctxSource 2: context canceled",
+		">envconf.decode *struct { Host string; Port int } *envconf.ctxSource: Prefetch failed: context canceled",
+	}
+	if !reflect.DeepEqual(r.spans, expect) {
+		t.Errorf("Expected spans\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(r.spans, "\n"))
+		t.Fail()
+	}
+}
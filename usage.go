@@ -0,0 +1,72 @@
+package envconf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Usage describes the variables read for conf with the given prefix, as a
+// table of their names, types, defaults, whether they are required, and the
+// fields' "desc" tags. It is suitable for printing when decoding fails:
+//
+//	if err := envconf.ReadConfigEnv(&conf); err != nil {
+//		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, envconf.Usage(&conf, ""))
+//		os.Exit(2)
+//	}
+//
+// Usage returns the empty string if conf is not a struct or a pointer to a
+// struct.
+func Usage(conf interface{}, prefix string) string {
+	u, _ := NewDecoder(WithPrefix(prefix)).Usage(conf)
+	return u
+}
+
+// Usage describes the variables the Decoder reads for conf; see the Usage
+// function.
+func (d *Decoder) Usage(conf interface{}) (string, error) {
+	s, err := d.Schema(conf)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIABLE\tTYPE\tDEFAULT\tREQUIRED\tDESCRIPTION")
+	for _, v := range s.Variables {
+		name := v.Name
+		if v.Prefix {
+			name += "*"
+		}
+
+		def := v.Default
+		if len(v.DefaultFrom) > 0 {
+			def = "$" + v.DefaultFrom
+			if len(v.Default) > 0 {
+				def += " or " + v.Default
+			}
+		}
+
+		required := "no"
+		if v.Required {
+			required = "yes"
+		} else if len(v.RequiredIf) > 0 {
+			required = "if " + v.RequiredIf
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			name, v.Type, def, required, v.Description)
+	}
+	tw.Flush()
+
+	// trim the padding of the last column on rows without a description
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		out.WriteString(strings.TrimRight(line, " \n"))
+		if strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,35 @@
+package envconf
+
+import (
+	"testing"
+	"time"
+)
+
+type usageConfig struct {
+	Port     int           `required:"true" desc:"port to listen on"`
+	Timeout  time.Duration `default:"5s"`
+	Replica  string        `defaultFrom:"PRIMARY" default:"localhost"`
+	TLS      bool
+	CertFile string `required_if:"TLS=true" desc:"TLS certificate"`
+	Bucket   Lazy[string]
+}
+
+func TestUsage(t *testing.T) {
+	expect := `VARIABLE      TYPE           DEFAULT                    REQUIRED     DESCRIPTION
+APP_PORT      int                                       yes          port to listen on
+APP_TIMEOUT   time.Duration  5s                         no
+APP_REPLICA   string         $APP_PRIMARY or localhost  no
+APP_TLS       bool                                      no
+APP_CERTFILE  string                                    if TLS=true  TLS certificate
+APP_BUCKET    string                                    no
+`
+	if u := Usage(&usageConfig{}, "APP_"); u != expect {
+		t.Errorf("Usage(): expected\n%s\ngot\n%s", expect, u)
+		t.Fail()
+	}
+
+	if u := Usage(3, ""); u != "" {
+		t.Errorf("Usage(): expected nothing for a non-struct, got %q", u)
+		t.Fail()
+	}
+}
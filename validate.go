@@ -0,0 +1,262 @@
+package envconf
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Validator checks its own invariants. If a field's type implements
+// Validator (with a value or pointer receiver), Validate is called after a
+// value has been assigned to the field and any error is returned from Decode.
+type Validator interface {
+	Validate() error
+}
+
+// validateField calls Validate on fieldVal if its type implements Validator.
+func validateField(field reflect.StructField, fieldVal reflect.Value) error {
+	var val interface{}
+	if fieldVal.CanAddr() {
+		val = fieldVal.Addr().Interface()
+	} else {
+		val = fieldVal.Interface()
+	}
+
+	if v, ok := val.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf(
+				"Invalid value for config field %s: %v", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkInput validates a raw value against field's "oneof" and "pattern"
+// tags. For slices, it is called for each element.
+func checkInput(field reflect.StructField, input string) error {
+	if err := checkOneOf(field, input); err != nil {
+		return err
+	}
+	return checkPattern(field, input)
+}
+
+// checkOneOf checks input against the comma-separated choices in field's
+// "oneof" tag, if it has one. For slices, it is called for each element.
+func checkOneOf(field reflect.StructField, input string) error {
+	tag := field.Tag.Get("oneof")
+	if len(tag) == 0 {
+		return nil
+	}
+
+	choices := strings.Split(tag, ",")
+	for _, c := range choices {
+		if input == c {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"Invalid value for config field %s: %s (must be one of %s)",
+		field.Name, quoteInput(field, input), strings.Join(choices, ", "))
+}
+
+// quoteInput quotes input for an error about field, or replaces it with
+// Redacted if field is a secret, so that a mistyped secret is kept out of
+// logs.
+func quoteInput(field reflect.StructField, input string) string {
+	if isSecret(field) {
+		return Redacted
+	}
+	return strconv.Quote(input)
+}
+
+// patternCache maps "pattern" tags to their compiled regular expressions,
+// which are compiled when a struct is planned rather than for each value.
+var patternCache sync.Map
+
+// compilePattern returns the compiled regular expression of field's
+// "pattern" tag, anchored to match in full, or nil if it has none.
+func compilePattern(field reflect.StructField) (*regexp.Regexp, error) {
+	pattern := field.Tag.Get("pattern")
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Invalid pattern for config field %s: %v", field.Name, err)
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+// checkPatterns compiles the "pattern" tags of the fields in plan, including
+// those of the elements of Indexed fields, so that a malformed pattern is
+// reported whether or not its variable is set.
+func (d *Decoder) checkPatterns(plan []PlannedField) error {
+	for _, pf := range plan {
+		if _, err := compilePattern(pf.labelled()); err != nil {
+			return err
+		}
+		if pf.Indexed {
+			if err := d.checkPatterns(d.elementPlan(pf, 0)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkPattern checks that input matches the regular expression in field's
+// "pattern" tag in full, if it has one.
+func checkPattern(field reflect.StructField, input string) error {
+	re, err := compilePattern(field)
+	if re == nil || err != nil {
+		return err
+	}
+
+	if !re.MatchString(input) {
+		return fmt.Errorf(
+			"Invalid value for config field %s: %s (must match %s)",
+			field.Name, quoteInput(field, input), field.Tag.Get("pattern"))
+	}
+	return nil
+}
+
+// checkRange checks a converted value of type t against field's "min" and
+// "max" tags, if it has them. The bounds are inclusive. t is the field's type,
+// or for slices the element type. Values which can't be parsed are left for
+// setValue to report.
+func checkRange(field reflect.StructField, t reflect.Type, input string) error {
+	min, max := field.Tag.Get("min"), field.Tag.Get("max")
+	if len(min) == 0 && len(max) == 0 {
+		return nil
+	}
+
+	parse := func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	// the kinds setValue parses
+	switch kind := t.Kind(); {
+	case t == durationType:
+		parse = func(s string) (float64, error) {
+			d, err := time.ParseDuration(s)
+			return float64(d), err
+		}
+	case kind == reflect.Int || kind == reflect.Float32 || kind == reflect.Float64:
+	default:
+		return fmt.Errorf(
+			"Invalid range for config field %s: min and max apply to numbers and durations",
+			field.Name)
+	}
+
+	v, err := parse(input)
+	if err != nil {
+		return nil
+	}
+	for _, bound := range []struct {
+		tag, desc string
+		outside   func(v, b float64) bool
+	}{
+		{min, "at least", func(v, b float64) bool { return v < b }},
+		{max, "at most", func(v, b float64) bool { return v > b }},
+	} {
+		if len(bound.tag) == 0 {
+			continue
+		}
+		if b, err := parse(bound.tag); err != nil {
+			return fmt.Errorf(
+				"Invalid range for config field %s: %v", field.Name, err)
+		} else if bound.outside(v, b) {
+			return fmt.Errorf(
+				"Invalid value for config field %s: %s (must be %s %s)",
+				field.Name, quoteInput(field, input), bound.desc, bound.tag)
+		}
+	}
+	return nil
+}
+
+// checkStrength checks the value of a string or []byte field against its
+// "minlen" and "minentropy" tags or, for secret fields without them, the
+// Decoder's defaults from WithSecretStrength. The value is never included in
+// the error, as it is likely a secret.
+func (d *Decoder) checkStrength(field reflect.StructField, fieldVal reflect.Value) error {
+	var value []byte
+	isText := true
+	switch {
+	case fieldVal.Kind() == reflect.String:
+		value = []byte(fieldVal.String())
+	case fieldVal.Kind() == reflect.Slice && fieldVal.Type().Elem().Kind() == reflect.Uint8:
+		value = fieldVal.Bytes()
+	default:
+		isText = false
+	}
+
+	minLen, minEntropy := field.Tag.Get("minlen"), field.Tag.Get("minentropy")
+	// the Decoder's defaults only apply to secrets they can measure
+	if isSecret(field) && isText {
+		if len(minLen) == 0 && d.secretMinLen > 0 {
+			minLen = strconv.Itoa(d.secretMinLen)
+		}
+		if len(minEntropy) == 0 && d.secretMinEntropy > 0 {
+			minEntropy = strconv.FormatFloat(d.secretMinEntropy, 'g', -1, 64)
+		}
+	}
+	if len(minLen) == 0 && len(minEntropy) == 0 {
+		return nil
+	}
+	if !isText {
+		return fmt.Errorf(
+			"Invalid strength for config field %s: minlen and minentropy apply to strings and byte slices",
+			field.Name)
+	}
+
+	if len(minLen) > 0 {
+		n, err := strconv.Atoi(minLen)
+		if err != nil {
+			return fmt.Errorf(
+				"Invalid strength for config field %s: %v", field.Name, err)
+		} else if len(value) < n {
+			return fmt.Errorf(
+				"Invalid value for config field %s: too short (must be at least %d bytes)",
+				field.Name, n)
+		}
+	}
+	if len(minEntropy) > 0 {
+		bits, err := strconv.ParseFloat(minEntropy, 64)
+		if err != nil {
+			return fmt.Errorf(
+				"Invalid strength for config field %s: %v", field.Name, err)
+		} else if e := entropy(value); e < bits {
+			return fmt.Errorf(
+				"Invalid value for config field %s: too predictable (about %.0f bits of entropy, must be at least %s)",
+				field.Name, e, minEntropy)
+		}
+	}
+	return nil
+}
+
+// entropy estimates the bits of entropy in b from the frequency of its
+// bytes. It is a rough measure, but tells random keys apart from words and
+// placeholders such as "changeme".
+func entropy(b []byte) float64 {
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+	var perByte float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(b))
+			perByte -= p * math.Log2(p)
+		}
+	}
+	return perByte * float64(len(b))
+}
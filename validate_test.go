@@ -0,0 +1,316 @@
+package envconf
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOneOf(t *testing.T) {
+	type MyConf struct {
+		LogLevel string   `oneof:"debug,info,warn,error" default:"info"`
+		Outputs  []string `oneof:"stdout,file"`
+		Workers  int      `oneof:"1,2,4"`
+	}
+	tests := []struct {
+		vals     mapgetter
+		valid    bool
+		errmatch string
+	}{
+		{mapgetter{}, true, ""},
+		{mapgetter{"LOGLEVEL": "warn", "OUTPUTS": "stdout,file", "WORKERS": "4"}, true, ""},
+		{mapgetter{"LOGLEVEL": "trace"}, false, `config field LogLevel (LOGLEVEL): "trace" (must be one of debug, info, warn, error)`},
+		{mapgetter{"LOGLEVEL": "INFO"}, false, `config field LogLevel (LOGLEVEL): "INFO"`},
+		{mapgetter{"OUTPUTS": "stdout,syslog"}, false, `config field Outputs (OUTPUTS): "syslog" (must be one of stdout, file)`},
+		{mapgetter{"WORKERS": "3"}, false, `config field Workers (WORKERS): "3"`},
+	}
+
+	for _, test := range tests {
+		c := MyConf{}
+		err := ReadConfig(&c, test.vals.get)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	type MyConf struct {
+		Bucket   string   `pattern:"[a-z0-9][a-z0-9.-]{2,62}"`
+		Versions []string `pattern:"v[0-9]+\\.[0-9]+"`
+	}
+	tests := []struct {
+		vals     mapgetter
+		valid    bool
+		errmatch string
+	}{
+		{mapgetter{}, true, ""},
+		{mapgetter{"BUCKET": "my-bucket.logs", "VERSIONS": "v1.2,v10.0"}, true, ""},
+		{mapgetter{"BUCKET": "My_Bucket"}, false, `config field Bucket (BUCKET): "My_Bucket" (must match [a-z0-9][a-z0-9.-]{2,62})`},
+		{mapgetter{"BUCKET": "ok-bucket!"}, false, `config field Bucket (BUCKET): "ok-bucket!"`},
+		{mapgetter{"VERSIONS": "v1.2,1.3"}, false, `config field Versions (VERSIONS): "1.3"`},
+	}
+
+	for _, test := range tests {
+		c := MyConf{}
+		err := ReadConfig(&c, test.vals.get)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+	}
+
+	var bad struct {
+		ID string `pattern:"[a-z"`
+	}
+	match := "Invalid pattern for config field ID (ID): error parsing regexp"
+	if err := ReadConfig(&bad, mapgetter{"ID": "x"}.get); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+	if err := ReadConfig(&bad, mapgetter{}.get); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig() with ID unset: expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+	if _, err := NewDecoder().Plan(&bad); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Plan(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	var badElem struct {
+		Upstreams []struct {
+			Host string `pattern:"(x"`
+		}
+	}
+	match = "Invalid pattern for config field Upstreams.0.Host (UPSTREAMS_0_HOST)"
+	if _, err := NewDecoder().Plan(&badElem); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Plan(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestValidateSecret(t *testing.T) {
+	type MyConf struct {
+		Region string `secret:"true" oneof:"eu,us"`
+		APIKey string `secret:"true" pattern:"sk_[a-z0-9]+"`
+		PIN    int    `secret:"true" min:"1000" max:"9999"`
+	}
+	tests := []struct {
+		vals     mapgetter
+		errmatch string
+	}{
+		{mapgetter{"REGION": "mars"}, "config field Region (REGION): <redacted> (must be one of eu, us)"},
+		{mapgetter{"APIKEY": "pk_live_123"}, "config field APIKey (APIKEY): <redacted> (must match sk_[a-z0-9]+)"},
+		{mapgetter{"PIN": "12345"}, "config field PIN (PIN): <redacted> (must be at most 9999)"},
+	}
+
+	for _, test := range tests {
+		c := MyConf{}
+		err := ReadConfig(&c, test.vals.get)
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("ReadConfig(%v): expected an error matching '%s', got '%v'", test.vals, test.errmatch, err)
+			t.Fail()
+		}
+		for _, v := range test.vals {
+			if err != nil && strings.Contains(err.Error(), v) {
+				t.Errorf("ReadConfig(%v): expected the value to be left out of '%v'", test.vals, err)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	type MyConf struct {
+		Workers int           `min:"1" max:"64"`
+		Ratio   float64       `max:"1"`
+		Timeout time.Duration `min:"100ms" max:"1m"`
+		Delay   time.Duration `convert:"ms->duration" max:"10s"`
+		Shards  []int         `min:"1"`
+	}
+	tests := []struct {
+		vals     mapgetter
+		valid    bool
+		errmatch string
+	}{
+		{mapgetter{}, true, ""},
+		{mapgetter{"WORKERS": "64", "RATIO": "0.5", "TIMEOUT": "100ms", "DELAY": "5000", "SHARDS": "1,2"}, true, ""},
+		{mapgetter{"WORKERS": "0"}, false, `config field Workers (WORKERS): "0" (must be at least 1)`},
+		{mapgetter{"RATIO": "1.5"}, false, `config field Ratio (RATIO): "1.5" (must be at most 1)`},
+		{mapgetter{"TIMEOUT": "2m"}, false, `config field Timeout (TIMEOUT): "2m" (must be at most 1m)`},
+		{mapgetter{"DELAY": "20000"}, false, `config field Delay (DELAY): "20000ms" (must be at most 10s)`},
+		{mapgetter{"SHARDS": "1,0"}, false, `config field Shards (SHARDS): "0" (must be at least 1)`},
+		{mapgetter{"WORKERS": "x"}, false, `strconv.ParseInt: parsing "x"`},
+	}
+
+	for _, test := range tests {
+		c := MyConf{}
+		err := ReadConfig(&c, test.vals.get)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+	}
+
+	var bad struct {
+		Name string `min:"1"`
+	}
+	match := "Invalid range for config field Name (NAME): min and max apply to numbers and durations"
+	if err := ReadConfig(&bad, mapgetter{"NAME": "x"}.get); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	var small struct {
+		Level int8 `min:"1"`
+	}
+	match = "Invalid range for config field Level (LEVEL): min and max apply to numbers and durations"
+	if err := ReadConfig(&small, mapgetter{"LEVEL": "2"}.get); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+func TestStrength(t *testing.T) {
+	type MyConf struct {
+		SigningKey string `secret:"true" minlen:"32"`
+		Password   string `secret:"true"`
+		APIToken   string `secret:"true" minentropy:"64"`
+		Name       string
+	}
+	const key = "9f86d081884c7d659a2feaa0c55ad015"
+	tests := []struct {
+		vals     MapSource
+		valid    bool
+		errmatch string
+	}{
+		{MapSource{}, true, ""},
+		{MapSource{"SIGNINGKEY": key, "PASSWORD": "correct horse battery staple", "APITOKEN": key, "NAME": "x"}, true, ""},
+		{MapSource{"SIGNINGKEY": "changeme"}, false, "config field SigningKey (SIGNINGKEY): too short (must be at least 32 bytes)"},
+		{MapSource{"PASSWORD": "changemechangeme"}, false, "config field Password (PASSWORD): too predictable (about 44 bits of entropy, must be at least 60)"},
+		{MapSource{"PASSWORD": "short"}, false, "config field Password (PASSWORD): too short (must be at least 12 bytes)"},
+		{MapSource{"APITOKEN": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}, false, "config field APIToken (APITOKEN): too predictable (about 0 bits"},
+	}
+
+	d := NewDecoder(WithSecretStrength(12, 60))
+	for _, test := range tests {
+		c := MyConf{}
+		err := d.With(WithSource(test.vals)).Decode(&c)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+		if err != nil && strings.Contains(err.Error(), "changeme") {
+			t.Errorf("Expected the secret to be left out of the error, got '%v'", err)
+			t.Fail()
+		}
+	}
+
+	// the defaults apply to named byte slices, and not to secrets they
+	// can't measure
+	_, pemKey := testCertificate(t)
+	var other struct {
+		PIN      int      `secret:"true"`
+		Endpoint *url.URL `secret:"true"`
+		Key      PEM      `secret:"true"`
+	}
+	src := MapSource{"PIN": "1234", "ENDPOINT": "https://user:pw@example.com", "KEY": pemKey}
+	if err := d.With(WithSource(src)).Decode(&other); err != nil || other.PIN != 1234 || len(other.Key) == 0 {
+		t.Errorf("Decode(): unexpected %+v, %v", other, err)
+		t.Fail()
+	}
+	var short struct {
+		Key PEM `minlen:"4096"`
+	}
+	err := d.With(WithSource(MapSource{"KEY": pemKey})).Decode(&short)
+	if match := "config field Key (KEY): too short"; err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Decode(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	var bad struct {
+		Port int `minlen:"1"`
+	}
+	match := "Invalid strength for config field Port (PORT): minlen and minentropy apply to strings and byte slices"
+	if err := ReadConfig(&bad, mapgetter{"PORT": "1"}.get); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("ReadConfig(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+// port is a domain type carrying its own invariants.
+type port int
+
+func (p port) Validate() error {
+	if p < 1 || p > 65535 {
+		return fmt.Errorf("port %d out of range", p)
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	type MyConf struct {
+		Port    port
+		Metrics port
+		Debug   bool
+	}
+	tests := []struct {
+		vals     mapgetter
+		valid    bool
+		errmatch string
+	}{
+		{mapgetter{}, true, ""},
+		{mapgetter{"PORT": "8080"}, true, ""},
+		{mapgetter{"PORT": "70000"}, false, "Invalid value for config field Port (PORT): port 70000 out of range"},
+	}
+
+	for _, test := range tests {
+		c := MyConf{}
+		err := ReadConfig(&c, test.vals.get)
+		if err != nil && test.valid {
+			t.Errorf("Unexpected error with '%v': %v", test.vals, err)
+			t.Fail()
+		} else if err == nil && !test.valid {
+			t.Errorf("Expected an error with: %v", test.vals)
+			t.Fail()
+		} else if err != nil && !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("Error strings did not match for err '%v': looking for '%s'", err, test.errmatch)
+			t.Fail()
+		}
+	}
+
+	c := MyConf{}
+	input := mapgetter{"PORT": "0", "METRICS": "99999"}
+	err := NewDecoder(WithGetter(input.get), WithErrorMode(CollectAll)).Decode(&c)
+	if errs, ok := err.(Errors); !ok || len(errs) != 2 {
+		t.Errorf("CollectAll: expected 2 Errors, got '%v'", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Package vaultsrc provides an envconf Source backed by secrets in a HashiCorp
+Vault KV version 2 secrets engine.
+
+By default every variable is read from one secret, as the key named by the
+lower-cased variable name, so that DB_PASSWORD is read from the key
+db_password of the secret myapp:
+
+	vault := vaultsrc.New("secret", "myapp")
+	err := envconf.NewDecoder(
+		envconf.WithSource(envconf.LayerSources(envconf.EnvSource{}, vault)),
+	).Decode(&conf)
+
+A field can name its own secret and key with the "secretpath" tag, of the
+form path#key, once the tags are read with UseTags:
+
+	var conf struct {
+		Port       int
+		DBPassword string `secretpath:"shared/postgres#password"`
+	}
+	d := envconf.NewDecoder(envconf.WithSource(
+		envconf.LayerSources(envconf.EnvSource{}, vault)))
+	if err := vault.UseTags(d, &conf); err != nil {
+		return err
+	}
+	err := d.Decode(&conf)
+
+Each secret is read once and cached until Refresh is called.
+
+The source talks to Vault's HTTP API directly, so using it adds no
+dependencies. The server address, token and namespace are taken from the
+VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE variables, as by the vault
+command, unless set with options.
+*/
+package vaultsrc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ceralena/envconf"
+)
+
+// DefaultAddr is the server address used when none is configured.
+const DefaultAddr = "https://127.0.0.1:8200"
+
+// Source looks up variables in Vault KV v2 secrets.
+type Source struct {
+	addr      string
+	token     string
+	namespace string
+	mount     string
+	path      string
+	client    *http.Client
+	keyFor    func(name string) (path, key string)
+
+	mu      sync.Mutex
+	tagged  map[string]location
+	secrets map[string]map[string]string
+}
+
+// location is the secret and key a variable is read from.
+type location struct {
+	path, key string
+}
+
+// An Option configures a Source.
+type Option func(*Source)
+
+// WithAddr sets the address of the Vault server.
+func WithAddr(addr string) Option {
+	return func(s *Source) {
+		s.addr = addr
+	}
+}
+
+// WithToken sets the token sent with each request.
+func WithToken(token string) Option {
+	return func(s *Source) {
+		s.token = token
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace of each request.
+func WithNamespace(ns string) Option {
+	return func(s *Source) {
+		s.namespace = ns
+	}
+}
+
+// WithHTTPClient sets the client used for requests. The default is
+// http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Source) {
+		s.client = c
+	}
+}
+
+// WithKeyMapper sets how variables without a "secretpath" tag are mapped to
+// a secret path, relative to the mount, and a key within it. It replaces the
+// default of the lower-cased name within the Source's own path.
+func WithKeyMapper(fn func(name string) (path, key string)) Option {
+	return func(s *Source) {
+		s.keyFor = fn
+	}
+}
+
+// New returns a Source reading secrets from the KV v2 engine mounted at
+// mount, with variables read from the secret at path unless mapped
+// otherwise.
+func New(mount, path string, opts ...Option) *Source {
+	s := &Source{
+		addr:      os.Getenv("VAULT_ADDR"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		mount:     strings.Trim(mount, "/"),
+		path:      strings.Trim(path, "/"),
+		client:    http.DefaultClient,
+		tagged:    make(map[string]location),
+		secrets:   make(map[string]map[string]string),
+	}
+	s.keyFor = func(name string) (string, string) {
+		return s.path, strings.ToLower(name)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.addr) == 0 {
+		s.addr = DefaultAddr
+	}
+	s.addr = strings.TrimSuffix(s.addr, "/")
+	return s
+}
+
+// UseTags reads the "secretpath" tags of conf's fields, as named by d, so
+// that each tagged field's variable is read from the secret and key its tag
+// names.
+func (s *Source) UseTags(d *envconf.Decoder, conf interface{}) error {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pf := range plan {
+		tag := pf.Field.Tag.Get("secretpath")
+		if len(tag) == 0 {
+			continue
+		}
+		i := strings.LastIndex(tag, "#")
+		if i <= 0 || i == len(tag)-1 {
+			return fmt.Errorf(
+				"Invalid secretpath for config field %s: %q (expected path#key)",
+				pf.Field.Name, tag)
+		}
+		s.tagged[pf.Name] = location{strings.Trim(tag[:i], "/"), tag[i+1:]}
+	}
+	return nil
+}
+
+// locate returns the secret and key name is read from.
+func (s *Source) locate(name string) location {
+	s.mu.Lock()
+	loc, ok := s.tagged[name]
+	s.mu.Unlock()
+	if ok {
+		return loc
+	}
+	path, key := s.keyFor(name)
+	return location{path, key}
+}
+
+// Locate returns the API path of the secret name is read from, so that
+// Decoder.Manifest can list the secrets a config reads for a Vault policy.
+func (s *Source) Locate(name string) (string, bool) {
+	return s.mount + "/data/" + s.locate(name).path, true
+}
+
+// Lookup reads name from its secret. A missing secret or key is an unset
+// variable.
+func (s *Source) Lookup(name string) (string, bool, error) {
+	return s.LookupContext(context.Background(), name)
+}
+
+// LookupContext is like Lookup, making its request with ctx.
+func (s *Source) LookupContext(ctx context.Context, name string) (string, bool, error) {
+	loc := s.locate(name)
+	secret, err := s.secret(ctx, loc.path)
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := secret[loc.key]
+	return v, ok, nil
+}
+
+// Prefetch reads the secrets of each of names.
+func (s *Source) Prefetch(names []string) error {
+	return s.PrefetchContext(context.Background(), names)
+}
+
+// PrefetchContext is like Prefetch, making its requests with ctx.
+func (s *Source) PrefetchContext(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if _, err := s.secret(ctx, s.locate(name).path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh discards the cached secrets, so that they are read again on their
+// next lookup.
+func (s *Source) Refresh() {
+	s.mu.Lock()
+	s.secrets = make(map[string]map[string]string)
+	s.mu.Unlock()
+}
+
+// Ping checks that the server is reachable, initialized and unsealed.
+func (s *Source) Ping(ctx context.Context) error {
+	resp, err := s.get(ctx, "/v1/sys/health?standbyok=true&perfstandbyok=true")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case 501:
+		return fmt.Errorf("vault is not initialized")
+	case 503:
+		return fmt.Errorf("vault is sealed")
+	default:
+		return fmt.Errorf("vault returned %s", resp.Status)
+	}
+}
+
+// Name identifies the source in errors and reports.
+func (s *Source) Name() string {
+	return "vault(" + s.mount + "/" + s.path + ")"
+}
+
+// secret returns the data of the secret at path, reading it if it is not
+// cached.
+func (s *Source) secret(ctx context.Context, path string) (map[string]string, error) {
+	s.mu.Lock()
+	secret, ok := s.secrets[path]
+	s.mu.Unlock()
+	if ok {
+		return secret, nil
+	}
+
+	resp, err := s.get(ctx, "/v1/"+s.mount+"/data/"+path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		secret = map[string]string{}
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("vault returned %s for %s/%s: %s",
+			resp.Status, s.mount, path, strings.TrimSpace(string(msg)))
+	}
+
+	if secret == nil {
+		var body struct {
+			Data struct {
+				Data map[string]json.RawMessage `json:"data"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("Invalid response from vault for %s/%s: %v", s.mount, path, err)
+		}
+		secret = make(map[string]string, len(body.Data.Data))
+		for k, raw := range body.Data.Data {
+			secret[k] = valueString(raw)
+		}
+	}
+
+	s.mu.Lock()
+	s.secrets[path] = secret
+	s.mu.Unlock()
+	return secret, nil
+}
+
+// valueString returns a secret value as a string. Strings are unquoted;
+// numbers, booleans and structures are kept as JSON.
+func valueString(raw json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// get sends a GET request for path to the server.
+func (s *Source) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.token) > 0 {
+		req.Header.Set("X-Vault-Token", s.token)
+	}
+	if len(s.namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+	return s.client.Do(req)
+}
@@ -0,0 +1,148 @@
+package vaultsrc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+// fakeVault serves KV v2 secrets over a minimal copy of Vault's HTTP API.
+type fakeVault struct {
+	secrets map[string]string
+	token   string
+	sealed  bool
+	reads   int
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/sys/health" {
+		if f.sealed {
+			w.WriteHeader(503)
+		}
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != f.token {
+		http.Error(w, `{"errors": ["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	f.reads++
+	if data, ok := f.secrets[r.URL.Path]; ok {
+		fmt.Fprintf(w, `{"data": {"data": %s, "metadata": {"version": 3}}}`, data)
+	} else {
+		http.Error(w, `{"errors": []}`, http.StatusNotFound)
+	}
+}
+
+type appConfig struct {
+	Port       int
+	DBPassword string `secretpath:"shared/postgres#password" required:"true"`
+	APIKey     string `required:"true"`
+	Debug      bool
+}
+
+func newVault() *fakeVault {
+	return &fakeVault{
+		secrets: map[string]string{
+			"/v1/secret/data/myapp":           `{"apikey": "k-123", "debug": true, "port": 8080}`,
+			"/v1/secret/data/shared/postgres": `{"password": "hunter2"}`,
+		},
+		token: "s.token",
+	}
+}
+
+func TestSource(t *testing.T) {
+	fake := newVault()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	vault := New("secret", "myapp", WithAddr(srv.URL), WithToken("s.token"))
+	d := envconf.NewDecoder(envconf.WithSource(
+		envconf.LayerSources(envconf.MapSource{"PORT": "9090"}, vault)))
+	if err := vault.UseTags(d, &appConfig{}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	var conf appConfig
+	if err := d.Decode(&conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	expect := appConfig{9090, "hunter2", "k-123", true}
+	if conf != expect {
+		t.Errorf("Expected %+v, got %+v", expect, conf)
+		t.Fail()
+	}
+	if fake.reads != 2 {
+		t.Errorf("Expected each secret to be read once, got %d reads", fake.reads)
+		t.Fail()
+	}
+
+	resources, err := d.Manifest(&conf, vault)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if expect := []string{"secret/data/myapp", "secret/data/shared/postgres"}; !reflect.DeepEqual(resources, expect) {
+		t.Errorf("Manifest(): expected %v, got %v", expect, resources)
+		t.Fail()
+	}
+
+	if err := vault.Ping(context.Background()); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.Fail()
+	}
+	fake.sealed = true
+	if err := vault.Ping(context.Background()); err == nil || err.Error() != "vault is sealed" {
+		t.Errorf("Ping(): expected a sealed error, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	srv := httptest.NewServer(newVault())
+	defer srv.Close()
+
+	tests := []struct {
+		vault    *Source
+		errmatch string
+	}{
+		{New("secret", "myapp", WithAddr(srv.URL)), "vault returned 403 Forbidden for secret/myapp"},
+		{New("secret", "missing", WithAddr(srv.URL), WithToken("s.token")), "Missing config fields: DBPASSWORD, APIKEY"},
+	}
+	for _, test := range tests {
+		var conf appConfig
+		err := envconf.ReadConfigSource(&conf, envconf.LayerSources(test.vault))
+		if err == nil || !strings.Contains(err.Error(), test.errmatch) {
+			t.Errorf("expected an error matching '%s', got '%v'", test.errmatch, err)
+			t.Fail()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var conf appConfig
+	vault := New("secret", "myapp", WithAddr(srv.URL), WithToken("s.token"))
+	err := envconf.ReadConfigContext(ctx, &conf, vault)
+	if match := "context canceled"; err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	var bad struct {
+		Token string `secretpath:"shared/token"`
+	}
+	vault = New("secret", "myapp", WithAddr(srv.URL))
+	err = vault.UseTags(envconf.NewDecoder(), &bad)
+	match := `Invalid secretpath for config field Token: "shared/token" (expected path#key)`
+	if err == nil || err.Error() != match {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
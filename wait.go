@@ -0,0 +1,100 @@
+package envconf
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A Pinger is a Source which can check that it is reachable, without looking
+// up any variable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// pingKey is looked up to probe sources which are not Pingers. Only a lookup
+// error counts; the variable needn't be set.
+const pingKey = "ENVCONF_PING"
+
+// The delays between attempts in WaitForSources.
+const (
+	waitInitial = 100 * time.Millisecond
+	waitMax     = 10 * time.Second
+)
+
+// WaitForSources blocks until every source is reachable, retrying with
+// exponential backoff, or until ctx is done. It lets a service in a fresh
+// environment wait for its remote config, such as a Vault server being
+// unsealed, instead of failing and being restarted over and over.
+//
+// A source is reachable when its Ping method, if it is a Pinger, or otherwise
+// a lookup of a variable, succeeds. Layers are reachable when all of their
+// sources are.
+//
+// If ctx is done first, the error names the sources which were still
+// unreachable.
+func WaitForSources(ctx context.Context, sources ...Source) error {
+	delay := waitInitial
+	for {
+		var errs Errors
+		for _, src := range sources {
+			if err := ping(ctx, src); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", SourceName(src), err))
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Sources unavailable (%v): %v", ctx.Err(), errs)
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > waitMax {
+			delay = waitMax
+		}
+	}
+}
+
+// ping checks that src is reachable.
+func ping(ctx context.Context, src Source) error {
+	if p, ok := src.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	_, _, err := src.Lookup(pingKey)
+	return err
+}
+
+// Ping checks that every layer is reachable.
+func (l *Layers) Ping(ctx context.Context) error {
+	for _, src := range l.sources {
+		if err := ping(ctx, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping always succeeds, since a LastKnownGood can fall back to its cache
+// file.
+func (c *LastKnownGood) Ping(ctx context.Context) error {
+	return nil
+}
+
+// DecodeContext waits for the Decoder's source to be reachable, as by
+// WaitForSources, and then decodes conf, passing ctx to the source if it is a
+// ContextSource or ContextPrefetcher.
+func (d *Decoder) DecodeContext(ctx context.Context, conf interface{}) error {
+	if err := WaitForSources(ctx, d.source); err != nil {
+		return err
+	}
+	return d.withContext(ctx).Decode(conf)
+}
+
+// withContext returns a copy of the Decoder which passes ctx to its source.
+func (d *Decoder) withContext(ctx context.Context) *Decoder {
+	cd := *d
+	cd.ctx = ctx
+	return &cd
+}
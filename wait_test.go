@@ -0,0 +1,57 @@
+package envconf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unsealingSource fails to ping until it has been pinged n times.
+type unsealingSource struct {
+	MapSource
+	n     int32
+	pings int32
+}
+
+func (s *unsealingSource) Ping(ctx context.Context) error {
+	if atomic.AddInt32(&s.pings, 1) <= s.n {
+		return errors.New("vault is sealed")
+	}
+	return nil
+}
+
+func (s *unsealingSource) Name() string { return "vault" }
+
+func TestWaitForSources(t *testing.T) {
+	vault := &unsealingSource{MapSource: MapSource{"TOKEN": "t"}, n: 2}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var conf struct{ Token string }
+	d := NewDecoder(WithSource(LayerSources(EnvSource{}, vault)))
+	if err := d.DecodeContext(ctx, &conf); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if vault.pings != 3 || conf.Token != "t" {
+		t.Errorf("Expected to decode after 3 pings, got %d pings and %+v", vault.pings, conf)
+		t.Fail()
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	err := WaitForSources(ctx, MapSource{}, failingSource{}, &unsealingSource{n: 100})
+	for _, match := range []string{"context deadline exceeded", "envconf.failingSource: connection refused", "vault: vault is sealed"} {
+		if err == nil || !strings.Contains(err.Error(), match) {
+			t.Errorf("expected an error matching '%s', got '%v'", match, err)
+			t.Fail()
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "map") {
+		t.Errorf("Expected reachable sources to be left out of %v", err)
+		t.Fail()
+	}
+}
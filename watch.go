@@ -0,0 +1,276 @@
+package envconf
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Refresher is a Source which caches what it reads, such as a remote
+// store, and can discard its cache so that the next lookups read afresh.
+// Watch refreshes its source before each reload.
+type Refresher interface {
+	Refresh()
+}
+
+// A Change is delivered by a Watcher when a reload changes the config. Old
+// and New are pointers to copies of the config before and after; they are
+// never modified.
+type Change struct {
+	Old, New interface{}
+	// Fields lists the paths of the fields which changed, with their
+	// names joined by ".".
+	Fields []string
+}
+
+// A Watcher reloads a config struct periodically; see Watch.
+type Watcher struct {
+	d        *Decoder
+	conf     reflect.Value
+	plan     []PlannedField
+	interval time.Duration
+	c        chan Change
+
+	mu      sync.RWMutex
+	current atomic.Value
+	err     atomic.Value
+
+	cbMu      sync.Mutex
+	callbacks []changeCallback
+}
+
+// changeCallback is a callback registered with OnChange.
+type changeCallback struct {
+	path  string
+	index []int
+	fn    func(old, new interface{})
+}
+
+// Watch decodes conf from src, and then decodes it again every interval
+// until ctx is done, so that a long-running service picks up changed
+// values, such as a log level, without restarting. A shortcut for:
+//
+//	envconf.NewDecoder(envconf.WithSource(src)).Watch(ctx, conf, interval)
+func Watch(ctx context.Context, conf interface{}, src Source, interval time.Duration) (*Watcher, error) {
+	return NewDecoder(WithSource(src)).Watch(ctx, conf, interval)
+}
+
+// Watch decodes conf, which must be a pointer to a struct, and then decodes
+// it again every interval until ctx is done. An error from the first decode
+// is returned.
+//
+// Each reload refreshes the Decoder's source if it is a Refresher, and
+// decodes into a new value. If that fails, conf is left as it was and the
+// error is passed to the warning func and kept for Err; otherwise, if any
+// field changed, the new value is copied into conf and a Change is
+// delivered on C.
+//
+// Reloads write conf while holding the Watcher's lock, so goroutines which
+// read conf directly must hold RLock while they do. Alternatively, Current
+// returns a copy of the config which is never modified, and is replaced
+// atomically by each reload:
+//
+//	w, err := d.Watch(ctx, &conf, time.Minute)
+//	...
+//	for change := range w.C() {
+//		logger.SetLevel(change.New.(*Config).LogLevel)
+//	}
+func (d *Decoder) Watch(ctx context.Context, conf interface{}, interval time.Duration) (*Watcher, error) {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Decode(conf); err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		d:        d,
+		conf:     reflect.ValueOf(conf).Elem(),
+		plan:     plan,
+		interval: interval,
+		c:        make(chan Change, 1),
+	}
+	w.current.Store(w.copyConf())
+	go w.run(ctx)
+	return w, nil
+}
+
+// OnChange registers fn to be called after each reload which changes the
+// field at path, a path of Go field names joined with "." such as
+// "DB.URL", with the field's old and new values. A path naming a nested
+// struct matches changes to any of its fields. This lets a service react to
+// just the settings it cares about:
+//
+//	w.OnChange("DB.URL", func(old, new interface{}) {
+//		pool.Reconnect(new.(string))
+//	})
+//
+// Callbacks are called one at a time from the Watcher's goroutine, in the
+// order they were registered, before the change is delivered on C.
+func (w *Watcher) OnChange(path string, fn func(old, new interface{})) error {
+	t := w.conf.Type()
+	var index []int
+	for _, name := range strings.Split(path, ".") {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return fmt.Errorf("No config field %s in %v", path, w.conf.Type())
+		}
+		f, ok := t.FieldByName(name)
+		if !ok || len(f.PkgPath) > 0 {
+			return fmt.Errorf("No config field %s in %v", path, w.conf.Type())
+		}
+		index = append(index, f.Index...)
+		t = f.Type
+	}
+
+	w.cbMu.Lock()
+	w.callbacks = append(w.callbacks, changeCallback{path, index, fn})
+	w.cbMu.Unlock()
+	return nil
+}
+
+// notify calls the callbacks registered for the fields in change.
+func (w *Watcher) notify(change Change) {
+	w.cbMu.Lock()
+	callbacks := w.callbacks
+	w.cbMu.Unlock()
+
+	prev, next := reflect.ValueOf(change.Old).Elem(), reflect.ValueOf(change.New).Elem()
+	for _, cb := range callbacks {
+		for _, field := range change.Fields {
+			if field == cb.path || strings.HasPrefix(field, cb.path+".") {
+				cb.fn(fieldInterface(prev, cb.index), fieldInterface(next, cb.index))
+				break
+			}
+		}
+	}
+}
+
+// C returns the channel on which changes are delivered. If changes are not
+// received as fast as they happen, only the latest is kept. The channel is
+// closed once the Watcher's context is done.
+func (w *Watcher) C() <-chan Change {
+	return w.c
+}
+
+// Current returns a pointer to a copy of the config as of the latest
+// successful reload. The copy is never modified.
+func (w *Watcher) Current() interface{} {
+	return w.current.Load()
+}
+
+// Err returns the error from the latest reload, or nil if it succeeded.
+func (w *Watcher) Err() error {
+	v, _ := w.err.Load().(errorValue)
+	return v.error
+}
+
+// RLock locks the config for reading; reloads wait until RUnlock.
+func (w *Watcher) RLock() { w.mu.RLock() }
+
+// RUnlock undoes a call to RLock.
+func (w *Watcher) RUnlock() { w.mu.RUnlock() }
+
+// run reloads the config every interval until ctx is done.
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.c)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if change, ok := w.reload(); ok {
+			w.notify(change)
+			w.deliver(change)
+		}
+	}
+}
+
+// reload decodes the config afresh and, if it changed, applies it.
+func (w *Watcher) reload() (Change, bool) {
+	if r, ok := w.d.source.(Refresher); ok {
+		r.Refresh()
+	}
+
+	next := reflect.New(w.conf.Type())
+	if err := w.d.Decode(next.Interface()); err != nil {
+		w.err.Store(errorValue{err})
+		w.d.warn(err)
+		return Change{}, false
+	}
+	w.err.Store(errorValue{})
+
+	old := w.current.Load()
+	fields := changedFields(w.plan, reflect.ValueOf(old).Elem(), next.Elem())
+	if len(fields) == 0 {
+		return Change{}, false
+	}
+
+	w.mu.Lock()
+	w.conf.Set(next.Elem())
+	w.mu.Unlock()
+	w.current.Store(next.Interface())
+	return Change{Old: old, New: next.Interface(), Fields: fields}, true
+}
+
+// deliver sends change on the channel, replacing an undelivered change.
+func (w *Watcher) deliver(change Change) {
+	for {
+		select {
+		case w.c <- change:
+			return
+		default:
+		}
+		select {
+		case <-w.c:
+		default:
+		}
+	}
+}
+
+// copyConf returns a pointer to a copy of the config.
+func (w *Watcher) copyConf() interface{} {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p := reflect.New(w.conf.Type())
+	p.Elem().Set(w.conf)
+	return p.Interface()
+}
+
+// errorValue wraps errors for atomic.Value, which needs a consistent type.
+type errorValue struct{ error }
+
+// changedFields returns the paths of the fields in plan whose values differ
+// between the config structs a and b. Lazy fields are not compared, as each
+// decode gives them new state.
+func changedFields(plan []PlannedField, a, b reflect.Value) []string {
+	var changed []string
+	for _, pf := range plan {
+		if reflect.PtrTo(pf.Field.Type).Implements(lazyBinderType) {
+			continue
+		}
+		if !reflect.DeepEqual(fieldInterface(a, pf.index), fieldInterface(b, pf.index)) {
+			changed = append(changed, strings.Join(pf.Path, "."))
+		}
+	}
+	return changed
+}
+
+// fieldInterface returns the value of the field of the struct v at index, or
+// nil if it is behind a nil pointer.
+func fieldInterface(v reflect.Value, index []int) interface{} {
+	if fv, ok := fieldByIndex(v, index, false); ok {
+		return fv.Interface()
+	}
+	return nil
+}
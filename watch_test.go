@@ -0,0 +1,168 @@
+package envconf
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// changingSource is a MapSource which can be changed while in use, and
+// counts its refreshes.
+type changingSource struct {
+	mu        sync.Mutex
+	m         MapSource
+	refreshes int
+}
+
+func (s *changingSource) Lookup(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Lookup(key)
+}
+
+func (s *changingSource) Refresh() {
+	s.mu.Lock()
+	s.refreshes++
+	s.mu.Unlock()
+}
+
+func (s *changingSource) set(key, value string) {
+	s.mu.Lock()
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+type watchConfig struct {
+	LogLevel string `default:"info"`
+	Port     int    `required:"true"`
+	Bucket   Lazy[string]
+}
+
+func TestWatch(t *testing.T) {
+	src := &changingSource{m: MapSource{"PORT": "80"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var conf watchConfig
+	var warnings []error
+	d := NewDecoder(WithSource(src), WithWarningFunc(func(err error) { warnings = append(warnings, err) }))
+	w, err := d.Watch(ctx, &conf, 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.LogLevel != "info" || w.Current().(*watchConfig).Port != 80 {
+		t.Errorf("Expected the first decode to be applied, got %+v", conf)
+		t.Fail()
+	}
+
+	src.set("LOGLEVEL", "debug")
+	var change Change
+	select {
+	case change = <-w.C():
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for a change")
+		t.FailNow()
+	}
+	if change.Old.(*watchConfig).LogLevel != "info" || change.New.(*watchConfig).LogLevel != "debug" ||
+		!reflect.DeepEqual(change.Fields, []string{"LogLevel"}) {
+		t.Errorf("Unexpected change %+v", change)
+		t.Fail()
+	}
+	w.RLock()
+	if conf.LogLevel != "debug" {
+		t.Errorf("Expected the change to be applied to conf, got %+v", conf)
+		t.Fail()
+	}
+	w.RUnlock()
+
+	// An invalid config is not applied.
+	src.set("PORT", "eighty")
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := w.Err(); err == nil || !strings.Contains(err.Error(), `parsing "eighty"`) {
+		t.Errorf("Expected the reload error from Err, got %v", err)
+		t.Fail()
+	}
+	if w.Current().(*watchConfig).Port != 80 {
+		t.Errorf("Expected the last good config to be kept, got %+v", w.Current())
+		t.Fail()
+	}
+
+	cancel()
+	for range w.C() {
+	}
+	src.mu.Lock()
+	if src.refreshes == 0 {
+		t.Errorf("Expected the source to be refreshed before reloads")
+		t.Fail()
+	}
+	src.mu.Unlock()
+
+	if _, err := Watch(context.Background(), &conf, MapSource{}, time.Second); err == nil {
+		t.Errorf("Watch(): expected an error from the first decode")
+		t.Fail()
+	}
+}
+
+func TestWatcherOnChange(t *testing.T) {
+	type dbConfig struct {
+		URL      string
+		MaxConns int `default:"10"`
+	}
+	type onChangeConfig struct {
+		LogLevel string `default:"info"`
+		DB       dbConfig
+	}
+
+	src := &changingSource{m: MapSource{"DB_URL": "postgres://a"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var conf onChangeConfig
+	w, err := Watch(ctx, &conf, src, 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	var calls []string
+	record := func(name string) func(old, new interface{}) {
+		return func(old, new interface{}) {
+			calls = append(calls, fmt.Sprintf("%s: %v -> %v", name, old, new))
+		}
+	}
+	for _, path := range []string{"DB.URL", "LogLevel", "DB"} {
+		if err := w.OnChange(path, record(path)); err != nil {
+			t.Errorf("Unexpected error %v", err)
+			t.FailNow()
+		}
+	}
+	match := "No config field DB.Host in envconf.onChangeConfig"
+	if err := w.OnChange("DB.Host", record("DB.Host")); err == nil || err.Error() != match {
+		t.Errorf("expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	src.set("DB_URL", "postgres://b")
+	select {
+	case <-w.C():
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for a change")
+		t.FailNow()
+	}
+	expect := []string{
+		"DB.URL: postgres://a -> postgres://b",
+		"DB: {postgres://a 10} -> {postgres://b 10}",
+	}
+	if !reflect.DeepEqual(calls, expect) {
+		t.Errorf("Expected callbacks %v, got %v", expect, calls)
+		t.Fail()
+	}
+}
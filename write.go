@@ -0,0 +1,205 @@
+package envconf
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Write returns the variables which ReadConfigMap would read back into
+// conf, as set by a Decoder with the default options. It is the inverse of
+// ReadConfig, for passing a resolved config down to child processes or test
+// harnesses:
+//
+//	env, err := envconf.Write(&conf)
+//	...
+//	for k, v := range env {
+//		cmd.Env = append(cmd.Env, k+"="+v)
+//	}
+func Write(conf interface{}) (map[string]string, error) {
+	return NewDecoder().Write(conf)
+}
+
+// Write returns the variables which the Decoder would read back into conf.
+// Every field is written, including zero values and secrets; use Dump to
+// log config.
+//
+// Values are formatted with MarshalText if their types implement
+// encoding.TextMarshaler, and otherwise as the Decoder parses them: slices
+// are joined with the field's separator, and quoted if they are read as CSV,
+// and fields with a "convert" tag are written in the tag's source format.
+// Fields which can't be written back as variables are left out: those whose
+// types implement EnvDecoder, Lazy fields, and fields tagged file:"true",
+// whose variables held paths. A struct implementing
+// encoding.TextUnmarshaler but not encoding.TextMarshaler, and with no
+// exported fields to write one by one, is an error.
+func (d *Decoder) Write(conf interface{}) (map[string]string, error) {
+	plan, err := d.Plan(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string, len(plan))
+	if err := d.write(env, reflect.Indirect(reflect.ValueOf(conf)), plan); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// write adds the variables for the fields of plan in the struct v to env.
+func (d *Decoder) write(env map[string]string, v reflect.Value, plan []PlannedField) error {
+	var skip []int
+	for i, pf := range plan {
+		if skip != nil && hasIndexPrefix(pf.index, skip) {
+			continue
+		}
+		field := pf.labelled()
+		if pf.SelfDecoding || field.Tag.Get("file") == "true" ||
+			reflect.PtrTo(field.Type).Implements(lazyBinderType) {
+			continue
+		}
+
+		fieldVal, ok := fieldByIndex(v, pf.index, false)
+		if !ok {
+			// behind a nil pointer
+			continue
+		}
+		if pf.Composite {
+			// written field by field unless it can be written whole
+			if _, ok := textMarshaler(fieldVal); !ok {
+				if i+1 < len(plan) && hasIndexPrefix(plan[i+1].index, pf.index) {
+					continue
+				}
+				return fmt.Errorf(
+					"Config field %s can't be written: %v has no MarshalText method",
+					field.Name, field.Type)
+			}
+			skip = pf.index
+		}
+		if pf.Indexed {
+			for i := 0; i < fieldVal.Len(); i++ {
+				if err := d.write(env, fieldVal.Index(i), d.elementPlan(pf, i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		s, err := d.format(field, fieldVal)
+		if err != nil {
+			return err
+		}
+		env[pf.Name] = s
+	}
+	return nil
+}
+
+// format formats the value of field as the Decoder would parse it.
+func (d *Decoder) format(field reflect.StructField, v reflect.Value) (string, error) {
+	if len(field.Tag.Get("convert")) > 0 {
+		return formatConverted(field, v)
+	}
+	if isJSONField(field) {
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	}
+
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Map) || isTextUnmarshaler(v.Type()) {
+		s, err := formatValue(v)
+		if err == errInvalidKind {
+			return "", fmt.Errorf(
+				"Invalid kind for config field %s: %v", field.Name, field.Type.Kind())
+		}
+		return s, err
+	}
+
+	if v.Kind() == reflect.Map {
+		entries, err := formatMap(field, v)
+		if err != nil {
+			return "", err
+		}
+		return d.join(field, entries)
+	}
+	elems := make([]string, v.Len())
+	for i := range elems {
+		s, err := formatValue(v.Index(i))
+		if err == errInvalidKind {
+			return "", fmt.Errorf(
+				"Invalid kind for config field %s: %v", field.Name, field.Type)
+		} else if err != nil {
+			return "", err
+		}
+		elems[i] = s
+	}
+	return d.join(field, elems)
+}
+
+// formatMap formats the entries of the map v as key=value, sorted.
+func formatMap(field reflect.StructField, v reflect.Value) ([]string, error) {
+	entries := make([]string, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, err := formatValue(iter.Key())
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Invalid kind for config field %s: %v", field.Name, field.Type)
+		}
+		e, err := formatValue(iter.Value())
+		if err == errInvalidKind {
+			return nil, fmt.Errorf(
+				"Invalid kind for config field %s: %v", field.Name, field.Type)
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, k+"="+e)
+	}
+	sort.Strings(entries)
+	return entries, nil
+}
+
+// textMarshaler returns v, or a pointer to it, as an encoding.TextMarshaler.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		return tm, true
+	}
+	if v.CanAddr() {
+		tm, ok := v.Addr().Interface().(encoding.TextMarshaler)
+		return tm, ok
+	}
+	return nil, false
+}
+
+// formatValue formats a single value as setValue parses it.
+func formatValue(v reflect.Value) (string, error) {
+	if tm, ok := textMarshaler(v); ok {
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	// types read by a Parser are written with their String method
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", nil
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return "", errInvalidKind
+}
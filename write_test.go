@@ -0,0 +1,102 @@
+package envconf
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type writeConfig struct {
+	Port    int
+	Debug   bool
+	Ratio   float64
+	Name    string
+	Timeout time.Duration
+	Legacy  time.Duration `convert:"ms->duration"`
+	Hosts   []string      `separator:";"`
+	Ports   []int
+	Bind    net.IP
+	Window  window
+	Server  struct {
+		Host string
+	}
+	Cache cacheConfig
+	Token string `file:"true"`
+	Later Lazy[string]
+}
+
+func TestWrite(t *testing.T) {
+	conf := writeConfig{
+		Port:    8080,
+		Debug:   true,
+		Ratio:   0.75,
+		Name:    "app",
+		Timeout: 90 * time.Second,
+		Legacy:  1500 * time.Millisecond,
+		Hosts:   []string{"a,1", "b"},
+		Ports:   []int{1, 2},
+		Bind:    net.ParseIP("10.0.0.1"),
+		Window:  window{9, 17},
+	}
+	conf.Server.Host = "example.com"
+
+	env, err := Write(&conf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	expect := map[string]string{
+		"PORT":         "8080",
+		"DEBUG":        "true",
+		"RATIO":        "0.75",
+		"NAME":         "app",
+		"TIMEOUT":      "1m30s",
+		"LEGACY":       "1500",
+		"HOSTS":        "a,1;b",
+		"PORTS":        "1,2",
+		"BIND":         "10.0.0.1",
+		"WINDOW_START": "9",
+		"WINDOW_END":   "17",
+		"SERVER_HOST":  "example.com",
+	}
+	if !reflect.DeepEqual(env, expect) {
+		t.Errorf("Write(): expected %v, got %v", expect, env)
+		t.Fail()
+	}
+
+	// Reading it back gives the same config.
+	var back writeConfig
+	if err := ReadConfigMap(&back, env); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	back.Later = Lazy[string]{}
+	if !reflect.DeepEqual(back, conf) {
+		t.Errorf("ReadConfigMap(): expected %+v, got %+v", conf, back)
+		t.Fail()
+	}
+
+	var bad struct{ Ch chan int }
+	match := "Invalid kind for config field Ch (CH): chan"
+	if _, err := Write(&bad); err == nil || !strings.Contains(err.Error(), match) {
+		t.Errorf("Write(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+
+	var opaque struct{ Key opaqueKey }
+	match = "Config field Key (KEY) can't be written: envconf.opaqueKey has no MarshalText method"
+	if _, err := Write(&opaque); err == nil || err.Error() != match {
+		t.Errorf("Write(): expected an error matching '%s', got '%v'", match, err)
+		t.Fail()
+	}
+}
+
+// opaqueKey can be read from a variable but not written back.
+type opaqueKey struct{ b []byte }
+
+func (k *opaqueKey) UnmarshalText(text []byte) error {
+	k.b = text
+	return nil
+}
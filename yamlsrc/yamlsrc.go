@@ -0,0 +1,63 @@
+/*
+Package yamlsrc provides an envconf Source backed by a YAML document, such as
+a values.yaml file mounted from a Kubernetes ConfigMap.
+
+Nested keys are flattened as by envconf.Flatten, so
+
+	server:
+	  port: 8080
+	  hosts: [a, b]
+
+serves SERVER_PORT=8080 and SERVER_HOSTS=a,b. A common setup reads the YAML
+file as a base and lets the environment override it:
+
+	base, err := yamlsrc.File("/etc/app/values.yaml")
+	if err != nil {
+		return err
+	}
+	err = envconf.ReadConfigSource(&conf,
+		envconf.LayerSources(envconf.EnvSource{}, base))
+
+The package lives apart from envconf so that only programs which read YAML
+depend on gopkg.in/yaml.v3.
+*/
+package yamlsrc
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ceralena/envconf"
+	"gopkg.in/yaml.v3"
+)
+
+// Parse returns a Source serving the values in the YAML document data, which
+// must be a mapping. An empty document serves nothing.
+func Parse(data []byte) (envconf.MapSource, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	switch doc.(type) {
+	case nil:
+		return envconf.MapSource{}, nil
+	case map[string]interface{}, map[interface{}]interface{}:
+		return envconf.MapSource(envconf.Flatten(doc, envconf.DefaultNamer)), nil
+	default:
+		return nil, fmt.Errorf("Invalid YAML config: expected a mapping")
+	}
+}
+
+// File is like Parse, but reads the document from the file at path.
+func File(path string) (envconf.MapSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return src, nil
+}
@@ -0,0 +1,101 @@
+package yamlsrc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ceralena/envconf"
+)
+
+const testYAML = `
+port: 8080
+debug: true
+timeout: 30s
+server:
+  host: localhost
+  tls:
+    certFile: /etc/cert.pem
+tags: [a, b]
+upstreams:
+  - host: a
+    port: 1
+  - host: b
+nothing: ~
+`
+
+func TestParse(t *testing.T) {
+	src, err := Parse([]byte(testYAML))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	expect := envconf.MapSource{
+		"PORT":                "8080",
+		"DEBUG":               "true",
+		"TIMEOUT":             "30s",
+		"SERVER_HOST":         "localhost",
+		"SERVER_TLS_CERTFILE": "/etc/cert.pem",
+		"TAGS":                "a,b",
+		"UPSTREAMS_0_HOST":    "a",
+		"UPSTREAMS_0_PORT":    "1",
+		"UPSTREAMS_1_HOST":    "b",
+	}
+	if !reflect.DeepEqual(src, expect) {
+		t.Errorf("Parse(): expected %v, got %v", expect, src)
+		t.Fail()
+	}
+
+	if src, err := Parse(nil); err != nil || len(src) != 0 {
+		t.Errorf("Parse(nil): expected an empty source, got %v, %v", src, err)
+		t.Fail()
+	}
+
+	for _, bad := range []string{"- a\n- b\n", "a: [1"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q): expected an error", bad)
+			t.Fail()
+		}
+	}
+}
+
+func TestFileWithEnvOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(path, []byte(testYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := File(path)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+
+	var conf struct {
+		Port   int
+		Server struct{ Host string }
+	}
+	src := envconf.LayerSources(envconf.MapSource{"PORT": "9090"}, base)
+	if err := envconf.ReadConfigSource(&conf, src); err != nil {
+		t.Errorf("Unexpected error %v", err)
+		t.FailNow()
+	}
+	if conf.Port != 9090 || conf.Server.Host != "localhost" {
+		t.Errorf("Expected the override and the YAML base, got %+v", conf)
+		t.Fail()
+	}
+
+	if _, err := File(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("File(): expected an error for a missing file")
+		t.Fail()
+	}
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(bad, []byte("- a\n"), 0600)
+	if _, err := File(bad); err == nil || !strings.HasPrefix(err.Error(), bad) {
+		t.Errorf("File(): expected an error naming the file, got %v", err)
+		t.Fail()
+	}
+}